@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type ExpireMode int
+
+const (
+	// ExpireFixed 过期时间只在计数器创建时设置, 到窗口结束时过期
+	ExpireFixed ExpireMode = iota
+	// ExpireSliding 每次增加都重新设置过期时间
+	ExpireSliding
+)
+
+type ICounterCache[K comparable] interface {
+	Incr(ctx context.Context, k K) (int64, error)
+	IncrBy(ctx context.Context, k K, n int64) (int64, error)
+	Decr(ctx context.Context, k K) (int64, error)
+	// Get 计数器不存在时返回0
+	Get(ctx context.Context, k K) (int64, error)
+	GetMany(ctx context.Context, keys ...K) (map[K]int64, error)
+	Reset(ctx context.Context, keys ...K) error
+	IKeyExpiration[K]
+}
+
+type counterCache[K comparable] struct {
+	client        ICommonCache
+	formatHandler func(K) string
+	expiration    time.Duration
+	mode          ExpireMode
+}
+
+func CreateCounterCache[K comparable](client ICommonCache, expiration time.Duration, mode ExpireMode, format ...func(k K) string) ICounterCache[K] {
+	r := &counterCache[K]{
+		client:     client,
+		expiration: expiration,
+		mode:       mode,
+	}
+	if len(format) > 0 {
+		r.formatHandler = format[0]
+	} else {
+		r.formatHandler = func(k K) string {
+			return fmt.Sprint(k)
+		}
+	}
+	return r
+}
+
+func (r *counterCache[K]) Incr(ctx context.Context, k K) (int64, error) {
+	return r.IncrBy(ctx, k, 1)
+}
+
+func (r *counterCache[K]) Decr(ctx context.Context, k K) (int64, error) {
+	return r.IncrBy(ctx, k, -1)
+}
+
+func (r *counterCache[K]) IncrBy(ctx context.Context, k K, n int64) (int64, error) {
+	return r.client.IncrByEx(ctx, r.formatHandler(k), n, r.expiration, r.mode == ExpireSliding)
+}
+
+func (r *counterCache[K]) Get(ctx context.Context, k K) (int64, error) {
+	v, err := r.client.GetInt(ctx, r.formatHandler(k))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return v, nil
+}
+
+func (r *counterCache[K]) GetMany(ctx context.Context, ks ...K) (map[K]int64, error) {
+	rs := make(map[K]int64, len(ks))
+	if len(ks) == 0 {
+		return rs, nil
+	}
+	keys := make([]string, 0, len(ks))
+	for _, k := range ks {
+		keys = append(keys, r.formatHandler(k))
+	}
+	values, err := r.client.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range ks {
+		if values[i] == nil {
+			rs[k] = 0
+			continue
+		}
+		v, err := strconv.ParseInt(string(values[i]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		rs[k] = v
+	}
+	return rs, nil
+}
+
+func (r *counterCache[K]) Reset(ctx context.Context, ks ...K) error {
+	if len(ks) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(ks))
+	for _, k := range ks {
+		keys = append(keys, r.formatHandler(k))
+	}
+	return r.client.Del(ctx, keys...)
+}
+
+func (r *counterCache[K]) TTL(ctx context.Context, k K) (time.Duration, error) {
+	return r.client.TTL(ctx, r.formatHandler(k))
+}
+
+func (r *counterCache[K]) Expire(ctx context.Context, k K, expiration time.Duration) (bool, error) {
+	return r.client.Expire(ctx, r.formatHandler(k), expiration)
+}
+
+func (r *counterCache[K]) ExpireAt(ctx context.Context, k K, t time.Time) (bool, error) {
+	return r.client.ExpireAt(ctx, r.formatHandler(k), t)
+}
+
+func (r *counterCache[K]) Persist(ctx context.Context, k K) (bool, error) {
+	return r.client.Persist(ctx, r.formatHandler(k))
+}
+
+func (r *counterCache[K]) Exists(ctx context.Context, ks ...K) (int64, error) {
+	if len(ks) == 0 {
+		return 0, nil
+	}
+	keys := make([]string, 0, len(ks))
+	for _, k := range ks {
+		keys = append(keys, r.formatHandler(k))
+	}
+	return r.client.Exists(ctx, keys...)
+}
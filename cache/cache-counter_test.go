@@ -0,0 +1,89 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+func TestCounterCache(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	counter := cache.CreateCounterCache[int64](client, time.Minute, cache.ExpireFixed)
+
+	if v, err := counter.Get(ctx, 1); err != nil || v != 0 {
+		t.Errorf("missing counter: want 0, got %d %v", v, err)
+	}
+	if v, _ := counter.Incr(ctx, 1); v != 1 {
+		t.Errorf("Incr: want 1, got %d", v)
+	}
+	if v, _ := counter.IncrBy(ctx, 1, 5); v != 6 {
+		t.Errorf("IncrBy: want 6, got %d", v)
+	}
+	if v, _ := counter.Decr(ctx, 1); v != 5 {
+		t.Errorf("Decr: want 5, got %d", v)
+	}
+	_, _ = counter.Incr(ctx, 2)
+	values, err := counter.GetMany(ctx, 1, 2, 3)
+	if err != nil || values[1] != 5 || values[2] != 1 || values[3] != 0 || len(values) != 3 {
+		t.Errorf("GetMany: want {1:5 2:1 3:0}, got %v %v", values, err)
+	}
+	if err := counter.Reset(ctx, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := counter.Exists(ctx, 1, 2); n != 0 {
+		t.Errorf("Reset should delete counters, %d left", n)
+	}
+}
+
+func TestCounterExpireModes(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+
+	fixed := cache.CreateCounterCache[string](client, 200*time.Millisecond, cache.ExpireFixed, func(k string) string { return "fixed:" + k })
+	sliding := cache.CreateCounterCache[string](client, 200*time.Millisecond, cache.ExpireSliding, func(k string) string { return "sliding:" + k })
+
+	_, _ = fixed.Incr(ctx, "k")
+	_, _ = sliding.Incr(ctx, "k")
+	time.Sleep(120 * time.Millisecond)
+	_, _ = fixed.Incr(ctx, "k")
+	_, _ = sliding.Incr(ctx, "k")
+
+	// 固定窗口不会因为增加而延长, 滑动窗口每次增加都重新计时
+	if ttl, _ := fixed.TTL(ctx, "k"); ttl > 100*time.Millisecond {
+		t.Errorf("fixed: TTL should not be extended, got %s", ttl)
+	}
+	if ttl, _ := sliding.TTL(ctx, "k"); ttl <= 100*time.Millisecond {
+		t.Errorf("sliding: TTL should be extended, got %s", ttl)
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	if v, _ := fixed.Get(ctx, "k"); v != 0 {
+		t.Errorf("fixed: counter should expire at the end of the window, got %d", v)
+	}
+	if v, _ := sliding.Get(ctx, "k"); v != 2 {
+		t.Errorf("sliding: want 2, got %d", v)
+	}
+}
+
+// 没有过期时间的计数器(如 Persist 之后)再次增加时, 固定窗口模式也需要重新设置过期时间
+func TestCounterFixedModeRestoresMissingTTL(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	counter := cache.CreateCounterCache[int64](client, time.Minute, cache.ExpireFixed)
+
+	_, _ = counter.Incr(ctx, 1)
+	if ok, err := counter.Persist(ctx, 1); err != nil || !ok {
+		t.Fatalf("Persist: %v %v", ok, err)
+	}
+	if ttl, _ := counter.TTL(ctx, 1); ttl >= 0 {
+		t.Fatalf("Persist should remove the TTL, got %s", ttl)
+	}
+	_, _ = counter.Incr(ctx, 1)
+	if ttl, _ := counter.TTL(ctx, 1); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("Incr should set the missing TTL, got %s", ttl)
+	}
+}
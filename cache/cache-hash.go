@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// IHashCache 每个key对应一个hash, 每个字段单独编码保存, 可以按字段读写
+type IHashCache[T any, K comparable, F comparable] interface {
+	GetField(ctx context.Context, k K, f F) (*T, error)
+	SetField(ctx context.Context, k K, f F, t *T) error
+	// GetAll key不存在时与 GetField 一样返回 ErrNotFound
+	GetAll(ctx context.Context, k K) (map[F]*T, error)
+	DeleteFields(ctx context.Context, k K, fs ...F) error
+	Delete(ctx context.Context, keys ...K) error
+	IKeyExpiration[K]
+}
+
+type hashCache[T any, K comparable, F comparable] struct {
+	client        ICommonCache
+	formatHandler func(K) string
+	expiration    time.Duration
+	codec         Codec
+}
+
+// CreateHashCache format 为空时使用 fmt.Sprint 生成key, 字段使用 fmt.Sprint 转换为字符串, 因此F应为字符串或数值类型
+// 忽略 WithRefreshAhead
+func CreateHashCache[T any, K comparable, F comparable](client ICommonCache, expiration time.Duration, format func(k K) string, opts ...Option) IHashCache[T, K, F] {
+	if expiration == 0 {
+		expiration = defaultExpiration
+	}
+	o := newOptions(opts)
+	r := &hashCache[T, K, F]{
+		client:     client,
+		expiration: expiration,
+		codec:      o.codec,
+	}
+	if format != nil {
+		r.formatHandler = format
+	} else {
+		r.formatHandler = func(k K) string {
+			return fmt.Sprint(k)
+		}
+	}
+	return r
+}
+
+func (r *hashCache[T, K, F]) GetField(ctx context.Context, k K, f F) (*T, error) {
+	bytes, err := r.client.HGet(ctx, r.formatHandler(k), fmt.Sprint(f))
+	if err != nil {
+		return nil, err
+	}
+	return decode[T](r.codec, bytes)
+}
+
+func (r *hashCache[T, K, F]) SetField(ctx context.Context, k K, f F, t *T) error {
+	bytes, err := encode(r.codec, t)
+	if err != nil {
+		return err
+	}
+	return r.client.HSet(ctx, r.formatHandler(k), fmt.Sprint(f), bytes, r.expiration)
+}
+
+func (r *hashCache[T, K, F]) GetAll(ctx context.Context, k K) (map[F]*T, error) {
+	values, err := r.client.HGetAllBytes(ctx, r.formatHandler(k))
+	if err != nil {
+		return nil, err
+	}
+	// Redis不保存空的hash, 没有任何字段即key不存在
+	if len(values) == 0 {
+		return nil, ErrNotFound
+	}
+	rs := make(map[F]*T, len(values))
+	for field, bytes := range values {
+		f, err := parseField[F](field)
+		if err != nil {
+			return nil, err
+		}
+		t, err := decode[T](r.codec, bytes)
+		if err != nil {
+			return nil, err
+		}
+		rs[f] = t
+	}
+	return rs, nil
+}
+
+func (r *hashCache[T, K, F]) DeleteFields(ctx context.Context, k K, fs ...F) error {
+	if len(fs) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(fs))
+	for _, f := range fs {
+		fields = append(fields, fmt.Sprint(f))
+	}
+	return r.client.HDel(ctx, r.formatHandler(k), fields...)
+}
+
+func (r *hashCache[T, K, F]) Delete(ctx context.Context, ks ...K) error {
+	if len(ks) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(ks))
+	for _, k := range ks {
+		keys = append(keys, r.formatHandler(k))
+	}
+	return r.client.Del(ctx, keys...)
+}
+
+func (r *hashCache[T, K, F]) TTL(ctx context.Context, k K) (time.Duration, error) {
+	return r.client.TTL(ctx, r.formatHandler(k))
+}
+
+func (r *hashCache[T, K, F]) Expire(ctx context.Context, k K, expiration time.Duration) (bool, error) {
+	return r.client.Expire(ctx, r.formatHandler(k), expiration)
+}
+
+func (r *hashCache[T, K, F]) ExpireAt(ctx context.Context, k K, t time.Time) (bool, error) {
+	return r.client.ExpireAt(ctx, r.formatHandler(k), t)
+}
+
+func (r *hashCache[T, K, F]) Persist(ctx context.Context, k K) (bool, error) {
+	return r.client.Persist(ctx, r.formatHandler(k))
+}
+
+func (r *hashCache[T, K, F]) Exists(ctx context.Context, ks ...K) (int64, error) {
+	if len(ks) == 0 {
+		return 0, nil
+	}
+	keys := make([]string, 0, len(ks))
+	for _, k := range ks {
+		keys = append(keys, r.formatHandler(k))
+	}
+	return r.client.Exists(ctx, keys...)
+}
+
+func parseField[F comparable](field string) (F, error) {
+	var f F
+	// 字符串类型(包括以string为底层类型的自定义类型)直接转换, fmt.Sscan 会在空白处截断
+	if v := reflect.ValueOf(&f).Elem(); v.Kind() == reflect.String {
+		v.SetString(field)
+		return f, nil
+	}
+	if _, err := fmt.Sscan(field, &f); err != nil {
+		return f, fmt.Errorf("parse hash field %s: %w", field, err)
+	}
+	return f, nil
+}
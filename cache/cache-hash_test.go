@@ -0,0 +1,89 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+type settingName string
+
+func TestHashCache(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	settings := cache.CreateHashCache[user, int64, string](client, time.Minute, nil)
+
+	if err := settings.SetField(ctx, 1, "a", &user{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = settings.SetField(ctx, 1, "b", &user{Name: "b"})
+	if u, err := settings.GetField(ctx, 1, "a"); err != nil || u.Name != "a" {
+		t.Errorf("GetField: want a, got %v %v", u, err)
+	}
+	all, err := settings.GetAll(ctx, 1)
+	if err != nil || len(all) != 2 || all["b"].Name != "b" {
+		t.Errorf("GetAll: want 2 fields, got %v %v", all, err)
+	}
+	if ttl, _ := settings.TTL(ctx, 1); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("SetField should apply expiration, got %s", ttl)
+	}
+
+	if err := settings.DeleteFields(ctx, 1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := settings.GetField(ctx, 1, "a"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("deleted field: want ErrNotFound, got %v", err)
+	}
+	if err := settings.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := settings.Exists(ctx, 1); n != 0 {
+		t.Errorf("Delete should remove the hash, got %d", n)
+	}
+}
+
+func TestHashCacheMissIsConsistent(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	settings := cache.CreateHashCache[user, int64, string](client, time.Minute, nil)
+
+	if _, err := settings.GetField(ctx, 1, "a"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("GetField on missing hash: want ErrNotFound, got %v", err)
+	}
+	if all, err := settings.GetAll(ctx, 1); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("GetAll on missing hash: want ErrNotFound, got %v %v", all, err)
+	}
+	// 删除最后一个字段后hash不再存在
+	_ = settings.SetField(ctx, 1, "a", &user{})
+	_ = settings.DeleteFields(ctx, 1, "a")
+	if _, err := settings.GetAll(ctx, 1); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("GetAll after deleting every field: want ErrNotFound, got %v", err)
+	}
+}
+
+func TestHashCacheFieldTypes(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+
+	named := cache.CreateHashCache[user, int64, settingName](client, time.Minute, func(k int64) string { return "named" })
+	_ = named.SetField(ctx, 1, "dark theme", &user{Name: "x"})
+	all, err := named.GetAll(ctx, 1)
+	if err != nil || all["dark theme"] == nil {
+		t.Errorf("named string field with spaces: want \"dark theme\", got %v %v", all, err)
+	}
+
+	numeric := cache.CreateHashCache[user, int64, int32](client, time.Minute, func(k int64) string { return "numeric" })
+	_ = numeric.SetField(ctx, 1, -7, &user{Name: "n"})
+	if all, err := numeric.GetAll(ctx, 1); err != nil || all[-7] == nil || all[-7].Name != "n" {
+		t.Errorf("numeric field: want -7, got %v %v", all, err)
+	}
+
+	_ = client.HSet(ctx, "numeric", "x", []byte(`{}`), 0)
+	if _, err := numeric.GetAll(ctx, 1); err == nil {
+		t.Error("non-numeric field should fail to parse")
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// nativeListCache 使用Redis列表(RPUSH/LRANGE/LTRIM/LLEN)保存, 每个元素单独编码
+type nativeListCache[T any] struct {
+	client ICommonCache
+	key    string
+	codec  Codec
+	maxLen int64
+	expirationPolicy
+}
+
+func (r *nativeListCache[T]) Delete(ctx context.Context) error {
+	return r.client.Del(ctx, r.key)
+}
+
+func (r *nativeListCache[T]) TTL(ctx context.Context) (time.Duration, error) {
+	return r.client.TTL(ctx, r.key)
+}
+
+func (r *nativeListCache[T]) Expire(ctx context.Context, expiration time.Duration) (bool, error) {
+	return r.client.Expire(ctx, r.key, expiration)
+}
+
+func (r *nativeListCache[T]) ExpireAt(ctx context.Context, t time.Time) (bool, error) {
+	return r.client.ExpireAt(ctx, r.key, t)
+}
+
+func (r *nativeListCache[T]) Persist(ctx context.Context) (bool, error) {
+	return r.client.Persist(ctx, r.key)
+}
+
+func (r *nativeListCache[T]) Exists(ctx context.Context) (bool, error) {
+	n, err := r.client.Exists(ctx, r.key)
+	return n > 0, err
+}
+
+// GetAll 列表为空时返回 ErrNotFound, 与整体保存的列表缓存保持一致
+func (r *nativeListCache[T]) GetAll(ctx context.Context) ([]T, error) {
+	list, err := r.lrange(ctx, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	r.touch(ctx, r.client, r.key)
+	return list, nil
+}
+
+func (r *nativeListCache[T]) SetAll(ctx context.Context, ts []T) error {
+	if r.maxLen > 0 && int64(len(ts)) > r.maxLen {
+		ts = ts[int64(len(ts))-r.maxLen:]
+	}
+	values, err := r.encodeAll(ts)
+	if err != nil {
+		return err
+	}
+	return r.client.LReplace(ctx, r.key, r.ttl(), values...)
+}
+
+func (r *nativeListCache[T]) Append(ctx context.Context, ts ...T) error {
+	if len(ts) == 0 {
+		return nil
+	}
+	values, err := r.encodeAll(ts)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.RPushTrim(ctx, r.key, r.maxLen, r.ttl(), values...)
+	return err
+}
+
+func (r *nativeListCache[T]) Prepend(ctx context.Context, ts ...T) error {
+	if len(ts) == 0 {
+		return nil
+	}
+	values, err := r.encodeAll(ts)
+	if err != nil {
+		return err
+	}
+	// LPUSH 会将参数逆序插入, 反转后保证 Prepend(a, b) 的结果以 a, b 开头
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+	_, err = r.client.LPushTrim(ctx, r.key, r.maxLen, r.ttl(), values...)
+	return err
+}
+
+func (r *nativeListCache[T]) Range(ctx context.Context, offset, limit int64) ([]T, error) {
+	offset = max(offset, 0)
+	stop := int64(-1)
+	if limit > 0 {
+		stop = offset + limit - 1
+	}
+	list, err := r.lrange(ctx, offset, stop)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) > 0 {
+		r.touch(ctx, r.client, r.key)
+	}
+	return list, nil
+}
+
+func (r *nativeListCache[T]) Len(ctx context.Context) (int64, error) {
+	return r.client.LLen(ctx, r.key)
+}
+
+func (r *nativeListCache[T]) lrange(ctx context.Context, start, stop int64) ([]T, error) {
+	values, err := r.client.LRange(ctx, r.key, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]T, 0, len(values))
+	for _, v := range values {
+		t, err := decode[T](r.codec, v)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, nil
+}
+
+func (r *nativeListCache[T]) encodeAll(ts []T) ([][]byte, error) {
+	values := make([][]byte, 0, len(ts))
+	for _, t := range ts {
+		bytes, err := encode(r.codec, t)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, bytes)
+	}
+	return values, nil
+}
@@ -2,26 +2,51 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
 type IListCache[T any] interface {
+	// SetAll 传入空列表时删除缓存, 之后 GetAll 返回 ErrNotFound
 	SetAll(ctx context.Context, t []T) error
 	GetAll(ctx context.Context) ([]T, error)
 	Delete(ctx context.Context) error
+	// Append 在末尾追加, Prepend 在开头插入, Prepend(a, b) 后列表以 a, b 开头
+	Append(ctx context.Context, t ...T) error
+	Prepend(ctx context.Context, t ...T) error
+	// Range 读取从 offset 开始的 limit 个元素, limit 小于等于0时读取到末尾, 列表不存在或 offset 超出长度时返回空列表
+	Range(ctx context.Context, offset, limit int64) ([]T, error)
+	Len(ctx context.Context) (int64, error)
+	IExpiration
 }
 type listCache[T any] struct {
-	client     ICommonCache
-	key        string
-	expiration time.Duration
+	client ICommonCache
+	key    string
+	codec  Codec
+	maxLen int64
+	expirationPolicy
 }
 
-func CreateListCache[T any](client ICommonCache, expiration time.Duration, key string) IListCache[T] {
+// CreateListCache 默认将整个列表编码后作为一个值保存, 此时 Append/Prepend 需要读取并重写整个列表, 并发写入时可能丢失数据,
+// 需要频繁追加或分页读取时使用 WithNativeList, 忽略 WithRefreshAhead
+func CreateListCache[T any](client ICommonCache, expiration time.Duration, key string, opts ...Option) IListCache[T] {
 
+	o := newOptions(opts)
+	if o.nativeList {
+		return &nativeListCache[T]{
+			key:              key,
+			client:           client,
+			codec:            o.codec,
+			maxLen:           o.maxLen,
+			expirationPolicy: newExpirationPolicy(expiration, o),
+		}
+	}
 	r := &listCache[T]{
-		key:        key,
-		expiration: expiration,
-		client:     client,
+		key:              key,
+		client:           client,
+		codec:            o.codec,
+		maxLen:           o.maxLen,
+		expirationPolicy: newExpirationPolicy(expiration, o),
 	}
 
 	return r
@@ -30,23 +55,120 @@ func (r *listCache[T]) Delete(ctx context.Context) error {
 	return r.client.Del(ctx, r.key)
 }
 
+func (r *listCache[T]) TTL(ctx context.Context) (time.Duration, error) {
+	return r.client.TTL(ctx, r.key)
+}
+
+func (r *listCache[T]) Expire(ctx context.Context, expiration time.Duration) (bool, error) {
+	return r.client.Expire(ctx, r.key, expiration)
+}
+
+func (r *listCache[T]) ExpireAt(ctx context.Context, t time.Time) (bool, error) {
+	return r.client.ExpireAt(ctx, r.key, t)
+}
+
+func (r *listCache[T]) Persist(ctx context.Context) (bool, error) {
+	return r.client.Persist(ctx, r.key)
+}
+
+func (r *listCache[T]) Exists(ctx context.Context) (bool, error) {
+	n, err := r.client.Exists(ctx, r.key)
+	return n > 0, err
+}
+
 func (r *listCache[T]) GetAll(ctx context.Context) ([]T, error) {
+	list, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.touch(ctx, r.client, r.key)
+	return list, nil
+}
+
+func (r *listCache[T]) get(ctx context.Context) ([]T, error) {
 
 	bytes, err := r.client.Get(ctx, r.key)
 	if err != nil {
 		return nil, err
 	}
 
-	return decodeList[T](bytes)
+	return decodeList[T](r.codec, bytes)
 
 }
 
 func (r *listCache[T]) SetAll(ctx context.Context, t []T) error {
+	// Redis列表不能为空, 与 WithNativeList 保持一致, 空列表不保存
+	if len(t) == 0 {
+		return r.client.Del(ctx, r.key)
+	}
+	if r.maxLen > 0 && int64(len(t)) > r.maxLen {
+		t = t[int64(len(t))-r.maxLen:]
+	}
+	bytes, err := encode(r.codec, t)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, r.key, bytes, r.ttl())
+}
 
-	bytes, err := encode(t)
+func (r *listCache[T]) Append(ctx context.Context, ts ...T) error {
+	list, err := r.getOrEmpty(ctx)
 	if err != nil {
 		return err
 	}
+	list = append(list, ts...)
+	if r.maxLen > 0 && int64(len(list)) > r.maxLen {
+		list = list[int64(len(list))-r.maxLen:]
+	}
+	return r.SetAll(ctx, list)
+}
+
+func (r *listCache[T]) Prepend(ctx context.Context, ts ...T) error {
+	list, err := r.getOrEmpty(ctx)
+	if err != nil {
+		return err
+	}
+	list = append(append(make([]T, 0, len(ts)+len(list)), ts...), list...)
+	if r.maxLen > 0 && int64(len(list)) > r.maxLen {
+		list = list[:r.maxLen]
+	}
+	return r.SetAll(ctx, list)
+}
+
+func (r *listCache[T]) Range(ctx context.Context, offset, limit int64) ([]T, error) {
+	list, err := r.getOrEmpty(ctx)
+	if err != nil {
+		return nil, err
+	}
+	offset = max(offset, 0)
+	length := int64(len(list))
+	if offset >= length {
+		return []T{}, nil
+	}
+	r.touch(ctx, r.client, r.key)
+	end := length
+	if limit > 0 && offset+limit < length {
+		end = offset + limit
+	}
+	return list[offset:end], nil
+}
 
-	return r.client.Set(ctx, r.key, bytes, r.expiration)
+func (r *listCache[T]) Len(ctx context.Context) (int64, error) {
+	list, err := r.getOrEmpty(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(list)), nil
+}
+
+func (r *listCache[T]) getOrEmpty(ctx context.Context) ([]T, error) {
+	list, err := r.get(ctx)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return []T{}, nil
+		}
+		return nil, err
+	}
+	return list, nil
 }
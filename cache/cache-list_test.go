@@ -0,0 +1,145 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+// listModes 整体保存和Redis列表两种模式需要有相同的行为
+func listModes(t *testing.T, key string, opts ...cache.Option) map[string]cache.IListCache[int] {
+	t.Helper()
+	client, _ := cachetest.NewRedisCache(t)
+	return map[string]cache.IListCache[int]{
+		"blob":   cache.CreateListCache[int](client, time.Minute, key, opts...),
+		"native": cache.CreateListCache[int](client, time.Minute, key+":native", append(opts, cache.WithNativeList())...),
+	}
+}
+
+func TestListCache(t *testing.T) {
+	ctx := context.Background()
+	for name, list := range listModes(t, "list") {
+		t.Run(name, func(t *testing.T) {
+			if _, err := list.GetAll(ctx); !errors.Is(err, cache.ErrNotFound) {
+				t.Errorf("missing list: want ErrNotFound, got %v", err)
+			}
+			if err := list.SetAll(ctx, []int{3, 4}); err != nil {
+				t.Fatal(err)
+			}
+			_ = list.Append(ctx, 5, 6)
+			_ = list.Prepend(ctx, 1, 2)
+			if all, err := list.GetAll(ctx); err != nil || fmt.Sprint(all) != "[1 2 3 4 5 6]" {
+				t.Errorf("GetAll: want [1 2 3 4 5 6], got %v %v", all, err)
+			}
+			if page, err := list.Range(ctx, 2, 3); err != nil || fmt.Sprint(page) != "[3 4 5]" {
+				t.Errorf("Range 2 3: want [3 4 5], got %v %v", page, err)
+			}
+			if page, _ := list.Range(ctx, 4, 0); fmt.Sprint(page) != "[5 6]" {
+				t.Errorf("Range 4 0: want [5 6], got %v", page)
+			}
+			if page, _ := list.Range(ctx, 10, 2); len(page) != 0 {
+				t.Errorf("Range past the end: want empty, got %v", page)
+			}
+			if page, err := list.Range(ctx, -5, 2); err != nil || fmt.Sprint(page) != "[1 2]" {
+				t.Errorf("Range -5 2: want [1 2], got %v %v", page, err)
+			}
+			if n, _ := list.Len(ctx); n != 6 {
+				t.Errorf("Len: want 6, got %d", n)
+			}
+			if ttl, _ := list.TTL(ctx); ttl <= 0 || ttl > time.Minute {
+				t.Errorf("list should expire, got %s", ttl)
+			}
+			_ = list.Delete(ctx)
+			if ok, _ := list.Exists(ctx); ok {
+				t.Error("Delete should remove the list")
+			}
+		})
+	}
+}
+
+func TestListCacheSetAllEmpty(t *testing.T) {
+	ctx := context.Background()
+	for name, list := range listModes(t, "empty") {
+		t.Run(name, func(t *testing.T) {
+			_ = list.SetAll(ctx, []int{1})
+			if err := list.SetAll(ctx, []int{}); err != nil {
+				t.Fatal(err)
+			}
+			if all, err := list.GetAll(ctx); !errors.Is(err, cache.ErrNotFound) {
+				t.Errorf("GetAll after SetAll([]): want ErrNotFound, got %v %v", all, err)
+			}
+			if n, _ := list.Len(ctx); n != 0 {
+				t.Errorf("Len after SetAll([]): want 0, got %d", n)
+			}
+			if page, err := list.Range(ctx, 0, 10); err != nil || page == nil || len(page) != 0 {
+				t.Errorf("Range on a missing list: want an empty list, got %v %v", page, err)
+			}
+			if ok, _ := list.Exists(ctx); ok {
+				t.Error("SetAll([]) should not keep the key")
+			}
+		})
+	}
+}
+
+func TestListCacheMaxLen(t *testing.T) {
+	ctx := context.Background()
+	for name, list := range listModes(t, "max", cache.WithMaxLen(3)) {
+		t.Run(name, func(t *testing.T) {
+			_ = list.SetAll(ctx, []int{1, 2, 3, 4, 5})
+			if all, _ := list.GetAll(ctx); fmt.Sprint(all) != "[3 4 5]" {
+				t.Errorf("SetAll keeps the newest: want [3 4 5], got %v", all)
+			}
+			_ = list.SetAll(ctx, []int{1, 2})
+			_ = list.Append(ctx, 3, 4)
+			if all, _ := list.GetAll(ctx); fmt.Sprint(all) != "[2 3 4]" {
+				t.Errorf("Append keeps the newest: want [2 3 4], got %v", all)
+			}
+			_ = list.Prepend(ctx, 0, 1)
+			if all, _ := list.GetAll(ctx); fmt.Sprint(all) != "[0 1 2]" {
+				t.Errorf("Prepend keeps the head: want [0 1 2], got %v", all)
+			}
+		})
+	}
+}
+
+// Redis列表模式下追加和裁剪在同一个事务中执行, 并发读取不会看到超过上限的列表
+func TestNativeListTrimIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	list := cache.CreateListCache[int](client, time.Minute, "feed", cache.WithNativeList(), cache.WithMaxLen(5))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_ = list.Append(ctx, i, j)
+			}
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			if n, _ := list.Len(ctx); n != 5 {
+				t.Errorf("final Len: want 5, got %d", n)
+			}
+			return
+		default:
+		}
+		if n, err := list.Len(ctx); err != nil || n > 5 {
+			t.Fatalf("Len: want at most 5, got %d %v", n, err)
+		}
+	}
+}
@@ -36,13 +36,14 @@ func (r *simple[K]) Set(ctx context.Context, k K, v []byte) error {
 }
 
 func (r *simple[K]) Delete(ctx context.Context, ks ...K) error {
+	if len(ks) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(ks))
 	for _, k := range ks {
-		key := r.formatHandler(k)
-		if err := r.client.Del(ctx, key); err != nil {
-			return err
-		}
+		keys = append(keys, r.formatHandler(k))
 	}
-	return nil
+	return r.client.Del(ctx, keys...)
 }
 func CreateCacheSimple[K any](client ICommonCache, expiration time.Duration, formatHandler ...func(k K) string) SimpleCache[K] {
 
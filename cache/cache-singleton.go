@@ -8,7 +8,10 @@ import (
 type ISingletonCache[T any] interface {
 	Get(ctx context.Context) (*T, error)
 	Set(ctx context.Context, t *T) error
+	// SetWithTags 写入并加入标签集合, 之后可以通过 InvalidateTags 删除
+	SetWithTags(ctx context.Context, t *T, tags ...string) error
 	Delete(ctx context.Context) error
+	IExpiration
 }
 
 type cacheSingleton[T any] struct {
@@ -24,14 +27,41 @@ func (r *cacheSingleton[T]) Set(ctx context.Context, t *T) error {
 	return r.base.Set(ctx, r.key, t)
 }
 
+func (r *cacheSingleton[T]) SetWithTags(ctx context.Context, t *T, tags ...string) error {
+	return r.base.SetWithTags(ctx, r.key, t, tags...)
+}
+
 func (r *cacheSingleton[T]) Delete(ctx context.Context) error {
 	return r.base.Delete(ctx, r.key)
 }
-func CreateSingletonCache[T any](client ICommonCache, expiration time.Duration, key string) ISingletonCache[T] {
+
+func (r *cacheSingleton[T]) TTL(ctx context.Context) (time.Duration, error) {
+	return r.base.TTL(ctx, r.key)
+}
+
+func (r *cacheSingleton[T]) Expire(ctx context.Context, expiration time.Duration) (bool, error) {
+	return r.base.Expire(ctx, r.key, expiration)
+}
+
+func (r *cacheSingleton[T]) ExpireAt(ctx context.Context, t time.Time) (bool, error) {
+	return r.base.ExpireAt(ctx, r.key, t)
+}
+
+func (r *cacheSingleton[T]) Persist(ctx context.Context) (bool, error) {
+	return r.base.Persist(ctx, r.key)
+}
+
+func (r *cacheSingleton[T]) Exists(ctx context.Context) (bool, error) {
+	n, err := r.base.Exists(ctx, r.key)
+	return n > 0, err
+}
+
+// CreateSingletonCache 没有 GetOrLoad, 忽略 WithRefreshAhead
+func CreateSingletonCache[T any](client ICommonCache, expiration time.Duration, key string, opts ...Option) ISingletonCache[T] {
 	return &cacheSingleton[T]{
-		base: CreateKvCache[T, string](client, expiration, func(k string) string {
+		base: CreateKvCacheWithOptions[T, string](client, expiration, func(k string) string {
 			return k
-		}),
+		}, opts...),
 		key: key,
 	}
 }
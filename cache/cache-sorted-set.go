@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+type ScoredMember[M any] struct {
+	Member M
+	Score  float64
+}
+
+// ISortedSetCache 有序集合缓存, 成员通过codec编码, 编码结果相同的成员视为同一个成员, 因此codec的编码结果必须是确定的
+type ISortedSetCache[M any] interface {
+	IncrScore(ctx context.Context, m M, delta float64) (float64, error)
+	SetScore(ctx context.Context, m M, score float64) error
+	Score(ctx context.Context, m M) (float64, error)
+	// Rank 按分数从低到高的排名, RevRank 按分数从高到低的排名, 从0开始, 成员不存在时返回 ErrNotFound
+	Rank(ctx context.Context, m M) (int64, error)
+	RevRank(ctx context.Context, m M) (int64, error)
+	// RevRange 按分数从高到低读取从 offset 开始的 limit 个成员, limit 小于等于0时读取到末尾
+	RevRange(ctx context.Context, offset, limit int64) ([]ScoredMember[M], error)
+	// RangeByScore 按分数从低到高读取 [min, max] 之间的成员, 可以使用 math.Inf 表示不限制
+	RangeByScore(ctx context.Context, min, max float64, offset, limit int64) ([]ScoredMember[M], error)
+	Remove(ctx context.Context, ms ...M) error
+	Delete(ctx context.Context) error
+	IExpiration
+}
+
+type sortedSetCache[M any] struct {
+	client     ICommonCache
+	key        string
+	expiration time.Duration
+	codec      Codec
+}
+
+// CreateSortedSetCache 忽略 WithRefreshAhead
+func CreateSortedSetCache[M any](client ICommonCache, expiration time.Duration, key string, opts ...Option) ISortedSetCache[M] {
+	o := newOptions(opts)
+	return &sortedSetCache[M]{
+		client:     client,
+		key:        key,
+		expiration: expiration,
+		codec:      o.codec,
+	}
+}
+
+func (r *sortedSetCache[M]) IncrScore(ctx context.Context, m M, delta float64) (float64, error) {
+	member, err := encode(r.codec, m)
+	if err != nil {
+		return 0, err
+	}
+	return r.client.ZIncrBy(ctx, r.key, member, delta, r.expiration)
+}
+
+func (r *sortedSetCache[M]) SetScore(ctx context.Context, m M, score float64) error {
+	member, err := encode(r.codec, m)
+	if err != nil {
+		return err
+	}
+	return r.client.ZAdd(ctx, r.key, member, score, r.expiration)
+}
+
+func (r *sortedSetCache[M]) Score(ctx context.Context, m M) (float64, error) {
+	member, err := encode(r.codec, m)
+	if err != nil {
+		return 0, err
+	}
+	return r.client.ZScore(ctx, r.key, member)
+}
+
+func (r *sortedSetCache[M]) Rank(ctx context.Context, m M) (int64, error) {
+	return r.rank(ctx, m, false)
+}
+
+func (r *sortedSetCache[M]) RevRank(ctx context.Context, m M) (int64, error) {
+	return r.rank(ctx, m, true)
+}
+
+func (r *sortedSetCache[M]) rank(ctx context.Context, m M, reverse bool) (int64, error) {
+	member, err := encode(r.codec, m)
+	if err != nil {
+		return 0, err
+	}
+	return r.client.ZRank(ctx, r.key, member, reverse)
+}
+
+func (r *sortedSetCache[M]) RevRange(ctx context.Context, offset, limit int64) ([]ScoredMember[M], error) {
+	offset = max(offset, 0)
+	stop := int64(-1)
+	if limit > 0 {
+		stop = offset + limit - 1
+	}
+	members, err := r.client.ZRange(ctx, r.key, offset, stop, true)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeAll(members)
+}
+
+func (r *sortedSetCache[M]) RangeByScore(ctx context.Context, min, max float64, offset, limit int64) ([]ScoredMember[M], error) {
+	if math.IsNaN(min) || math.IsNaN(max) {
+		return []ScoredMember[M]{}, nil
+	}
+	members, err := r.client.ZRangeByScore(ctx, r.key, min, max, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeAll(members)
+}
+
+func (r *sortedSetCache[M]) Remove(ctx context.Context, ms ...M) error {
+	if len(ms) == 0 {
+		return nil
+	}
+	members := make([][]byte, 0, len(ms))
+	for _, m := range ms {
+		member, err := encode(r.codec, m)
+		if err != nil {
+			return err
+		}
+		members = append(members, member)
+	}
+	return r.client.ZRem(ctx, r.key, members...)
+}
+
+func (r *sortedSetCache[M]) Delete(ctx context.Context) error {
+	return r.client.Del(ctx, r.key)
+}
+
+func (r *sortedSetCache[M]) TTL(ctx context.Context) (time.Duration, error) {
+	return r.client.TTL(ctx, r.key)
+}
+
+func (r *sortedSetCache[M]) Expire(ctx context.Context, expiration time.Duration) (bool, error) {
+	return r.client.Expire(ctx, r.key, expiration)
+}
+
+func (r *sortedSetCache[M]) ExpireAt(ctx context.Context, t time.Time) (bool, error) {
+	return r.client.ExpireAt(ctx, r.key, t)
+}
+
+func (r *sortedSetCache[M]) Persist(ctx context.Context) (bool, error) {
+	return r.client.Persist(ctx, r.key)
+}
+
+func (r *sortedSetCache[M]) Exists(ctx context.Context) (bool, error) {
+	n, err := r.client.Exists(ctx, r.key)
+	return n > 0, err
+}
+
+func (r *sortedSetCache[M]) decodeAll(members []ZMember) ([]ScoredMember[M], error) {
+	rs := make([]ScoredMember[M], 0, len(members))
+	for _, z := range members {
+		m, err := decode[M](r.codec, z.Member)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, ScoredMember[M]{Member: *m, Score: z.Score})
+	}
+	return rs, nil
+}
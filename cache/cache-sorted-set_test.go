@@ -0,0 +1,92 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+func TestSortedSetCache(t *testing.T) {
+	ctx := context.Background()
+	redisCache, _ := cachetest.NewRedisCache(t)
+	memoryCache := cache_memory.NewCommonCache(cache_memory.Option{})
+	defer memoryCache.(io.Closer).Close()
+
+	for name, client := range map[string]cache.ICommonCache{"redis": redisCache, "memory": memoryCache} {
+		t.Run(name, func(t *testing.T) {
+			board := cache.CreateSortedSetCache[user](client, time.Minute, "rank")
+			a, b, c := user{Id: 1, Name: "a"}, user{Id: 2, Name: "b"}, user{Id: 3, Name: "c"}
+
+			_ = board.SetScore(ctx, a, 10)
+			_ = board.SetScore(ctx, b, 20)
+			if score, err := board.IncrScore(ctx, c, 5); err != nil || score != 5 {
+				t.Fatalf("IncrScore on new member: want 5, got %v %v", score, err)
+			}
+			if score, _ := board.IncrScore(ctx, c, 25.5); score != 30.5 {
+				t.Errorf("IncrScore: want 30.5, got %v", score)
+			}
+			if score, err := board.Score(ctx, b); err != nil || score != 20 {
+				t.Errorf("Score: want 20, got %v %v", score, err)
+			}
+			if rank, _ := board.Rank(ctx, a); rank != 0 {
+				t.Errorf("Rank: want 0, got %d", rank)
+			}
+			if rank, _ := board.RevRank(ctx, a); rank != 2 {
+				t.Errorf("RevRank: want 2, got %d", rank)
+			}
+			if _, err := board.Rank(ctx, user{Id: 9}); !errors.Is(err, cache.ErrNotFound) {
+				t.Errorf("Rank of missing member: want ErrNotFound, got %v", err)
+			}
+			if _, err := board.Score(ctx, user{Id: 9}); !errors.Is(err, cache.ErrNotFound) {
+				t.Errorf("Score of missing member: want ErrNotFound, got %v", err)
+			}
+
+			top, err := board.RevRange(ctx, 0, 2)
+			if err != nil || len(top) != 2 || top[0].Member != c || top[1].Member != b || top[0].Score != 30.5 {
+				t.Errorf("RevRange 0 2: want [c b], got %v %v", top, err)
+			}
+			if all, _ := board.RevRange(ctx, 1, 0); len(all) != 2 || all[1].Member != a {
+				t.Errorf("RevRange 1 0: want [b a], got %v", all)
+			}
+
+			ranged, err := board.RangeByScore(ctx, 10, 20, 0, 0)
+			if err != nil || fmt.Sprint(names(ranged)) != "[a b]" {
+				t.Errorf("RangeByScore 10 20: want [a b], got %v %v", ranged, err)
+			}
+			if ranged, _ := board.RangeByScore(ctx, math.Inf(-1), math.Inf(1), 1, 1); fmt.Sprint(names(ranged)) != "[b]" {
+				t.Errorf("RangeByScore with offset: want [b], got %v", ranged)
+			}
+			if ranged, err := board.RangeByScore(ctx, math.NaN(), 1, 0, 0); err != nil || len(ranged) != 0 {
+				t.Errorf("RangeByScore NaN: want empty, got %v %v", ranged, err)
+			}
+			if ttl, _ := board.TTL(ctx); ttl <= 0 || ttl > time.Minute {
+				t.Errorf("writes should set expiration, got %s", ttl)
+			}
+
+			_ = board.Remove(ctx, a, b)
+			if all, _ := board.RevRange(ctx, 0, 0); fmt.Sprint(names(all)) != "[c]" {
+				t.Errorf("after Remove: want [c], got %v", all)
+			}
+			_ = board.Delete(ctx)
+			if ok, _ := board.Exists(ctx); ok {
+				t.Error("Delete should remove the set")
+			}
+		})
+	}
+}
+
+func names(members []cache.ScoredMember[user]) []string {
+	rs := make([]string, 0, len(members))
+	for _, m := range members {
+		rs = append(rs, m.Member.Name)
+	}
+	return rs
+}
@@ -2,70 +2,469 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math/rand"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 const (
 	defaultExpiration = time.Minute
+
+	loadLockExpiration = time.Second * 10
+	loadWaitTimeout    = time.Second * 3
+	loadWaitInterval   = time.Millisecond * 50
 )
 
+// Loader 缓存未命中时从数据源加载数据, 数据不存在时返回nil或 ErrNotFound
+type Loader[T any, K comparable] func(ctx context.Context, k K) (*T, error)
+
+type lookupObserverKey struct{}
+
+// WithLookupObserver 返回的ctx传给 IKVCache.GetOrLoad 时, 读取缓存后以是否命中调用一次 fn
+// 需要回源(包括等待其他调用的加载结果和熔断时直接回源)计为未命中, 读取缓存失败时不调用
+// fn 在调用 GetOrLoad 的goroutine中执行, 不会传递给 loader 收到的ctx
+func WithLookupObserver(ctx context.Context, fn func(hit bool)) context.Context {
+	return context.WithValue(ctx, lookupObserverKey{}, fn)
+}
+
+// takeLookupObserver 取出ctx中的观察者, 返回的ctx不再携带观察者, 避免loader中嵌套的 GetOrLoad 重复通知
+func takeLookupObserver(ctx context.Context) (context.Context, func(hit bool)) {
+	fn, _ := ctx.Value(lookupObserverKey{}).(func(hit bool))
+	if fn == nil {
+		return ctx, func(bool) {}
+	}
+	return context.WithValue(ctx, lookupObserverKey{}, nil), fn
+}
+
 type IKVCache[T any, K comparable] interface {
 	Get(ctx context.Context, k K) (*T, error)
 	Set(ctx context.Context, k K, t *T) error
+	// SetWithTags 写入并将key加入标签集合, 之后可以通过 InvalidateTags 删除
+	SetWithTags(ctx context.Context, k K, t *T, tags ...string) error
 	Delete(ctx context.Context, keys ...K) error
+	// GetMany 批量读取, 返回命中的值和未命中的key
+	GetMany(ctx context.Context, keys ...K) (map[K]*T, []K, error)
+	SetMany(ctx context.Context, values map[K]*T) error
+	// GetOrLoad 读取缓存, 未命中时调用loader加载并写回缓存, loader 返回nil或 ErrNotFound 表示数据不存在, 此时返回 ErrNotFound
+	// 同一进程内对同一个key的并发未命中只会调用一次loader, 多个进程之间通过SetNX保证只有一个进程回源
+	// 开启 WithRefreshAhead 时, 命中即将过期的值会在后台刷新
+	// 缓存熔断(ErrCircuitOpen)时直接调用loader, 不写回缓存
+	GetOrLoad(ctx context.Context, k K, loader Loader[T, K]) (*T, error)
+	IKeyExpiration[K]
 }
 type kvCache[T any, K comparable] struct {
 	client        ICommonCache
 	formatHandler func(K) string
-	expiration    time.Duration
+	codec         Codec
+	flight        flightGroup[T]
+	expirationPolicy
+
+	negativeExpiration time.Duration
+	refreshAhead       float64
+	refreshing         sync.Map
+	generation         string
+}
+
+// key 生成缓存key, 开启 WithGeneration 时key中包含分组当前的代数
+func (r *kvCache[T, K]) key(ctx context.Context, k K) (string, error) {
+	keys, err := r.keys(ctx, k)
+	if err != nil {
+		return "", err
+	}
+	return keys[0], nil
+}
+
+func (r *kvCache[T, K]) keys(ctx context.Context, ks ...K) ([]string, error) {
+	keys := make([]string, 0, len(ks))
+	if r.generation == "" {
+		for _, k := range ks {
+			keys = append(keys, r.formatHandler(k))
+		}
+		return keys, nil
+	}
+	gen, err := generation(ctx, r.client, r.generation)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range ks {
+		keys = append(keys, fmt.Sprint(r.generation, ":", gen, ":", r.formatHandler(k)))
+	}
+	return keys, nil
 }
 
 func (r *kvCache[T, K]) Get(ctx context.Context, k K) (*T, error) {
-	kv := r.formatHandler(k)
+	kv, err := r.key(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	return r.get(ctx, kv)
+}
 
+func (r *kvCache[T, K]) get(ctx context.Context, kv string) (*T, error) {
 	bytes, err := r.client.Get(ctx, kv)
 	if err != nil {
 		return nil, err
 	}
+	if isTombstone(bytes) {
+		return nil, errTombstone
+	}
 
-	return decode[T](bytes)
+	t, err := decode[T](r.codec, bytes)
+	if err != nil {
+		return nil, err
+	}
+	r.touch(ctx, r.client, kv)
+	return t, nil
 
 }
 func (r *kvCache[T, K]) Set(ctx context.Context, k K, t *T) error {
+	kv, err := r.key(ctx, k)
+	if err != nil {
+		return err
+	}
+	return r.set(ctx, kv, t, r.ttl())
+}
+
+func (r *kvCache[T, K]) SetWithTags(ctx context.Context, k K, t *T, tags ...string) error {
+	kv, err := r.key(ctx, k)
+	if err != nil {
+		return err
+	}
+	ttl := r.ttl()
+	// 先记录标签再写入, 写入失败时标签中多出的key不影响失效
+	if err := addTags(ctx, r.client, kv, ttl, tags); err != nil {
+		return err
+	}
+	return r.set(ctx, kv, t, ttl)
+}
 
-	kv := r.formatHandler(k)
+func (r *kvCache[T, K]) set(ctx context.Context, kv string, t *T, ttl time.Duration) error {
+	bytes, err := encode(r.codec, t)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, kv, bytes, ttl)
+}
 
-	bytes, err := encode(t)
+func (r *kvCache[T, K]) GetOrLoad(ctx context.Context, k K, loader Loader[T, K]) (*T, error) {
+	ctx, observe := takeLookupObserver(ctx)
+	kv, err := r.key(ctx, k)
+	if errors.Is(err, ErrCircuitOpen) {
+		observe(false)
+		return r.loadWithoutCache(ctx, k, loader)
+	}
 	if err != nil {
+		return nil, err
+	}
+	t, err := r.get(ctx, kv)
+	if err == nil {
+		observe(true)
+		r.refreshIfExpiring(ctx, k, kv, loader)
+		return t, nil
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		observe(false)
+		return r.loadWithoutCache(ctx, k, loader)
+	}
+	if errors.Is(err, errTombstone) {
+		// 空值标记是缓存的"不存在"结果
+		observe(true)
+		return nil, err
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	observe(false)
+	// 加载时间不超过加载锁的过期时间, 超时后其他进程可能已经开始回源
+	return r.flight.do(ctx, kv, loadLockExpiration, func(ctx context.Context) (*T, error) {
+		return r.load(ctx, k, kv, loader)
+	})
+}
+
+// refreshIfExpiring 剩余过期时间进入刷新窗口时启动后台刷新, 同一进程内同一个key同时只有一个刷新任务
+func (r *kvCache[T, K]) refreshIfExpiring(ctx context.Context, k K, kv string, loader Loader[T, K]) {
+	if r.refreshAhead <= 0 || r.expiration <= 0 {
+		return
+	}
+	ttl, err := r.client.TTL(ctx, kv)
+	if err != nil || ttl < 0 || ttl > time.Duration(float64(r.expiration)*r.refreshAhead) {
+		return
+	}
+	if _, refreshing := r.refreshing.LoadOrStore(kv, struct{}{}); refreshing {
+		return
+	}
+	go func() {
+		defer r.refreshing.Delete(kv)
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadLockExpiration)
+		defer cancel()
+		if err := r.refresh(ctx, k, kv, loader); err != nil {
+			log.Printf("refresh cache %s error:%s", kv, err.Error())
+		}
+	}()
+}
+
+// refresh 与回源共用加载锁, 其他进程正在加载或刷新时跳过
+func (r *kvCache[T, K]) refresh(ctx context.Context, k K, kv string, loader Loader[T, K]) error {
+	lockKey := fmt.Sprint(kv, ":loading")
+	token := uuid.NewString()
+	locked, err := r.client.SetNX(ctx, lockKey, token, loadLockExpiration)
+	if err != nil || !locked {
 		return err
 	}
+	defer r.unlockLoad(ctx, lockKey, token)
+
+	t, err := loader(ctx, k)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if t == nil {
+		if r.negativeExpiration > 0 {
+			return r.client.Set(ctx, kv, tombstone, r.negativeExpiration)
+		}
+		return r.client.Del(ctx, kv)
+	}
+	return r.set(ctx, kv, t, r.ttl())
+}
+
+func (r *kvCache[T, K]) load(ctx context.Context, k K, kv string, loader Loader[T, K]) (*T, error) {
+	lockKey := fmt.Sprint(kv, ":loading")
+	token := uuid.NewString()
+	locked, err := r.client.SetNX(ctx, lockKey, token, loadLockExpiration)
+	if errors.Is(err, ErrCircuitOpen) {
+		return r.loadWithoutCache(ctx, k, loader)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		// 其他进程正在回源, 等待其写回缓存, 超时后自行加载
+		if t, done, err := r.waitLoaded(ctx, kv); done {
+			return t, err
+		}
+	} else {
+		defer r.unlockLoad(ctx, lockKey, token)
+	}
 
-	return r.client.Set(ctx, kv, bytes, r.expiration)
+	t, err := loader(ctx, k)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if t == nil {
+		if r.negativeExpiration > 0 {
+			if err := r.client.Set(ctx, kv, tombstone, r.negativeExpiration); err != nil && !errors.Is(err, ErrCircuitOpen) {
+				return nil, err
+			}
+		}
+		return nil, ErrNotFound
+	}
+	if err := r.set(ctx, kv, t, r.ttl()); err != nil && !errors.Is(err, ErrCircuitOpen) {
+		return nil, err
+	}
+	return t, nil
+}
+
+// loadWithoutCache 缓存熔断期间直接回源, 不写回缓存
+func (r *kvCache[T, K]) loadWithoutCache(ctx context.Context, k K, loader Loader[T, K]) (*T, error) {
+	t, err := loader(ctx, k)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+func (r *kvCache[T, K]) waitLoaded(ctx context.Context, kv string) (*T, bool, error) {
+	timer := time.NewTimer(loadWaitTimeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(loadWaitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false, nil
+		case <-timer.C:
+			return nil, false, nil
+		case <-ticker.C:
+			t, err := r.get(ctx, kv)
+			if err == nil {
+				return t, true, nil
+			}
+			if errors.Is(err, errTombstone) {
+				return nil, true, ErrNotFound
+			}
+			if !errors.Is(err, ErrNotFound) {
+				return nil, false, nil
+			}
+		}
+	}
+}
+
+func (r *kvCache[T, K]) unlockLoad(ctx context.Context, lockKey, token string) {
+	// 锁可能已过期并被其他进程持有, 只删除自己持有的锁
+	_, _ = r.client.CompareAndDelete(ctx, lockKey, []byte(token))
 }
 
 func (r *kvCache[T, K]) Delete(ctx context.Context, ks ...K) error {
-	for _, k := range ks {
-		key := r.formatHandler(k)
-		if err := r.client.Del(ctx, key); err != nil {
-			return err
+	if len(ks) == 0 {
+		return nil
+	}
+	keys, err := r.keys(ctx, ks...)
+	if err != nil {
+		return err
+	}
+	return r.client.Del(ctx, keys...)
+}
+
+func (r *kvCache[T, K]) TTL(ctx context.Context, k K) (time.Duration, error) {
+	kv, err := r.key(ctx, k)
+	if err != nil {
+		return 0, err
+	}
+	return r.client.TTL(ctx, kv)
+}
+
+func (r *kvCache[T, K]) Expire(ctx context.Context, k K, expiration time.Duration) (bool, error) {
+	kv, err := r.key(ctx, k)
+	if err != nil {
+		return false, err
+	}
+	return r.client.Expire(ctx, kv, expiration)
+}
+
+func (r *kvCache[T, K]) ExpireAt(ctx context.Context, k K, t time.Time) (bool, error) {
+	kv, err := r.key(ctx, k)
+	if err != nil {
+		return false, err
+	}
+	return r.client.ExpireAt(ctx, kv, t)
+}
+
+func (r *kvCache[T, K]) Persist(ctx context.Context, k K) (bool, error) {
+	kv, err := r.key(ctx, k)
+	if err != nil {
+		return false, err
+	}
+	return r.client.Persist(ctx, kv)
+}
+
+func (r *kvCache[T, K]) Exists(ctx context.Context, ks ...K) (int64, error) {
+	if len(ks) == 0 {
+		return 0, nil
+	}
+	keys, err := r.keys(ctx, ks...)
+	if err != nil {
+		return 0, err
+	}
+	return r.client.Exists(ctx, keys...)
+}
+
+func (r *kvCache[T, K]) GetMany(ctx context.Context, ks ...K) (map[K]*T, []K, error) {
+	if len(ks) == 0 {
+		return map[K]*T{}, nil, nil
+	}
+	keys, err := r.keys(ctx, ks...)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err := r.client.MGet(ctx, keys...)
+	if err != nil {
+		return nil, nil, err
+	}
+	rs := make(map[K]*T, len(ks))
+	missing := make([]K, 0)
+	hits := make([]string, 0, len(ks))
+	for i, k := range ks {
+		if values[i] == nil || isTombstone(values[i]) {
+			missing = append(missing, k)
+			continue
+		}
+		t, err := decode[T](r.codec, values[i])
+		if err != nil {
+			return nil, nil, err
 		}
+		rs[k] = t
+		hits = append(hits, keys[i])
+	}
+	r.touch(ctx, r.client, hits...)
+	return rs, missing, nil
+}
 
+func (r *kvCache[T, K]) SetMany(ctx context.Context, values map[K]*T) error {
+	if len(values) == 0 {
+		return nil
+	}
+	ks := make([]K, 0, len(values))
+	for k := range values {
+		ks = append(ks, k)
+	}
+	keys, err := r.keys(ctx, ks...)
+	if err != nil {
+		return err
+	}
+	kvs := make(map[string][]byte, len(values))
+	for i, k := range ks {
+		bytes, err := encode(r.codec, values[k])
+		if err != nil {
+			return err
+		}
+		kvs[keys[i]] = bytes
+	}
+	if r.jitter <= 0 || r.expiration <= 0 {
+		return r.client.MSet(ctx, kvs, r.expiration)
+	}
+	// 按随机分组写入, 使同一批写入的key使用不同的过期时间
+	buckets := make([]map[string][]byte, jitterBuckets)
+	for key, bytes := range kvs {
+		i := rand.Intn(jitterBuckets)
+		if buckets[i] == nil {
+			buckets[i] = make(map[string][]byte)
+		}
+		buckets[i][key] = bytes
+	}
+	for _, bucket := range buckets {
+		if len(bucket) == 0 {
+			continue
+		}
+		if err := r.client.MSet(ctx, bucket, r.ttl()); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 func CreateKvCache[T any, K comparable](client ICommonCache, expiration time.Duration, format ...func(k K) string) IKVCache[T, K] {
 
+	if len(format) > 0 {
+		return CreateKvCacheWithOptions[T, K](client, expiration, format[0])
+	}
+	return CreateKvCacheWithOptions[T, K](client, expiration, nil)
+}
+
+// CreateKvCacheWithOptions format 为空时使用 fmt.Sprint 生成key
+func CreateKvCacheWithOptions[T any, K comparable](client ICommonCache, expiration time.Duration, format func(k K) string, opts ...Option) IKVCache[T, K] {
+
 	if expiration == 0 {
 		expiration = defaultExpiration
 	}
+	o := newOptions(opts)
 	r := &kvCache[T, K]{
-		expiration: expiration,
-		client:     client,
+		client:           client,
+		codec:            o.codec,
+		expirationPolicy: newExpirationPolicy(expiration, o),
+
+		negativeExpiration: o.negativeExpiration,
+		refreshAhead:       o.refreshAhead,
+		generation:         o.generation,
 	}
 
-	if len(format) > 0 {
-		r.formatHandler = format[0]
+	if format != nil {
+		r.formatHandler = format
 	} else {
 		r.formatHandler = func(k K) string {
 			return fmt.Sprint(k)
@@ -0,0 +1,36 @@
+package auto_yaml
+
+import (
+	"context"
+
+	"github.com/mengri/utils/autowire-v2"
+	"github.com/mengri/utils/cftool"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+)
+
+func init() {
+	cftool.Register[MemoryConfig]("memory_cache")
+
+	autowire.Auto(func() cache.ICommonCache {
+		return &memoryInit{}
+	})
+}
+
+type memoryInit struct {
+	cache.ICommonCache
+	conf *MemoryConfig `autowired:""`
+}
+
+func (m *memoryInit) Health(ctx context.Context) error {
+	return nil
+}
+
+func (m *memoryInit) OnPreComplete() {
+	m.ICommonCache = cache_memory.NewCommonCache(cache_memory.Option{
+		Capacity:      m.conf.Capacity,
+		SweepInterval: m.conf.SweepInterval,
+		Namespace:     m.conf.Prefix,
+	})
+}
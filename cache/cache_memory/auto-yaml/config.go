@@ -0,0 +1,9 @@
+package auto_yaml
+
+import "time"
+
+type MemoryConfig struct {
+	Capacity      int           `yaml:"capacity"`
+	SweepInterval time.Duration `yaml:"sweep_interval"`
+	Prefix        string        `yaml:"prefix"`
+}
@@ -0,0 +1,22 @@
+package auto_yaml
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// cftool 使用 yaml.v3 解码配置, 时间与 redis 配置一样使用 5m、30s 等格式
+func TestMemoryConfigYAML(t *testing.T) {
+	conf := new(MemoryConfig)
+	if err := yaml.Unmarshal([]byte("capacity: 1000\nsweep_interval: 30s\nprefix: app\n"), conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Capacity != 1000 || conf.SweepInterval != 30*time.Second || conf.Prefix != "app" {
+		t.Errorf("unexpected config %+v", conf)
+	}
+	if err := yaml.Unmarshal([]byte("sweep_interval: soon\n"), new(MemoryConfig)); err == nil {
+		t.Error("invalid sweep_interval should be rejected")
+	}
+}
@@ -0,0 +1,458 @@
+package cache_memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/health"
+)
+
+const (
+	defaultCapacity      = 10000
+	defaultSweepInterval = time.Minute
+)
+
+var (
+	_ cache.ICommonCache = (*commonCache)(nil)
+	_ health.IHealth     = (*commonCache)(nil)
+
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+)
+
+type Option struct {
+	// Capacity 最大条目数, 超出后按LRU淘汰, 小于0表示不限制
+	Capacity      int
+	SweepInterval time.Duration
+	Namespace     string
+}
+
+type commonCache struct {
+	store     *memoryStore
+	keyPrefix string
+}
+
+// NewCommonCache 创建进程内缓存, 返回值实现了 io.Closer, 关闭后停止后台过期清理
+func NewCommonCache(opt Option) cache.ICommonCache {
+	if opt.Capacity == 0 {
+		opt.Capacity = defaultCapacity
+	}
+	if opt.SweepInterval <= 0 {
+		opt.SweepInterval = defaultSweepInterval
+	}
+	namespace := opt.Namespace
+	if namespace == "" {
+		namespace = "apinto"
+	}
+	namespace = fmt.Sprint(strings.Trim(namespace, ":"), ":")
+	return &commonCache{
+		store:     newMemoryStore(opt.Capacity, opt.SweepInterval),
+		keyPrefix: namespace,
+	}
+}
+
+func (c *commonCache) Close() error {
+	c.store.close()
+	return nil
+}
+
+// Health 进程内缓存始终可用
+func (c *commonCache) Health(ctx context.Context) error {
+	return nil
+}
+
+func (c *commonCache) Clone() cache.ICommonCache {
+	return &commonCache{
+		store:     c.store,
+		keyPrefix: c.keyPrefix,
+	}
+}
+
+func (c *commonCache) key(v string) string {
+	return fmt.Sprint(c.keyPrefix, v)
+}
+
+func (c *commonCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(c.key(key), time.Now())
+	if e == nil {
+		return nil, cache.ErrNotFound
+	}
+	if e.kind != kindString {
+		return nil, ErrWrongType
+	}
+	return cloneBytes(e.value), nil
+}
+
+func (c *commonCache) GetInt(ctx context.Context, key string) (int64, error) {
+	bytes, err := c.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(bytes), 10, 64)
+}
+
+func (c *commonCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	now := time.Now()
+	e := &entry{key: c.key(key), value: cloneBytes(val)}
+	e.setExpiration(now, expiration)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	c.store.put(e)
+	return nil
+}
+
+func (c *commonCache) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	values := make([][]byte, len(keys))
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	for i, key := range keys {
+		e := c.store.get(c.key(key), now)
+		if e == nil || e.kind != kindString {
+			continue
+		}
+		values[i] = cloneBytes(e.value)
+	}
+	return values, nil
+}
+
+func (c *commonCache) MSet(ctx context.Context, values map[string][]byte, expiration time.Duration) error {
+	now := time.Now()
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	for key, val := range values {
+		e := &entry{key: c.key(key), value: cloneBytes(val)}
+		e.setExpiration(now, expiration)
+		c.store.put(e)
+	}
+	return nil
+}
+
+func (c *commonCache) Del(ctx context.Context, keys ...string) error {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	for _, key := range keys {
+		c.store.remove(c.key(key))
+	}
+	return nil
+}
+
+func (c *commonCache) Incr(ctx context.Context, key string, expiration time.Duration) error {
+	_, err := c.IncrByEx(ctx, key, 1, expiration, true)
+	return err
+}
+
+func (c *commonCache) IncrBy(ctx context.Context, key string, val int64, expiration time.Duration) error {
+	_, err := c.IncrByEx(ctx, key, val, expiration, true)
+	return err
+}
+
+func (c *commonCache) IncrByEx(ctx context.Context, key string, val int64, expiration time.Duration, sliding bool) (int64, error) {
+	now := time.Now()
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, now)
+	if e == nil {
+		e = &entry{key: redisKey}
+	} else if e.kind != kindString {
+		return 0, ErrWrongType
+	}
+	var current int64
+	if len(e.value) > 0 {
+		v, err := strconv.ParseInt(string(e.value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		current = v
+	}
+	current += val
+	n := &entry{key: redisKey, value: []byte(strconv.FormatInt(current, 10)), expireAt: e.expireAt}
+	if sliding || n.expireAt.IsZero() {
+		n.setExpiration(now, expiration)
+	}
+	c.store.put(n)
+	return current, nil
+}
+
+func (c *commonCache) HMSet(ctx context.Context, key string, value map[string][]byte, expiration time.Duration) error {
+	now := time.Now()
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, now)
+	if e != nil && e.kind != kindHash {
+		return ErrWrongType
+	}
+	hash := make(map[string][]byte, len(value))
+	n := &entry{key: redisKey, kind: kindHash, hash: hash}
+	if e != nil {
+		for f, v := range e.hash {
+			hash[f] = v
+		}
+		n.expireAt = e.expireAt
+	}
+	for f, v := range value {
+		hash[f] = cloneBytes(v)
+	}
+	n.setExpiration(now, expiration)
+	c.store.put(n)
+	return nil
+}
+
+func (c *commonCache) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	v, err := c.HGetAllBytes(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	rs := make(map[string]string, len(v))
+	for f, fv := range v {
+		rs[f] = string(fv)
+	}
+	return rs, nil
+}
+
+func (c *commonCache) HGetAllBytes(ctx context.Context, key string) (map[string][]byte, error) {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(c.key(key), time.Now())
+	if e == nil {
+		return map[string][]byte{}, nil
+	}
+	if e.kind != kindHash {
+		return nil, ErrWrongType
+	}
+	rs := make(map[string][]byte, len(e.hash))
+	for f, v := range e.hash {
+		rs[f] = cloneBytes(v)
+	}
+	return rs, nil
+}
+
+func (c *commonCache) HGet(ctx context.Context, key string, field string) ([]byte, error) {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(c.key(key), time.Now())
+	if e == nil {
+		return nil, cache.ErrNotFound
+	}
+	if e.kind != kindHash {
+		return nil, ErrWrongType
+	}
+	v, has := e.hash[field]
+	if !has {
+		return nil, cache.ErrNotFound
+	}
+	return cloneBytes(v), nil
+}
+
+func (c *commonCache) HSet(ctx context.Context, key string, field string, val []byte, expiration time.Duration) error {
+	return c.HMSet(ctx, key, map[string][]byte{field: val}, expiration)
+}
+
+func (c *commonCache) HDel(ctx context.Context, key string, fields ...string) error {
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, time.Now())
+	if e == nil {
+		return nil
+	}
+	if e.kind != kindHash {
+		return ErrWrongType
+	}
+	hash := make(map[string][]byte, len(e.hash))
+	for f, v := range e.hash {
+		hash[f] = v
+	}
+	for _, f := range fields {
+		delete(hash, f)
+	}
+	if len(hash) == 0 {
+		c.store.remove(redisKey)
+		return nil
+	}
+	c.store.put(&entry{key: redisKey, kind: kindHash, hash: hash, expireAt: e.expireAt})
+	return nil
+}
+
+func (c *commonCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	now := time.Now()
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(c.key(key), now)
+	if e == nil {
+		return 0, cache.ErrNotFound
+	}
+	if e.expireAt.IsZero() {
+		return -1, nil
+	}
+	return e.expireAt.Sub(now), nil
+}
+
+func (c *commonCache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	now := time.Now()
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, now)
+	if e == nil {
+		return false, nil
+	}
+	if expiration <= 0 {
+		c.store.remove(redisKey)
+		return true, nil
+	}
+	e.expireAt = now.Add(expiration)
+	return true, nil
+}
+
+func (c *commonCache) MExpire(ctx context.Context, keys []string, expiration time.Duration) error {
+	now := time.Now()
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	for _, key := range keys {
+		redisKey := c.key(key)
+		e := c.store.get(redisKey, now)
+		switch {
+		case e == nil:
+		case expiration <= 0:
+			c.store.remove(redisKey)
+		default:
+			e.expireAt = now.Add(expiration)
+		}
+	}
+	return nil
+}
+
+func (c *commonCache) ExpireAt(ctx context.Context, key string, t time.Time) (bool, error) {
+	now := time.Now()
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, now)
+	if e == nil {
+		return false, nil
+	}
+	if !t.After(now) {
+		c.store.remove(redisKey)
+		return true, nil
+	}
+	e.expireAt = t
+	return true, nil
+}
+
+func (c *commonCache) Persist(ctx context.Context, key string) (bool, error) {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(c.key(key), time.Now())
+	if e == nil || e.expireAt.IsZero() {
+		return false, nil
+	}
+	e.expireAt = time.Time{}
+	return true, nil
+}
+
+func (c *commonCache) Exists(ctx context.Context, keys ...string) (int64, error) {
+	now := time.Now()
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	var n int64
+	for _, key := range keys {
+		if c.store.get(c.key(key), now) != nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (c *commonCache) SetNX(ctx context.Context, key string, val interface{}, expiration time.Duration) (bool, error) {
+	now := time.Now()
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	if c.store.get(redisKey, now) != nil {
+		return false, nil
+	}
+	e := &entry{key: redisKey, value: toBytes(val)}
+	e.setExpiration(now, expiration)
+	c.store.put(e)
+	return true, nil
+}
+
+func (c *commonCache) CompareAndDelete(ctx context.Context, key string, val []byte) (bool, error) {
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, time.Now())
+	if e == nil || e.kind != kindString || !bytes.Equal(e.value, val) {
+		return false, nil
+	}
+	c.store.remove(redisKey)
+	return true, nil
+}
+
+func (c *commonCache) CompareAndExpire(ctx context.Context, key string, val []byte, expiration time.Duration) (bool, error) {
+	now := time.Now()
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, now)
+	if e == nil || e.kind != kindString || !bytes.Equal(e.value, val) {
+		return false, nil
+	}
+	if expiration <= 0 {
+		c.store.remove(redisKey)
+		return true, nil
+	}
+	c.store.put(&entry{key: redisKey, value: e.value, expireAt: now.Add(expiration)})
+	return true, nil
+}
+
+func (c *commonCache) Eval(ctx context.Context, script *cache.Script, keys []string, args ...interface{}) (interface{}, error) {
+	return nil, cache.ErrNotSupported
+}
+
+func cloneBytes(v []byte) []byte {
+	if v == nil {
+		return []byte{}
+	}
+	rs := make([]byte, len(v))
+	copy(rs, v)
+	return rs
+}
+
+func toBytes(val interface{}) []byte {
+	switch v := val.(type) {
+	case nil:
+		return []byte{}
+	case []byte:
+		return cloneBytes(v)
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
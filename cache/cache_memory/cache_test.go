@@ -0,0 +1,20 @@
+package cache_memory_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+func TestCommonCache(t *testing.T) {
+	cachetest.TestCommonCache(t, func(t *testing.T, namespace string) cache.ICommonCache {
+		c := cache_memory.NewCommonCache(cache_memory.Option{Namespace: namespace})
+		t.Cleanup(func() {
+			_ = c.(io.Closer).Close()
+		})
+		return c
+	})
+}
@@ -0,0 +1,158 @@
+package cache_memory
+
+import (
+	"context"
+	"time"
+)
+
+func (c *commonCache) RPush(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) (int64, error) {
+	return c.push(key, 0, expiration, false, vals)
+}
+
+func (c *commonCache) LPush(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) (int64, error) {
+	return c.push(key, 0, expiration, true, vals)
+}
+
+func (c *commonCache) RPushTrim(ctx context.Context, key string, maxLen int64, expiration time.Duration, vals ...[]byte) (int64, error) {
+	return c.push(key, maxLen, expiration, false, vals)
+}
+
+func (c *commonCache) LPushTrim(ctx context.Context, key string, maxLen int64, expiration time.Duration, vals ...[]byte) (int64, error) {
+	return c.push(key, maxLen, expiration, true, vals)
+}
+
+func (c *commonCache) push(key string, maxLen int64, expiration time.Duration, left bool, vals [][]byte) (int64, error) {
+	now := time.Now()
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, now)
+	if e != nil && e.kind != kindList {
+		return 0, ErrWrongType
+	}
+	var old [][]byte
+	n := &entry{key: redisKey, kind: kindList}
+	if e != nil {
+		old = e.list
+		n.expireAt = e.expireAt
+	}
+	if len(vals) == 0 {
+		return int64(len(old)), nil
+	}
+	list := make([][]byte, 0, len(old)+len(vals))
+	if left {
+		// 与Redis一致, LPUSH a b c 后列表为 c b a
+		for i := len(vals) - 1; i >= 0; i-- {
+			list = append(list, cloneBytes(vals[i]))
+		}
+		list = append(list, old...)
+	} else {
+		list = append(list, old...)
+		for _, v := range vals {
+			list = append(list, cloneBytes(v))
+		}
+	}
+	if maxLen > 0 && int64(len(list)) > maxLen {
+		if left {
+			list = list[:maxLen]
+		} else {
+			list = list[int64(len(list))-maxLen:]
+		}
+	}
+	n.list = list
+	n.setExpiration(now, expiration)
+	c.store.put(n)
+	return int64(len(list)), nil
+}
+
+func (c *commonCache) LReplace(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) error {
+	now := time.Now()
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	c.store.remove(redisKey)
+	if len(vals) == 0 {
+		return nil
+	}
+	list := make([][]byte, 0, len(vals))
+	for _, v := range vals {
+		list = append(list, cloneBytes(v))
+	}
+	n := &entry{key: redisKey, kind: kindList, list: list}
+	n.setExpiration(now, expiration)
+	c.store.put(n)
+	return nil
+}
+
+func (c *commonCache) LRange(ctx context.Context, key string, start, stop int64) ([][]byte, error) {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(c.key(key), time.Now())
+	if e == nil {
+		return [][]byte{}, nil
+	}
+	if e.kind != kindList {
+		return nil, ErrWrongType
+	}
+	from, to := listRange(int64(len(e.list)), start, stop)
+	rs := make([][]byte, 0, to-from)
+	for _, v := range e.list[from:to] {
+		rs = append(rs, cloneBytes(v))
+	}
+	return rs, nil
+}
+
+func (c *commonCache) LTrim(ctx context.Context, key string, start, stop int64) error {
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, time.Now())
+	if e == nil {
+		return nil
+	}
+	if e.kind != kindList {
+		return ErrWrongType
+	}
+	from, to := listRange(int64(len(e.list)), start, stop)
+	if from == to {
+		c.store.remove(redisKey)
+		return nil
+	}
+	list := make([][]byte, to-from)
+	copy(list, e.list[from:to])
+	c.store.put(&entry{key: redisKey, kind: kindList, list: list, expireAt: e.expireAt})
+	return nil
+}
+
+func (c *commonCache) LLen(ctx context.Context, key string) (int64, error) {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(c.key(key), time.Now())
+	if e == nil {
+		return 0, nil
+	}
+	if e.kind != kindList {
+		return 0, ErrWrongType
+	}
+	return int64(len(e.list)), nil
+}
+
+// listRange 将Redis风格的闭区间下标转换为切片的左闭右开区间
+func listRange(length, start, stop int64) (int64, int64) {
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return 0, 0
+	}
+	return start, stop + 1
+}
@@ -0,0 +1,55 @@
+package cache_memory
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mengri/utils-store/cache/internal/glob"
+)
+
+// Scan 先在锁内收集匹配的key, 再逐个调用fn, fn 中可以继续操作缓存
+func (c *commonCache) Scan(ctx context.Context, pattern string, fn func(key string) error) error {
+	for _, key := range c.match(pattern) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *commonCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	now := time.Now()
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	var n int64
+	for key, el := range c.store.items {
+		if !strings.HasPrefix(key, c.keyPrefix) || el.Value.expired(now) {
+			continue
+		}
+		if glob.Match(pattern, key[len(c.keyPrefix):]) {
+			c.store.removeElement(el)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (c *commonCache) match(pattern string) []string {
+	now := time.Now()
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	keys := make([]string, 0)
+	for key, el := range c.store.items {
+		if !strings.HasPrefix(key, c.keyPrefix) || el.Value.expired(now) {
+			continue
+		}
+		if k := key[len(c.keyPrefix):]; glob.Match(pattern, k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
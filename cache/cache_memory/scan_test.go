@@ -0,0 +1,69 @@
+package cache_memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	c := NewCommonCache(Option{Namespace: "app"})
+	defer c.(*commonCache).Close()
+	other := NewCommonCache(Option{Namespace: "other"})
+	defer other.(*commonCache).Close()
+
+	for _, key := range []string{"user:1", "user:2", "user:10", "order:1"} {
+		_ = c.Set(ctx, key, []byte("v"), 0)
+		_ = other.Set(ctx, key, []byte("v"), 0)
+	}
+	_ = c.Set(ctx, "user:3", []byte("v"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	scan := func(pattern string) string {
+		keys := make([]string, 0)
+		if err := c.Scan(ctx, pattern, func(key string) error {
+			keys = append(keys, key)
+			return nil
+		}); err != nil {
+			t.Fatalf("Scan %s: %v", pattern, err)
+		}
+		sort.Strings(keys)
+		return fmt.Sprint(keys)
+	}
+	for pattern, want := range map[string]string{
+		"*":         "[order:1 user:1 user:10 user:2]",
+		"user:*":    "[user:1 user:10 user:2]",
+		"user:?":    "[user:1 user:2]",
+		"user:[^1]": "[user:2]",
+		`user\:1`:   "[user:1]",
+	} {
+		if got := scan(pattern); got != want {
+			t.Errorf("Scan %s: want %s, got %s", pattern, want, got)
+		}
+	}
+
+	// fn 中可以继续操作缓存
+	stop := errors.New("stop")
+	err := c.Scan(ctx, "user:*", func(key string) error {
+		_ = c.Del(ctx, key)
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Scan should return the error of fn, got %v", err)
+	}
+
+	n, err := c.DeleteByPattern(ctx, "user:*")
+	if err != nil || n != 2 {
+		t.Errorf("DeleteByPattern: want 2, got %d %v", n, err)
+	}
+	if got := scan("*"); got != "[order:1]" {
+		t.Errorf("after DeleteByPattern: want [order:1], got %s", got)
+	}
+	if n, _ := other.Exists(ctx, "user:1", "user:2", "user:10"); n != 3 {
+		t.Errorf("keys of another namespace should be kept, got %d", n)
+	}
+}
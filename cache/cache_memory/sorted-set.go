@@ -0,0 +1,162 @@
+package cache_memory
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+func (c *commonCache) ZAdd(ctx context.Context, key string, member []byte, score float64, expiration time.Duration) error {
+	_, err := c.zupdate(key, member, expiration, func(float64) float64 {
+		return score
+	})
+	return err
+}
+
+func (c *commonCache) ZIncrBy(ctx context.Context, key string, member []byte, incr float64, expiration time.Duration) (float64, error) {
+	return c.zupdate(key, member, expiration, func(old float64) float64 {
+		return old + incr
+	})
+}
+
+func (c *commonCache) zupdate(key string, member []byte, expiration time.Duration, update func(old float64) float64) (float64, error) {
+	now := time.Now()
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, now)
+	if e != nil && e.kind != kindZSet {
+		return 0, ErrWrongType
+	}
+	zset := make(map[string]float64)
+	n := &entry{key: redisKey, kind: kindZSet, zset: zset}
+	if e != nil {
+		for m, s := range e.zset {
+			zset[m] = s
+		}
+		n.expireAt = e.expireAt
+	}
+	score := update(zset[string(member)])
+	zset[string(member)] = score
+	n.setExpiration(now, expiration)
+	c.store.put(n)
+	return score, nil
+}
+
+func (c *commonCache) ZScore(ctx context.Context, key string, member []byte) (float64, error) {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(c.key(key), time.Now())
+	if e == nil {
+		return 0, cache.ErrNotFound
+	}
+	if e.kind != kindZSet {
+		return 0, ErrWrongType
+	}
+	score, has := e.zset[string(member)]
+	if !has {
+		return 0, cache.ErrNotFound
+	}
+	return score, nil
+}
+
+func (c *commonCache) ZRank(ctx context.Context, key string, member []byte, reverse bool) (int64, error) {
+	members, err := c.zsorted(key, reverse)
+	if err != nil {
+		return 0, err
+	}
+	for i, m := range members {
+		if bytes.Equal(m.Member, member) {
+			return int64(i), nil
+		}
+	}
+	return 0, cache.ErrNotFound
+}
+
+func (c *commonCache) ZRange(ctx context.Context, key string, start, stop int64, reverse bool) ([]cache.ZMember, error) {
+	members, err := c.zsorted(key, reverse)
+	if err != nil {
+		return nil, err
+	}
+	from, to := listRange(int64(len(members)), start, stop)
+	return members[from:to], nil
+}
+
+func (c *commonCache) ZRangeByScore(ctx context.Context, key string, min, max float64, offset, count int64) ([]cache.ZMember, error) {
+	members, err := c.zsorted(key, false)
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]cache.ZMember, 0)
+	for _, m := range members {
+		if m.Score < min || m.Score > max {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		rs = append(rs, m)
+		if count > 0 && int64(len(rs)) >= count {
+			break
+		}
+	}
+	return rs, nil
+}
+
+func (c *commonCache) ZRem(ctx context.Context, key string, members ...[]byte) error {
+	redisKey := c.key(key)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(redisKey, time.Now())
+	if e == nil {
+		return nil
+	}
+	if e.kind != kindZSet {
+		return ErrWrongType
+	}
+	zset := make(map[string]float64, len(e.zset))
+	for m, s := range e.zset {
+		zset[m] = s
+	}
+	for _, m := range members {
+		delete(zset, string(m))
+	}
+	if len(zset) == 0 {
+		c.store.remove(redisKey)
+		return nil
+	}
+	c.store.put(&entry{key: redisKey, kind: kindZSet, zset: zset, expireAt: e.expireAt})
+	return nil
+}
+
+// zsorted 返回按分数排序的成员, 分数相同时按成员的字节序排序, 与Redis一致
+func (c *commonCache) zsorted(key string, reverse bool) ([]cache.ZMember, error) {
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	e := c.store.get(c.key(key), time.Now())
+	if e == nil {
+		return []cache.ZMember{}, nil
+	}
+	if e.kind != kindZSet {
+		return nil, ErrWrongType
+	}
+	members := make([]cache.ZMember, 0, len(e.zset))
+	for m, s := range e.zset {
+		members = append(members, cache.ZMember{Member: []byte(m), Score: s})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		less := members[i].Score < members[j].Score ||
+			(members[i].Score == members[j].Score && bytes.Compare(members[i].Member, members[j].Member) < 0)
+		if reverse {
+			return !less
+		}
+		return less
+	})
+	return members, nil
+}
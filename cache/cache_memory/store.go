@@ -0,0 +1,129 @@
+package cache_memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mengri/utils/list"
+)
+
+type entryKind int
+
+const (
+	kindString entryKind = iota
+	kindHash
+	kindList
+	kindZSet
+)
+
+type entry struct {
+	key      string
+	kind     entryKind
+	value    []byte
+	hash     map[string][]byte
+	list     [][]byte
+	zset     map[string]float64
+	expireAt time.Time
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+func (e *entry) setExpiration(now time.Time, expiration time.Duration) {
+	if expiration > 0 {
+		e.expireAt = now.Add(expiration)
+	}
+}
+
+// memoryStore 带容量上限的LRU存储, 过期的条目在读取时或由后台清理协程移除
+type memoryStore struct {
+	lock     sync.Mutex
+	capacity int
+	items    map[string]*list.Element[*entry]
+	lru      *list.List[*entry]
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newMemoryStore(capacity int, sweepInterval time.Duration) *memoryStore {
+	s := &memoryStore{
+		capacity: capacity,
+		items:    make(map[string]*list.Element[*entry]),
+		lru:      list.New[*entry](),
+		done:     make(chan struct{}),
+	}
+	go s.sweep(sweepInterval)
+	return s
+}
+
+// get 返回未过期的条目并将其标记为最近使用, 调用方需持有锁
+func (s *memoryStore) get(key string, now time.Time) *entry {
+	el, has := s.items[key]
+	if !has {
+		return nil
+	}
+	if el.Value.expired(now) {
+		s.removeElement(el)
+		return nil
+	}
+	s.lru.MoveToFront(el)
+	return el.Value
+}
+
+// put 写入条目, 超出容量时淘汰最久未使用的条目, 调用方需持有锁
+func (s *memoryStore) put(e *entry) {
+	if el, has := s.items[e.key]; has {
+		el.Value = e
+		s.lru.MoveToFront(el)
+		return
+	}
+	s.items[e.key] = s.lru.PushFront(e)
+	for s.capacity > 0 && s.lru.Len() > s.capacity {
+		s.removeElement(s.lru.Back())
+	}
+}
+
+// remove 删除条目, 调用方需持有锁
+func (s *memoryStore) remove(key string) {
+	if el, has := s.items[key]; has {
+		s.removeElement(el)
+	}
+}
+
+func (s *memoryStore) removeElement(el *list.Element[*entry]) {
+	s.lru.Remove(el)
+	delete(s.items, el.Value.key)
+}
+
+func (s *memoryStore) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.removeExpired(now)
+		}
+	}
+}
+
+func (s *memoryStore) removeExpired(now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for el := s.lru.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.expired(now) {
+			s.removeElement(el)
+		}
+		el = next
+	}
+}
+
+func (s *memoryStore) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
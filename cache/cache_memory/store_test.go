@@ -0,0 +1,101 @@
+package cache_memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+func TestLRUEviction(t *testing.T) {
+	ctx := context.Background()
+	c := NewCommonCache(Option{Capacity: 2})
+	defer c.(*commonCache).Close()
+
+	_ = c.Set(ctx, "a", []byte("1"), 0)
+	_ = c.Set(ctx, "b", []byte("2"), 0)
+	// 读取a后b成为最久未使用的条目
+	if _, err := c.Get(ctx, "a"); err != nil {
+		t.Fatalf("Get a: %v", err)
+	}
+	_ = c.Set(ctx, "c", []byte("3"), 0)
+
+	if _, err := c.Get(ctx, "b"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("b should be evicted, got %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := c.Get(ctx, key); err != nil {
+			t.Errorf("Get %s: %v", key, err)
+		}
+	}
+}
+
+func TestUnlimitedCapacity(t *testing.T) {
+	ctx := context.Background()
+	c := NewCommonCache(Option{Capacity: -1})
+	defer c.(*commonCache).Close()
+
+	for i := 0; i < defaultCapacity+10; i++ {
+		_ = c.Set(ctx, fmt.Sprint("k", i), []byte("v"), 0)
+	}
+	if n := c.(*commonCache).store.lru.Len(); n != defaultCapacity+10 {
+		t.Errorf("want %d entries, got %d", defaultCapacity+10, n)
+	}
+}
+
+func TestSweepRemovesExpired(t *testing.T) {
+	ctx := context.Background()
+	c := NewCommonCache(Option{SweepInterval: 10 * time.Millisecond})
+	defer c.(*commonCache).Close()
+
+	_ = c.Set(ctx, "short", []byte("v"), 20*time.Millisecond)
+	_ = c.Set(ctx, "forever", []byte("v"), 0)
+
+	store := c.(*commonCache).store
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.lock.Lock()
+		_, has := store.items[c.(*commonCache).key("short")]
+		n := len(store.items)
+		store.lock.Unlock()
+		if !has {
+			if n != 1 {
+				t.Errorf("want 1 entry left, got %d", n)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired entry was not swept")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCloneSharesStore(t *testing.T) {
+	ctx := context.Background()
+	c := NewCommonCache(Option{Namespace: "clone"})
+	defer c.(*commonCache).Close()
+
+	_ = c.Set(ctx, "k", []byte("v"), 0)
+	v, err := c.Clone().Get(ctx, "k")
+	if err != nil || string(v) != "v" {
+		t.Errorf("Get from clone: want v, got %q %v", v, err)
+	}
+}
+
+func TestWrongType(t *testing.T) {
+	ctx := context.Background()
+	c := NewCommonCache(Option{})
+	defer c.(*commonCache).Close()
+
+	_ = c.HSet(ctx, "h", "f", []byte("v"), 0)
+	if _, err := c.Get(ctx, "h"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Get on hash: want ErrWrongType, got %v", err)
+	}
+	if _, err := c.IncrByEx(ctx, "h", 1, 0, false); !errors.Is(err, ErrWrongType) {
+		t.Errorf("IncrByEx on hash: want ErrWrongType, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package cache_redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -9,6 +10,26 @@ import (
 	redis "github.com/redis/go-redis/v9"
 
 	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/internal/scripts"
+	"github.com/mengri/utils-store/health"
+)
+
+var (
+	compareAndDeleteScript = redis.NewScript(scripts.CompareAndDelete)
+	incrByExScript         = redis.NewScript(scripts.IncrByEx)
+	compareAndExpireScript = redis.NewScript(scripts.CompareAndExpire)
+)
+
+// IRedisClient 暴露底层Redis客户端和key前缀, 用于 ICommonCache 之外的Redis功能(如pub/sub、stream)
+type IRedisClient interface {
+	RedisClient() redis.UniversalClient
+	// Key 返回加上命名空间前缀后的key
+	Key(v string) string
+}
+
+var (
+	_ IRedisClient   = (*commonCache)(nil)
+	_ health.IHealth = (*commonCache)(nil)
 )
 
 type commonCache struct {
@@ -16,6 +37,24 @@ type commonCache struct {
 	keyPrefix string
 }
 
+func (c *commonCache) RedisClient() redis.UniversalClient {
+	return c.client
+}
+
+func (c *commonCache) Key(v string) string {
+	return c.key(v)
+}
+
+// Health 集群模式下ping所有节点, 任意节点不可用都视为不健康
+func (c *commonCache) Health(ctx context.Context) error {
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachShard(ctx, func(ctx context.Context, client *redis.Client) error {
+			return client.Ping(ctx).Err()
+		})
+	}
+	return c.client.Ping(ctx).Err()
+}
+
 func (c *commonCache) Clone() cache.ICommonCache {
 
 	return &commonCache{
@@ -24,17 +63,29 @@ func (c *commonCache) Clone() cache.ICommonCache {
 	}
 }
 
-func newCommonCache(client redis.UniversalClient, namespace string) cache.ICommonCache {
+// NewCommonCache 使用已创建的客户端创建缓存, namespace 为key前缀, 为空时使用 apinto
+func NewCommonCache(client redis.UniversalClient, namespace string) cache.ICommonCache {
+	return newCommonCache(client, namespace)
+}
+
+func newCommonCache(client redis.UniversalClient, prefix string) cache.ICommonCache {
+	return &commonCache{client: client, keyPrefix: namespace(prefix)}
+}
 
-	if namespace == "" {
-		namespace = "apinto"
+// namespace 返回key前缀, 为空时使用 apinto
+func namespace(prefix string) string {
+	if prefix == "" {
+		prefix = "apinto"
 	}
-	namespace = fmt.Sprint(strings.Trim(namespace, ":"), ":")
-	return &commonCache{client: client, keyPrefix: namespace}
+	return fmt.Sprint(strings.Trim(prefix, ":"), ":")
 }
 
 func (c *commonCache) Get(ctx context.Context, key string) ([]byte, error) {
-	return c.client.Get(ctx, c.key(key)).Bytes()
+	v, err := c.client.Get(ctx, c.key(key)).Bytes()
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return v, nil
 }
 
 func (c *commonCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
@@ -42,37 +93,94 @@ func (c *commonCache) Set(ctx context.Context, key string, val []byte, expiratio
 }
 
 func (c *commonCache) Incr(ctx context.Context, key string, expiration time.Duration) error {
-	redisKey := c.key(key)
-	err := c.client.Incr(ctx, redisKey).Err()
-	if err != nil {
-		return err
-	}
-	return c.client.Expire(ctx, redisKey, expiration).Err()
+	_, err := c.IncrByEx(ctx, key, 1, expiration, true)
+	return err
 }
 func (c *commonCache) key(v string) string {
 	return fmt.Sprint(c.keyPrefix, v)
 }
 func (c *commonCache) IncrBy(ctx context.Context, key string, val int64, expiration time.Duration) error {
-	redisKey := c.key(key)
-	err := c.client.IncrBy(ctx, redisKey, val).Err()
-	if err != nil {
-		return err
+	_, err := c.IncrByEx(ctx, key, val, expiration, true)
+	return err
+}
+
+func (c *commonCache) IncrByEx(ctx context.Context, key string, val int64, expiration time.Duration, sliding bool) (int64, error) {
+	mode := 0
+	if sliding {
+		mode = 1
 	}
-	return c.client.Expire(ctx, redisKey, expiration).Err()
+	return incrByExScript.Run(ctx, c.client, []string{c.key(key)}, val, expiration.Milliseconds(), mode).Int64()
 }
 
 func (c *commonCache) GetInt(ctx context.Context, key string) (int64, error) {
 	redisKey := c.key(key)
-	return c.client.Get(ctx, redisKey).Int64()
+	v, err := c.client.Get(ctx, redisKey).Int64()
+	if err != nil {
+		return 0, convertErr(err)
+	}
+	return v, nil
+}
+
+// convertErr 将 redis.Nil 转换为 cache.ErrNotFound
+func convertErr(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return cache.ErrNotFound
+	}
+	return err
 }
 
+// Del 通过pipeline一次性删除, 集群模式下pipeline会按slot分发到对应节点
 func (c *commonCache) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if len(keys) == 1 {
+		return c.client.Del(ctx, c.key(keys[0])).Err()
+	}
+	pipe := c.client.Pipeline()
 	for _, key := range keys {
-		if err := c.client.Del(ctx, c.key(key)).Err(); err != nil {
-			return err
+		pipe.Del(ctx, c.key(key))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *commonCache) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	pipe := c.client.Pipeline()
+	cmds := make([]*redis.StringCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipe.Get(ctx, c.key(key)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	values := make([][]byte, len(keys))
+	for i, cmd := range cmds {
+		v, err := cmd.Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			return nil, err
 		}
+		values[i] = v
 	}
-	return nil
+	return values, nil
+}
+
+func (c *commonCache) MSet(ctx context.Context, values map[string][]byte, expiration time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	pipe := c.client.Pipeline()
+	for key, val := range values {
+		pipe.Set(ctx, c.key(key), val, expiration)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
 func (c *commonCache) HMSet(ctx context.Context, key string, value map[string][]byte, expiration time.Duration) error {
@@ -80,11 +188,16 @@ func (c *commonCache) HMSet(ctx context.Context, key string, value map[string][]
 	for k, val := range value {
 		values = append(values, k, val)
 	}
-	if err := c.client.HMSet(ctx, c.key(key), values...).Err(); err != nil {
-		return err
-	}
-	c.client.Expire(ctx, c.key(key), expiration)
-	return nil
+	redisKey := c.key(key)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey, values...)
+		// EXPIRE 0 会删除key, 不设置过期时间时不能调用
+		if expiration > 0 {
+			pipe.PExpire(ctx, redisKey, expiration)
+		}
+		return nil
+	})
+	return err
 }
 
 func (c *commonCache) HDel(ctx context.Context, key string, fields ...string) error {
@@ -95,6 +208,141 @@ func (c *commonCache) HGetAll(ctx context.Context, key string) (map[string]strin
 	return c.client.HGetAll(ctx, c.key(key)).Result()
 }
 
+func (c *commonCache) HGetAllBytes(ctx context.Context, key string) (map[string][]byte, error) {
+	v, err := c.client.HGetAll(ctx, c.key(key)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return toBytesMap(v), nil
+}
+
+func (c *commonCache) HGet(ctx context.Context, key string, field string) ([]byte, error) {
+	v, err := c.client.HGet(ctx, c.key(key), field).Bytes()
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return v, nil
+}
+
+func (c *commonCache) HSet(ctx context.Context, key string, field string, val []byte, expiration time.Duration) error {
+	redisKey := c.key(key)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey, field, val)
+		if expiration > 0 {
+			pipe.PExpire(ctx, redisKey, expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+func toBytesMap(v map[string]string) map[string][]byte {
+	rs := make(map[string][]byte, len(v))
+	for f, fv := range v {
+		rs[f] = []byte(fv)
+	}
+	return rs
+}
+
+func (c *commonCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := c.client.PTTL(ctx, c.key(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// PTTL 在key不存在时返回-2, 永不过期时返回-1
+	if ttl == -2 {
+		return 0, cache.ErrNotFound
+	}
+	return ttl, nil
+}
+
+func (c *commonCache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	if expiration <= 0 {
+		n, err := c.client.Del(ctx, c.key(key)).Result()
+		return n > 0, err
+	}
+	return c.client.PExpire(ctx, c.key(key), expiration).Result()
+}
+
+func (c *commonCache) MExpire(ctx context.Context, keys []string, expiration time.Duration) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := c.client.Pipeline()
+	for _, key := range keys {
+		if expiration <= 0 {
+			pipe.Del(ctx, c.key(key))
+		} else {
+			pipe.PExpire(ctx, c.key(key), expiration)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *commonCache) ExpireAt(ctx context.Context, key string, t time.Time) (bool, error) {
+	return c.client.PExpireAt(ctx, c.key(key), t).Result()
+}
+
+func (c *commonCache) Persist(ctx context.Context, key string) (bool, error) {
+	return c.client.Persist(ctx, c.key(key)).Result()
+}
+
+// Exists 多个key时逐个查询, 集群模式下key可能分布在不同的slot
+func (c *commonCache) Exists(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if len(keys) == 1 {
+		return c.client.Exists(ctx, c.key(keys[0])).Result()
+	}
+	pipe := c.client.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipe.Exists(ctx, c.key(key)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, cmd := range cmds {
+		n += cmd.Val()
+	}
+	return n, nil
+}
+
 func (c *commonCache) SetNX(ctx context.Context, key string, val interface{}, expiration time.Duration) (bool, error) {
 	return c.client.SetNX(ctx, c.key(key), val, expiration).Result()
 }
+
+func (c *commonCache) CompareAndDelete(ctx context.Context, key string, val []byte) (bool, error) {
+	n, err := compareAndDeleteScript.Run(ctx, c.client, []string{c.key(key)}, val).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (c *commonCache) CompareAndExpire(ctx context.Context, key string, val []byte, expiration time.Duration) (bool, error) {
+	n, err := compareAndExpireScript.Run(ctx, c.client, []string{c.key(key)}, val, expiration.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (c *commonCache) Eval(ctx context.Context, script *cache.Script, keys []string, args ...interface{}) (interface{}, error) {
+	redisKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		redisKeys = append(redisKeys, c.key(key))
+	}
+	cmd := c.client.EvalSha(ctx, script.Hash(), redisKeys, args...)
+	if err := cmd.Err(); err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		cmd = c.client.Eval(ctx, script.Source(), redisKeys, args...)
+	}
+	v, err := cmd.Result()
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return v, nil
+}
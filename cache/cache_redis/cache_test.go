@@ -0,0 +1,44 @@
+package cache_redis_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+	"github.com/mengri/utils-store/cache/cache_redis"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+// 设置了 cachetest.RedisAddrEnv 时同时在真实的Redis上运行, 执行 internal/scripts 中的Lua脚本
+func TestCommonCache(t *testing.T) {
+	clients := scanClients(t)
+	if client, ok := cachetest.ConnectRedis(t); ok {
+		clients["redis-server"] = client
+	}
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			cachetest.TestCommonCache(t, func(t *testing.T, namespace string) cache.ICommonCache {
+				return cache_redis.NewCommonCache(client, namespace)
+			})
+		})
+	}
+}
+
+// 两级缓存的L1需要与L2表现一致, 使用同一套一致性测试
+func TestTwoTierCommonCache(t *testing.T) {
+	for name, client := range scanClients(t) {
+		t.Run(name, func(t *testing.T) {
+			cachetest.TestCommonCache(t, func(t *testing.T, namespace string) cache.ICommonCache {
+				l1 := cache_memory.NewCommonCache(cache_memory.Option{Namespace: namespace})
+				c := cache_redis.NewTwoTierCache(client, namespace, l1, time.Minute)
+				t.Cleanup(func() {
+					_ = c.(io.Closer).Close()
+					_ = l1.(io.Closer).Close()
+				})
+				return c
+			})
+		})
+	}
+}
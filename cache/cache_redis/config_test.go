@@ -0,0 +1,176 @@
+package cache_redis_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mengri/utils-store/cache/cache_redis"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+// cftool 使用 yaml.v3 解码配置
+func TestRedisConfigYAML(t *testing.T) {
+	data := []byte(`
+addr: [127.0.0.1:6379]
+prefix: app
+tls:
+  enable: true
+  ca_file: /etc/redis/ca.pem
+  server_name: redis.internal
+pool_size: 20
+min_idle_conns: 5
+conn_max_idle_time: 5m
+dial_timeout: 2s
+read_timeout: 500ms
+write_timeout: 1s
+max_retries: -1
+replica_read: latency
+`)
+	conf := new(cache_redis.RedisConfig)
+	if err := yaml.Unmarshal(data, conf); err != nil {
+		t.Fatal(err)
+	}
+	opts := conf.Option()
+	if !opts.TLS.Enable || opts.TLS.CAFile != "/etc/redis/ca.pem" || opts.TLS.ServerName != "redis.internal" {
+		t.Errorf("tls: got %+v", opts.TLS)
+	}
+	if opts.PoolSize != 20 || opts.MinIdleConns != 5 || opts.MaxRetries != -1 {
+		t.Errorf("pool: got size %d, min idle %d, retries %d", opts.PoolSize, opts.MinIdleConns, opts.MaxRetries)
+	}
+	if opts.ConnMaxIdleTime != 5*time.Minute || opts.DialTimeout != 2*time.Second ||
+		opts.ReadTimeout != 500*time.Millisecond || opts.WriteTimeout != time.Second {
+		t.Errorf("timeouts: got %+v", opts)
+	}
+	if opts.ReplicaRead != cache_redis.ReplicaReadLatency {
+		t.Errorf("replica read: want latency, got %q", opts.ReplicaRead)
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	s := cachetest.StartServer(t, cachetest.ServerOption{})
+	client, err := cache_redis.NewClient(cache_redis.Option{
+		Addrs:        []string{s.Addr()},
+		PoolSize:     7,
+		MinIdleConns: 2,
+		DialTimeout:  time.Second,
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 3 * time.Second,
+		MaxRetries:   -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	simple, ok := client.(*redis.Client)
+	if !ok {
+		t.Fatalf("single node without cluster mode: want *redis.Client, got %T", client)
+	}
+	// go-redis 将 MaxRetries -1 转换为0(不重试)
+	o := simple.Options()
+	if o.PoolSize != 7 || o.MinIdleConns != 2 || o.DialTimeout != time.Second ||
+		o.ReadTimeout != 2*time.Second || o.WriteTimeout != 3*time.Second || o.MaxRetries != 0 {
+		t.Errorf("options not applied: %+v", o)
+	}
+	if o.ConnMaxIdleTime != cache_redis.DefaultConnMaxIdleTime {
+		t.Errorf("ConnMaxIdleTime: want default %s, got %s", cache_redis.DefaultConnMaxIdleTime, o.ConnMaxIdleTime)
+	}
+}
+
+func TestNewClientReplicaRead(t *testing.T) {
+	client, err := cache_redis.NewClient(cache_redis.Option{Addrs: []string{"127.0.0.1:1", "127.0.0.1:2"}, ReplicaRead: cache_redis.ReplicaReadRandom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok || !cluster.Options().RouteRandomly || !cluster.Options().ReadOnly {
+		t.Errorf("random replica read: want a cluster client routing randomly, got %T", client)
+	}
+
+	failover, err := cache_redis.NewClient(cache_redis.Option{Addrs: []string{"127.0.0.1:1"}, MasterName: "m", ReplicaRead: cache_redis.ReplicaReadLatency})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer failover.Close()
+	if c, ok := failover.(*redis.ClusterClient); !ok || !c.Options().RouteByLatency {
+		t.Errorf("sentinel with replica read: want a failover cluster client routing by latency, got %T", failover)
+	}
+
+	if _, err := cache_redis.NewClient(cache_redis.Option{Addrs: []string{"127.0.0.1:1"}, ReplicaRead: "nearest"}); err == nil {
+		t.Error("unknown replica read mode should be rejected")
+	}
+}
+
+func TestTLSOption(t *testing.T) {
+	if conf, err := (&cache_redis.TLSOption{CAFile: "missing"}).Config(); conf != nil || err != nil {
+		t.Errorf("disabled TLS: want nil, got %v %v", conf, err)
+	}
+
+	dir := t.TempDir()
+	caFile, certFile, keyFile := writeCertificate(t, dir)
+	conf, err := (&cache_redis.TLSOption{Enable: true, CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "redis.internal"}).Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.RootCAs == nil || len(conf.Certificates) != 1 || conf.ServerName != "redis.internal" || conf.InsecureSkipVerify {
+		t.Errorf("TLS config not applied: %+v", conf)
+	}
+
+	if _, err := (&cache_redis.TLSOption{Enable: true, CAFile: filepath.Join(dir, "missing.pem")}).Config(); err == nil {
+		t.Error("missing CA file should be an error")
+	}
+	if _, err := (&cache_redis.TLSOption{Enable: true, CAFile: keyFile}).Config(); err == nil {
+		t.Error("CA file without certificates should be an error")
+	}
+	if _, err := (&cache_redis.TLSOption{Enable: true, CertFile: certFile}).Config(); err == nil {
+		t.Error("client certificate without key should be an error")
+	}
+}
+
+// writeCertificate 生成自签名证书, 同时作为CA和客户端证书使用
+func writeCertificate(t *testing.T, dir string) (caFile, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis.internal"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	write := func(path, typ string, data []byte) {
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: data}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(certFile, "CERTIFICATE", der)
+	write(keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, certFile, keyFile
+}
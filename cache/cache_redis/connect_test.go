@@ -0,0 +1,108 @@
+package cache_redis_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache/cache_redis"
+	"github.com/mengri/utils-store/cache/cachetest"
+	"github.com/mengri/utils-store/health"
+)
+
+// stoppedAddr 返回一个当前没有服务监听的地址
+func stoppedAddr(t *testing.T) string {
+	t.Helper()
+	s := cachetest.StartServer(t, cachetest.ServerOption{})
+	addr := s.Addr()
+	_ = s.Close()
+	return addr
+}
+
+func TestConnectDegradedRequiresMode(t *testing.T) {
+	startup := health.Startup{Attempts: 1, Degraded: true}
+	addr := stoppedAddr(t)
+	if _, err := cache_redis.Connect(context.Background(), cache_redis.Option{Addrs: []string{addr}}, startup); err == nil {
+		t.Fatal("degraded start with one address and no mode should be rejected")
+	}
+
+	client, err := cache_redis.Connect(context.Background(), cache_redis.Option{Addrs: []string{addr}, Mode: cache_redis.ModeSingle}, startup)
+	if err != nil {
+		t.Fatalf("degraded start with explicit mode: %v", err)
+	}
+	defer client.Close()
+	if _, ok := client.(*redis.Client); !ok {
+		t.Errorf("single mode: want *redis.Client, got %T", client)
+	}
+	if _, err := cache_redis.Connect(context.Background(), cache_redis.Option{Addrs: []string{addr}, Mode: cache_redis.ModeSingle}, health.Startup{Attempts: 1}); err == nil {
+		t.Error("without degraded mode the connection error should be returned")
+	}
+}
+
+// 以集群模式降级启动, 节点恢复后客户端可以正常使用
+func TestConnectDegradedClusterRecovers(t *testing.T) {
+	addr := stoppedAddr(t)
+	client, err := cache_redis.Connect(context.Background(), cache_redis.Option{Addrs: []string{addr}, Mode: cache_redis.ModeCluster, MaxRetries: -1},
+		health.Startup{Attempts: 1, Degraded: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, ok := client.(*redis.ClusterClient); !ok {
+		t.Fatalf("cluster mode: want *redis.ClusterClient, got %T", client)
+	}
+	c := cache_redis.NewCommonCache(client, "app")
+	if err := c.(health.IHealth).Health(context.Background()); err == nil {
+		t.Error("Health should fail while redis is down")
+	}
+
+	cachetest.StartServer(t, cachetest.ServerOption{Addr: addr, Cluster: true})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := c.Set(context.Background(), "k", []byte("v"), time.Minute)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("client should recover after redis starts: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := c.(health.IHealth).Health(context.Background()); err != nil {
+		t.Errorf("Health after recovery: %v", err)
+	}
+}
+
+func TestNewClientMode(t *testing.T) {
+	if _, err := cache_redis.NewClient(cache_redis.Option{Addrs: []string{"127.0.0.1:1", "127.0.0.1:2"}, Mode: cache_redis.ModeSingle}); err == nil {
+		t.Error("single mode with several addresses should be rejected")
+	}
+	if _, err := cache_redis.NewClient(cache_redis.Option{Addrs: []string{"127.0.0.1:1"}, Mode: "sharded"}); err == nil {
+		t.Error("unknown mode should be rejected")
+	}
+	s := cachetest.StartServer(t, cachetest.ServerOption{Cluster: true})
+	client, err := cache_redis.NewClient(cache_redis.Option{Addrs: []string{s.Addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, ok := client.(*redis.ClusterClient); !ok {
+		t.Errorf("probing a cluster node: want *redis.ClusterClient, got %T", client)
+	}
+}
+
+func TestRedisInitBeforeComplete(t *testing.T) {
+	r := cache_redis.NewRedisInit(&cache_redis.RedisConfig{Prefix: "app"})
+	if err := r.Health(context.Background()); !errors.Is(err, cache_redis.ErrNotInitialized) {
+		t.Errorf("Health: want cache_redis.ErrNotInitialized, got %v", err)
+	}
+	if c := r.RedisClient(); c != nil {
+		t.Errorf("RedisClient: want nil, got %T", c)
+	}
+	if key := r.Key("k"); key != "app:k" {
+		t.Errorf("Key: want app:k, got %s", key)
+	}
+}
@@ -0,0 +1,24 @@
+package cache_redis
+
+import "github.com/mengri/utils-store/health"
+
+// 以下导出内部实现, 供 cache_redis_test 中的测试使用
+
+const (
+	ScanCount              = scanCount
+	DefaultConnMaxIdleTime = defaultConnMaxIdleTime
+)
+
+var ErrNotInitialized = errNotInitialized
+
+func (c *RedisConfig) Option() Option {
+	return c.option()
+}
+
+// NewRedisInit 创建还没有完成初始化的 redis 缓存
+func NewRedisInit(conf *RedisConfig) interface {
+	IRedisClient
+	health.IHealth
+} {
+	return &redisInit{conf: conf}
+}
@@ -0,0 +1,101 @@
+package cache_redis
+
+import (
+	"context"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+func (c *commonCache) RPush(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) (int64, error) {
+	return c.push(ctx, key, 0, expiration, false, vals)
+}
+
+func (c *commonCache) LPush(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) (int64, error) {
+	return c.push(ctx, key, 0, expiration, true, vals)
+}
+
+func (c *commonCache) RPushTrim(ctx context.Context, key string, maxLen int64, expiration time.Duration, vals ...[]byte) (int64, error) {
+	return c.push(ctx, key, maxLen, expiration, false, vals)
+}
+
+func (c *commonCache) LPushTrim(ctx context.Context, key string, maxLen int64, expiration time.Duration, vals ...[]byte) (int64, error) {
+	return c.push(ctx, key, maxLen, expiration, true, vals)
+}
+
+func (c *commonCache) push(ctx context.Context, key string, maxLen int64, expiration time.Duration, left bool, vals [][]byte) (int64, error) {
+	if len(vals) == 0 {
+		return c.LLen(ctx, key)
+	}
+	redisKey := c.key(key)
+	var pushCmd *redis.IntCmd
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if left {
+			pushCmd = pipe.LPush(ctx, redisKey, listValues(vals)...)
+		} else {
+			pushCmd = pipe.RPush(ctx, redisKey, listValues(vals)...)
+		}
+		if maxLen > 0 {
+			if left {
+				pipe.LTrim(ctx, redisKey, 0, maxLen-1)
+			} else {
+				pipe.LTrim(ctx, redisKey, -maxLen, -1)
+			}
+		}
+		if expiration > 0 {
+			pipe.PExpire(ctx, redisKey, expiration)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if maxLen > 0 {
+		return min(pushCmd.Val(), maxLen), nil
+	}
+	return pushCmd.Val(), nil
+}
+
+func (c *commonCache) LReplace(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) error {
+	redisKey := c.key(key)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, redisKey)
+		if len(vals) == 0 {
+			return nil
+		}
+		pipe.RPush(ctx, redisKey, listValues(vals)...)
+		if expiration > 0 {
+			pipe.PExpire(ctx, redisKey, expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+func listValues(vals [][]byte) []interface{} {
+	values := make([]interface{}, 0, len(vals))
+	for _, v := range vals {
+		values = append(values, v)
+	}
+	return values
+}
+
+func (c *commonCache) LRange(ctx context.Context, key string, start, stop int64) ([][]byte, error) {
+	vs, err := c.client.LRange(ctx, c.key(key), start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	rs := make([][]byte, 0, len(vs))
+	for _, v := range vs {
+		rs = append(rs, []byte(v))
+	}
+	return rs, nil
+}
+
+func (c *commonCache) LTrim(ctx context.Context, key string, start, stop int64) error {
+	return c.client.LTrim(ctx, c.key(key), start, stop).Err()
+}
+
+func (c *commonCache) LLen(ctx context.Context, key string) (int64, error) {
+	return c.client.LLen(ctx, c.key(key)).Result()
+}
@@ -2,12 +2,18 @@ package cache_redis
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/mengri/utils/autowire-v2"
 	"github.com/mengri/utils/cftool"
 	"log"
 	"time"
 
+	redis "github.com/redis/go-redis/v9"
+
 	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+	"github.com/mengri/utils-store/health"
 )
 
 type RedisConfig struct {
@@ -17,6 +23,56 @@ type RedisConfig struct {
 	Prefix     string   `yaml:"prefix"`
 	MasterName string   `yaml:"master_name"`
 	DB         int      `yaml:"db"`
+	// Mode 可选 single/cluster, 为空时自动探测, 开启 startup.degraded 且只有一个地址时必须配置
+	Mode Mode `yaml:"mode"`
+
+	TLS TLSOption `yaml:"tls"`
+
+	PoolSize        int           `yaml:"pool_size"`
+	MinIdleConns    int           `yaml:"min_idle_conns"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	DialTimeout     time.Duration `yaml:"dial_timeout"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	MaxRetries      int           `yaml:"max_retries"`
+	MinRetryBackoff time.Duration `yaml:"min_retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
+	// ReplicaRead 集群和哨兵模式下只读命令的路由方式, 可选 random/latency, 为空时只访问主节点
+	ReplicaRead ReplicaRead `yaml:"replica_read"`
+
+	LocalCache LocalCacheConfig `yaml:"local_cache"`
+
+	// Startup 启动时的连接重试和降级配置, 不配置时只尝试一次, 失败后退出
+	Startup health.Startup `yaml:"startup"`
+}
+
+func (c *RedisConfig) option() Option {
+	return Option{
+		Addrs:           c.Addr,
+		MasterName:      c.MasterName,
+		Mode:            c.Mode,
+		Username:        c.UserName,
+		Password:        c.Password,
+		DB:              c.DB,
+		TLS:             c.TLS,
+		PoolSize:        c.PoolSize,
+		MinIdleConns:    c.MinIdleConns,
+		ConnMaxIdleTime: c.ConnMaxIdleTime,
+		DialTimeout:     c.DialTimeout,
+		ReadTimeout:     c.ReadTimeout,
+		WriteTimeout:    c.WriteTimeout,
+		MaxRetries:      c.MaxRetries,
+		MinRetryBackoff: c.MinRetryBackoff,
+		MaxRetryBackoff: c.MaxRetryBackoff,
+		ReplicaRead:     c.ReplicaRead,
+	}
+}
+
+// LocalCacheConfig 开启后在Redis前增加一层进程内缓存, 各节点之间通过 pub/sub 同步失效
+type LocalCacheConfig struct {
+	Enable     bool          `yaml:"enable"`
+	Capacity   int           `yaml:"capacity"`
+	Expiration time.Duration `yaml:"expiration"`
 }
 
 type redisInit struct {
@@ -24,24 +80,46 @@ type redisInit struct {
 	conf *RedisConfig `autowired:""`
 }
 
-func (r *redisInit) OnPreComplete() {
+var errNotInitialized = errors.New("cache_redis: redis cache is not initialized")
 
-	client := SimpleCluster(Option{
-		Addrs:      r.conf.Addr,
-		MasterName: r.conf.MasterName,
-		Username:   r.conf.UserName,
-		Password:   r.conf.Password,
-		DB:         r.conf.DB,
-	})
+// RedisClient 初始化完成前返回nil
+func (r *redisInit) RedisClient() redis.UniversalClient {
+	if c, ok := r.ICommonCache.(IRedisClient); ok {
+		return c.RedisClient()
+	}
+	return nil
+}
 
-	timeout, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancelFunc()
-	if err := client.Ping(timeout).Err(); err != nil {
-		_ = client.Close()
+// Key 初始化完成前按配置的前缀生成key
+func (r *redisInit) Key(v string) string {
+	if c, ok := r.ICommonCache.(IRedisClient); ok {
+		return c.Key(v)
+	}
+	return fmt.Sprint(namespace(r.conf.Prefix), v)
+}
 
-		log.Fatalf("ping redis %v error:%s", r.conf.Addr, err.Error())
+func (r *redisInit) Health(ctx context.Context) error {
+	if h, ok := r.ICommonCache.(health.IHealth); ok {
+		return h.Health(ctx)
 	}
+	return errNotInitialized
+}
 
+func (r *redisInit) OnPreComplete() {
+
+	client, err := Connect(context.Background(), r.conf.option(), r.conf.Startup)
+	if err != nil {
+		log.Fatalf("connect redis %v error:%s", r.conf.Addr, err.Error())
+	}
+
+	if r.conf.LocalCache.Enable {
+		l1 := cache_memory.NewCommonCache(cache_memory.Option{
+			Capacity:  r.conf.LocalCache.Capacity,
+			Namespace: r.conf.Prefix,
+		})
+		r.ICommonCache = NewTwoTierCache(client, r.conf.Prefix, l1, r.conf.LocalCache.Expiration)
+		return
+	}
 	r.ICommonCache = newCommonCache(client, r.conf.Prefix)
 }
 
@@ -0,0 +1,104 @@
+package cache_redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+const scanCount = 500
+
+var patternEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// Scan 集群模式下在每个主节点上执行SCAN, 各节点并发遍历, fn 的调用是串行的
+func (c *commonCache) Scan(ctx context.Context, pattern string, fn func(key string) error) error {
+	match := fmt.Sprint(patternEscaper.Replace(c.keyPrefix), pattern)
+	var lock sync.Mutex
+	scan := func(ctx context.Context, client redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, match, scanCount).Result()
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				lock.Lock()
+				err := fn(strings.TrimPrefix(key, c.keyPrefix))
+				lock.Unlock()
+				if err != nil {
+					return err
+				}
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return scan(ctx, c.client)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		err := scan(ctx, client)
+		if err != nil {
+			// 一个节点失败后停止其他节点的遍历
+			cancel()
+		}
+		return err
+	})
+}
+
+func (c *commonCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	return deleteByPattern(ctx, c, pattern, c.unlink)
+}
+
+// unlink 删除key并返回实际删除的数量
+func (c *commonCache) unlink(ctx context.Context, keys []string) (int64, error) {
+	pipe := c.client.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipe.Unlink(ctx, c.key(key)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, cmd := range cmds {
+		n += cmd.Val()
+	}
+	return n, nil
+}
+
+// deleteByPattern 遍历时按批删除, 避免在内存中保存所有匹配的key
+func deleteByPattern(ctx context.Context, c *commonCache, pattern string, del func(ctx context.Context, keys []string) (int64, error)) (int64, error) {
+	var total int64
+	batch := make([]string, 0, scanCount)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := del(ctx, batch)
+		total += n
+		batch = batch[:0]
+		return err
+	}
+	err := c.Scan(ctx, pattern, func(key string) error {
+		batch = append(batch, key)
+		if len(batch) >= scanCount {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return total, err
+	}
+	err = flush()
+	return total, err
+}
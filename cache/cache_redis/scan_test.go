@@ -0,0 +1,114 @@
+package cache_redis_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_redis"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+// scanClients 单机和集群模式的客户端
+func scanClients(t *testing.T) map[string]redis.UniversalClient {
+	t.Helper()
+	single := cachetest.StartServer(t, cachetest.ServerOption{})
+	cluster := cachetest.StartServer(t, cachetest.ServerOption{Cluster: true})
+	clients := map[string]redis.UniversalClient{
+		"single":  redis.NewClient(&redis.Options{Addr: single.Addr()}),
+		"cluster": redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{cluster.Addr()}}),
+	}
+	t.Cleanup(func() {
+		for _, client := range clients {
+			_ = client.Close()
+		}
+	})
+	return clients
+}
+
+func scanKeys(t *testing.T, c cache.ICommonCache, pattern string) []string {
+	t.Helper()
+	keys := make([]string, 0)
+	if err := c.Scan(context.Background(), pattern, func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		t.Fatalf("Scan %s: %v", pattern, err)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	for name, client := range scanClients(t) {
+		t.Run(name, func(t *testing.T) {
+			// 前缀中的glob字符需要转义, 否则 app[1]:* 会匹配 app1: 下的key
+			c := cache_redis.NewCommonCache(client, "app[1]")
+			other := cache_redis.NewCommonCache(client, "app1")
+			for _, key := range []string{"user:1", "user:2", "user:10", "order:1"} {
+				_ = c.Set(ctx, key, []byte("v"), 0)
+				_ = other.Set(ctx, key, []byte("v"), 0)
+			}
+
+			for pattern, want := range map[string]string{
+				"*":         "[order:1 user:1 user:10 user:2]",
+				"user:*":    "[user:1 user:10 user:2]",
+				"user:?":    "[user:1 user:2]",
+				"user:[^1]": "[user:2]",
+				"none:*":    "[]",
+			} {
+				if got := fmt.Sprint(scanKeys(t, c, pattern)); got != want {
+					t.Errorf("Scan %s: want %s, got %s", pattern, want, got)
+				}
+			}
+
+			stop := errors.New("stop")
+			calls := 0
+			err := c.Scan(ctx, "*", func(key string) error {
+				calls++
+				return stop
+			})
+			if !errors.Is(err, stop) || calls != 1 {
+				t.Errorf("Scan should stop at the first error, got %v after %d calls", err, calls)
+			}
+		})
+	}
+}
+
+func TestDeleteByPattern(t *testing.T) {
+	ctx := context.Background()
+	for name, client := range scanClients(t) {
+		t.Run(name, func(t *testing.T) {
+			c := cache_redis.NewCommonCache(client, "app")
+			other := cache_redis.NewCommonCache(client, "other")
+			// 超过一次SCAN的数量, 分批删除
+			values := make(map[string][]byte)
+			for i := 0; i < cache_redis.ScanCount*2+10; i++ {
+				values[fmt.Sprint("session:", i)] = []byte("v")
+			}
+			_ = c.MSet(ctx, values, 0)
+			_ = c.Set(ctx, "config", []byte("v"), 0)
+			_ = other.Set(ctx, "session:1", []byte("v"), 0)
+
+			n, err := c.DeleteByPattern(ctx, "session:*")
+			if err != nil || n != int64(len(values)) {
+				t.Fatalf("DeleteByPattern: want %d, got %d %v", len(values), n, err)
+			}
+			if keys := scanKeys(t, c, "*"); fmt.Sprint(keys) != "[config]" {
+				t.Errorf("only matching keys should be deleted, left %v", keys)
+			}
+			if _, err := other.Get(ctx, "session:1"); err != nil {
+				t.Errorf("keys of another namespace should be kept, got %v", err)
+			}
+			if n, err := c.DeleteByPattern(ctx, "session:*"); err != nil || n != 0 {
+				t.Errorf("DeleteByPattern without matches: want 0, got %d %v", n, err)
+			}
+		})
+	}
+}
@@ -2,48 +2,150 @@ package cache_redis
 
 import (
 	"context"
+	"fmt"
+	"github.com/mengri/utils-store/health"
 	"github.com/redis/go-redis/v9"
+	"log"
 	"strings"
 	"time"
 )
 
+const defaultConnMaxIdleTime = time.Minute
+
+// ReplicaRead 集群和哨兵模式下只读命令的路由方式
+type ReplicaRead string
+
+const (
+	// ReplicaReadNone 所有命令都发送到主节点
+	ReplicaReadNone ReplicaRead = ""
+	// ReplicaReadRandom 只读命令随机发送到主节点或从节点
+	ReplicaReadRandom ReplicaRead = "random"
+	// ReplicaReadLatency 只读命令发送到延迟最低的节点
+	ReplicaReadLatency ReplicaRead = "latency"
+)
+
+// Mode 单机或集群模式, 配置了 MasterName 时为哨兵模式, 不受该配置影响
+type Mode string
+
+const (
+	// ModeAuto 多个地址时为集群模式, 只有一个地址时通过 INFO cluster 探测, 探测失败时为单机模式
+	ModeAuto    Mode = ""
+	ModeSingle  Mode = "single"
+	ModeCluster Mode = "cluster"
+)
+
 type Option struct {
 	Addrs      []string
 	MasterName string
 	Username   string
 	Password   string
 	DB         int
+	// Mode 以降级模式启动时无法探测, 只有一个地址时需要明确指定
+	Mode Mode
+
+	TLS TLSOption
+
+	// 以下配置为0时使用go-redis的默认值, ConnMaxIdleTime 默认为1分钟
+	PoolSize        int
+	MinIdleConns    int
+	ConnMaxIdleTime time.Duration
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	// MaxRetries 为-1时不重试
+	MaxRetries      int
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+
+	ReplicaRead ReplicaRead
 }
 
+// SimpleCluster 配置错误(如TLS证书无法读取)时panic, 需要处理错误时使用 NewClient
 func SimpleCluster(opts Option) redis.UniversalClient {
+	client, err := NewClient(opts)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// probe 是否需要连接节点才能确定单机还是集群模式
+func (o *Option) probe() bool {
+	return o.MasterName == "" && o.Mode == ModeAuto && len(o.Addrs) == 1
+}
+
+// NewClient 根据配置创建哨兵、集群或单机客户端, 未指定 Mode 且只配置了一个地址时会探测该节点是否开启了集群模式
+func NewClient(opts Option) (redis.UniversalClient, error) {
+	tlsConfig, err := opts.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
 	options := &redis.UniversalOptions{
 		Addrs:           opts.Addrs,
 		MasterName:      opts.MasterName,
 		Username:        opts.Username,
 		Password:        opts.Password,
 		DB:              opts.DB,
-		ConnMaxIdleTime: time.Minute,
+		TLSConfig:       tlsConfig,
+		PoolSize:        opts.PoolSize,
+		MinIdleConns:    opts.MinIdleConns,
+		ConnMaxIdleTime: opts.ConnMaxIdleTime,
+		DialTimeout:     opts.DialTimeout,
+		ReadTimeout:     opts.ReadTimeout,
+		WriteTimeout:    opts.WriteTimeout,
+		MaxRetries:      opts.MaxRetries,
+		MinRetryBackoff: opts.MinRetryBackoff,
+		MaxRetryBackoff: opts.MaxRetryBackoff,
+	}
+	if options.ConnMaxIdleTime == 0 {
+		options.ConnMaxIdleTime = defaultConnMaxIdleTime
+	}
+	switch opts.ReplicaRead {
+	case ReplicaReadNone:
+	case ReplicaReadRandom:
+		options.RouteRandomly = true
+	case ReplicaReadLatency:
+		options.RouteByLatency = true
+	default:
+		return nil, fmt.Errorf("unknown redis replica read mode %q", opts.ReplicaRead)
+	}
+
+	switch opts.Mode {
+	case ModeAuto, ModeCluster:
+	case ModeSingle:
+		if len(opts.Addrs) > 1 {
+			return nil, fmt.Errorf("redis single mode requires one address, got %v", opts.Addrs)
+		}
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", opts.Mode)
 	}
 
 	if opts.MasterName != "" {
-		return redis.NewFailoverClient(options.Failover())
-	} else if len(opts.Addrs) > 1 {
-		return redis.NewClusterClient(options.Cluster())
+		if opts.ReplicaRead != ReplicaReadNone {
+			// 哨兵模式下通过集群客户端将只读命令路由到从节点
+			return redis.NewFailoverClusterClient(options.Failover()), nil
+		}
+		return redis.NewFailoverClient(options.Failover()), nil
+	} else if opts.Mode == ModeCluster || len(opts.Addrs) > 1 {
+		return redis.NewClusterClient(options.Cluster()), nil
 	}
 	simpleClient := redis.NewClient(options.Simple())
+	if opts.Mode == ModeSingle {
+		return simpleClient, nil
+	}
 	ctx, cf := context.WithTimeout(context.Background(), time.Second)
 	defer cf()
 	info := simpleClient.Info(ctx, "cluster")
 	if info.Err() != nil {
-		return simpleClient
+		return simpleClient, nil
 	}
 	if !strings.Contains(info.String(), "cluster_enabled:1") {
-		return simpleClient
+		return simpleClient, nil
 	}
 
 	nodes := simpleClient.ClusterNodes(context.Background())
 	if nodes.Err() != nil {
-		return simpleClient
+		return simpleClient, nil
 	}
 	_ = simpleClient.Close()
 	nodesContent := nodes.String()
@@ -55,9 +157,45 @@ func SimpleCluster(opts Option) redis.UniversalClient {
 		nodeAddrs = append(nodeAddrs, readAddr(line))
 	}
 	options.Addrs = nodeAddrs
-	return redis.NewClusterClient(options.Cluster())
+	return redis.NewClusterClient(options.Cluster()), nil
 
 }
+
+// Connect 按 startup 配置重试创建客户端并ping, 每次尝试都会重新探测集群模式
+// 全部尝试失败时, 开启降级则返回最后创建的客户端(go-redis会在后续命令中自动重连), 否则关闭客户端并返回错误
+// 降级启动时无法探测集群模式, 因此开启降级且只有一个地址时必须指定 Mode
+func Connect(ctx context.Context, opts Option, startup health.Startup) (redis.UniversalClient, error) {
+	if startup.Degraded && opts.probe() {
+		return nil, fmt.Errorf("redis mode must be %q or %q to start %v in degraded mode", ModeSingle, ModeCluster, opts.Addrs)
+	}
+	var client redis.UniversalClient
+	err := startup.Do(ctx, "redis", func(ctx context.Context) error {
+		if client != nil {
+			_ = client.Close()
+			client = nil
+		}
+		c, err := NewClient(opts)
+		if err != nil {
+			return err
+		}
+		client = c
+		timeout, cancel := context.WithTimeout(ctx, time.Second*10)
+		defer cancel()
+		return client.Ping(timeout).Err()
+	})
+	if err == nil {
+		return client, nil
+	}
+	if client == nil || !startup.Degraded {
+		if client != nil {
+			_ = client.Close()
+		}
+		return nil, err
+	}
+	log.Printf("redis %v unavailable, start in degraded mode: %s", opts.Addrs, err.Error())
+	return client, nil
+}
+
 func readAddr(line string) string {
 	fields := strings.Fields(line)
 	addr := fields[1]
@@ -0,0 +1,123 @@
+package cache_redis
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+func (c *commonCache) ZAdd(ctx context.Context, key string, member []byte, score float64, expiration time.Duration) error {
+	redisKey := c.key(key)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, redisKey, redis.Z{Score: score, Member: member})
+		if expiration > 0 {
+			pipe.PExpire(ctx, redisKey, expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *commonCache) ZIncrBy(ctx context.Context, key string, member []byte, incr float64, expiration time.Duration) (float64, error) {
+	redisKey := c.key(key)
+	var incrCmd *redis.FloatCmd
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incrCmd = pipe.ZIncrBy(ctx, redisKey, incr, string(member))
+		if expiration > 0 {
+			pipe.PExpire(ctx, redisKey, expiration)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incrCmd.Val(), nil
+}
+
+func (c *commonCache) ZScore(ctx context.Context, key string, member []byte) (float64, error) {
+	v, err := c.client.ZScore(ctx, c.key(key), string(member)).Result()
+	if err != nil {
+		return 0, convertErr(err)
+	}
+	return v, nil
+}
+
+func (c *commonCache) ZRank(ctx context.Context, key string, member []byte, reverse bool) (int64, error) {
+	var cmd *redis.IntCmd
+	if reverse {
+		cmd = c.client.ZRevRank(ctx, c.key(key), string(member))
+	} else {
+		cmd = c.client.ZRank(ctx, c.key(key), string(member))
+	}
+	v, err := cmd.Result()
+	if err != nil {
+		return 0, convertErr(err)
+	}
+	return v, nil
+}
+
+func (c *commonCache) ZRange(ctx context.Context, key string, start, stop int64, reverse bool) ([]cache.ZMember, error) {
+	var cmd *redis.ZSliceCmd
+	if reverse {
+		cmd = c.client.ZRevRangeWithScores(ctx, c.key(key), start, stop)
+	} else {
+		cmd = c.client.ZRangeWithScores(ctx, c.key(key), start, stop)
+	}
+	zs, err := cmd.Result()
+	if err != nil {
+		return nil, err
+	}
+	return toZMembers(zs), nil
+}
+
+func (c *commonCache) ZRangeByScore(ctx context.Context, key string, min, max float64, offset, count int64) ([]cache.ZMember, error) {
+	opt := &redis.ZRangeBy{
+		Min:    formatScore(min),
+		Max:    formatScore(max),
+		Offset: offset,
+		Count:  count,
+	}
+	if count <= 0 {
+		opt.Count = -1
+	}
+	zs, err := c.client.ZRangeByScoreWithScores(ctx, c.key(key), opt).Result()
+	if err != nil {
+		return nil, err
+	}
+	return toZMembers(zs), nil
+}
+
+func (c *commonCache) ZRem(ctx context.Context, key string, members ...[]byte) error {
+	if len(members) == 0 {
+		return nil
+	}
+	values := make([]interface{}, 0, len(members))
+	for _, m := range members {
+		values = append(values, m)
+	}
+	return c.client.ZRem(ctx, c.key(key), values...).Err()
+}
+
+func toZMembers(zs []redis.Z) []cache.ZMember {
+	rs := make([]cache.ZMember, 0, len(zs))
+	for _, z := range zs {
+		member, _ := z.Member.(string)
+		rs = append(rs, cache.ZMember{Member: []byte(member), Score: z.Score})
+	}
+	return rs
+}
+
+func formatScore(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,556 @@
+package cache_redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/health"
+)
+
+const (
+	defaultL1Expiration = time.Second * 30
+	invalidateChannel   = "__l1_invalidate"
+)
+
+var (
+	_ cache.ICommonCache = (*twoTierCache)(nil)
+	_ IRedisClient       = (*twoTierCache)(nil)
+	_ health.IHealth     = (*twoTierCache)(nil)
+)
+
+type invalidation struct {
+	Node string   `json:"node"`
+	Keys []string `json:"keys"`
+}
+
+// twoTierSync 管理L1副本, seq 在每次淘汰或写入L1时递增
+// 读取L2前记录 seq, 回填L1时 seq 已变化说明读取期间有写入, 读到的值可能已经过期, 放弃回填
+type twoTierSync struct {
+	client  redis.UniversalClient
+	channel string
+	node    string
+	l1      cache.ICommonCache
+	pubsub  *redis.PubSub
+
+	lock sync.Mutex
+	seq  uint64
+}
+
+// twoTierCache 本地内存(L1) + Redis(L2) 两级缓存
+// 写操作会通过 Redis pub/sub 通知其他节点淘汰各自的L1副本, 订阅断开期间的通知会丢失, 因此L1的过期时间不会超过 l1Expiration
+// L1只缓存 Get/MGet 读到的字符串和 HGetAll 读到的hash, 其余读操作直接访问L2, 所有写操作(包括计数器和脚本)都会淘汰L1副本
+// 不嵌入L2, 所有方法显式委托, ICommonCache 新增方法时需要在这里决定是否淘汰L1
+type twoTierCache struct {
+	l2           *commonCache
+	sync         *twoTierSync
+	l1Expiration time.Duration
+}
+
+// NewTwoTierCache 创建两级缓存, l1 通常为 cache_memory 创建的进程内缓存
+// 返回值实现了 io.Closer, 关闭后停止接收失效通知
+func NewTwoTierCache(client redis.UniversalClient, namespace string, l1 cache.ICommonCache, l1Expiration time.Duration) cache.ICommonCache {
+	if l1Expiration <= 0 {
+		l1Expiration = defaultL1Expiration
+	}
+	l2 := newCommonCache(client, namespace).(*commonCache)
+	s := &twoTierSync{
+		client:  client,
+		channel: l2.key(invalidateChannel),
+		node:    uuid.NewString(),
+		l1:      l1,
+	}
+	s.pubsub = client.Subscribe(context.Background(), s.channel)
+	go s.listen()
+
+	return &twoTierCache{
+		l2:           l2,
+		sync:         s,
+		l1Expiration: l1Expiration,
+	}
+}
+
+func (s *twoTierSync) listen() {
+	for msg := range s.pubsub.Channel() {
+		inv := new(invalidation)
+		if err := json.Unmarshal([]byte(msg.Payload), inv); err != nil {
+			log.Printf("decode l1 invalidation %s error:%s", msg.Payload, err.Error())
+			continue
+		}
+		if inv.Node == s.node {
+			continue
+		}
+		s.evict(context.Background(), inv.Keys...)
+	}
+}
+
+func (s *twoTierSync) version() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.seq
+}
+
+// update 修改L1并使进行中的回填失效
+func (s *twoTierSync) update(fn func(l1 cache.ICommonCache)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.seq++
+	fn(s.l1)
+}
+
+func (s *twoTierSync) evict(ctx context.Context, keys ...string) {
+	s.update(func(l1 cache.ICommonCache) {
+		_ = l1.Del(ctx, keys...)
+	})
+}
+
+// fill 回填L1, 读取L2之后 seq 发生变化时不回填
+// seq 是全局的, 其他key的写入也会使回填失效, 只会降低命中率
+func (s *twoTierSync) fill(seq uint64, fn func(l1 cache.ICommonCache)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.seq == seq {
+		fn(s.l1)
+	}
+}
+
+func (s *twoTierSync) publish(ctx context.Context, keys ...string) error {
+	data, err := json.Marshal(&invalidation{Node: s.node, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return s.client.Publish(ctx, s.channel, data).Err()
+}
+
+func (c *twoTierCache) Close() error {
+	return c.sync.pubsub.Close()
+}
+
+func (c *twoTierCache) RedisClient() redis.UniversalClient {
+	return c.l2.RedisClient()
+}
+
+func (c *twoTierCache) Key(v string) string {
+	return c.l2.Key(v)
+}
+
+func (c *twoTierCache) Health(ctx context.Context) error {
+	return c.l2.Health(ctx)
+}
+
+// invalidate 淘汰本节点和其他节点的L1副本
+func (c *twoTierCache) invalidate(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	c.sync.evict(ctx, keys...)
+	return c.sync.publish(ctx, keys...)
+}
+
+func (c *twoTierCache) Clone() cache.ICommonCache {
+	return &twoTierCache{
+		l2:           c.l2.Clone().(*commonCache),
+		sync:         c.sync,
+		l1Expiration: c.l1Expiration,
+	}
+}
+
+func (c *twoTierCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if v, err := c.sync.l1.Get(ctx, key); err == nil {
+		return v, nil
+	}
+
+	seq := c.sync.version()
+	redisKey := c.l2.key(key)
+	pipe := c.l2.client.Pipeline()
+	getCmd := pipe.Get(ctx, redisKey)
+	ttlCmd := pipe.PTTL(ctx, redisKey)
+	_, _ = pipe.Exec(ctx)
+	v, err := getCmd.Bytes()
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	c.sync.fill(seq, func(l1 cache.ICommonCache) {
+		_ = l1.Set(ctx, key, v, c.localExpiration(ttlCmd))
+	})
+	return v, nil
+}
+
+func (c *twoTierCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	if err := c.l2.Set(ctx, key, val, expiration); err != nil {
+		return err
+	}
+	l1Expiration := c.l1Expiration
+	if expiration > 0 && expiration < l1Expiration {
+		l1Expiration = expiration
+	}
+	c.sync.update(func(l1 cache.ICommonCache) {
+		_ = l1.Set(ctx, key, val, l1Expiration)
+	})
+	return c.sync.publish(ctx, key)
+}
+
+// Expire 缩短过期时间时淘汰L1副本, 避免L1在L2过期后仍返回旧值
+func (c *twoTierCache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	ok, err := c.l2.Expire(ctx, key, expiration)
+	if err != nil || !ok || expiration >= c.l1Expiration {
+		return ok, err
+	}
+	return ok, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) MExpire(ctx context.Context, keys []string, expiration time.Duration) error {
+	if err := c.l2.MExpire(ctx, keys, expiration); err != nil || expiration >= c.l1Expiration {
+		return err
+	}
+	return c.invalidate(ctx, keys...)
+}
+
+func (c *twoTierCache) ExpireAt(ctx context.Context, key string, t time.Time) (bool, error) {
+	ok, err := c.l2.ExpireAt(ctx, key, t)
+	if err != nil || !ok || !t.Before(time.Now().Add(c.l1Expiration)) {
+		return ok, err
+	}
+	return ok, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) CompareAndDelete(ctx context.Context, key string, val []byte) (bool, error) {
+	ok, err := c.l2.CompareAndDelete(ctx, key, val)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return ok, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) CompareAndExpire(ctx context.Context, key string, val []byte, expiration time.Duration) (bool, error) {
+	ok, err := c.l2.CompareAndExpire(ctx, key, val, expiration)
+	if err != nil || !ok || expiration >= c.l1Expiration {
+		return ok, err
+	}
+	return ok, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	values, err := c.sync.l1.MGet(ctx, keys...)
+	if err != nil {
+		values = make([][]byte, len(keys))
+	}
+	missing := make([]int, 0, len(keys))
+	for i, v := range values {
+		if v == nil {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	seq := c.sync.version()
+	pipe := c.l2.client.Pipeline()
+	getCmds := make([]*redis.StringCmd, 0, len(missing))
+	ttlCmds := make([]*redis.DurationCmd, 0, len(missing))
+	for _, i := range missing {
+		redisKey := c.l2.key(keys[i])
+		getCmds = append(getCmds, pipe.Get(ctx, redisKey))
+		ttlCmds = append(ttlCmds, pipe.PTTL(ctx, redisKey))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	loaded := make(map[int]time.Duration, len(missing))
+	for n, i := range missing {
+		v, err := getCmds[n].Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			return nil, err
+		}
+		values[i] = v
+		loaded[i] = c.localExpiration(ttlCmds[n])
+	}
+	c.sync.fill(seq, func(l1 cache.ICommonCache) {
+		for i, expiration := range loaded {
+			_ = l1.Set(ctx, keys[i], values[i], expiration)
+		}
+	})
+	return values, nil
+}
+
+func (c *twoTierCache) MSet(ctx context.Context, values map[string][]byte, expiration time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if err := c.l2.MSet(ctx, values, expiration); err != nil {
+		return err
+	}
+	l1Expiration := c.l1Expiration
+	if expiration > 0 && expiration < l1Expiration {
+		l1Expiration = expiration
+	}
+	c.sync.update(func(l1 cache.ICommonCache) {
+		_ = l1.MSet(ctx, values, l1Expiration)
+	})
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return c.sync.publish(ctx, keys...)
+}
+
+func (c *twoTierCache) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := c.l2.Del(ctx, keys...); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, keys...)
+}
+
+func (c *twoTierCache) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	v, err := c.HGetAllBytes(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	rs := make(map[string]string, len(v))
+	for f, fv := range v {
+		rs[f] = string(fv)
+	}
+	return rs, nil
+}
+
+func (c *twoTierCache) HGetAllBytes(ctx context.Context, key string) (map[string][]byte, error) {
+	if v, err := c.sync.l1.HGetAllBytes(ctx, key); err == nil && len(v) > 0 {
+		return v, nil
+	}
+
+	seq := c.sync.version()
+	redisKey := c.l2.key(key)
+	pipe := c.l2.client.Pipeline()
+	getCmd := pipe.HGetAll(ctx, redisKey)
+	ttlCmd := pipe.PTTL(ctx, redisKey)
+	_, _ = pipe.Exec(ctx)
+	v, err := getCmd.Result()
+	if err != nil {
+		return nil, err
+	}
+	values := toBytesMap(v)
+	if len(values) > 0 {
+		c.sync.fill(seq, func(l1 cache.ICommonCache) {
+			_ = l1.HMSet(ctx, key, values, c.localExpiration(ttlCmd))
+		})
+	}
+	return values, nil
+}
+
+// HGet L1中的hash只通过 HGetAll 整体写入, 读取单个字段时不回填L1, 避免L1中出现不完整的hash
+func (c *twoTierCache) HGet(ctx context.Context, key string, field string) ([]byte, error) {
+	if v, err := c.sync.l1.HGet(ctx, key, field); err == nil {
+		return v, nil
+	}
+	return c.l2.HGet(ctx, key, field)
+}
+
+func (c *twoTierCache) HSet(ctx context.Context, key string, field string, val []byte, expiration time.Duration) error {
+	if err := c.l2.HSet(ctx, key, field, val, expiration); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) HMSet(ctx context.Context, key string, value map[string][]byte, expiration time.Duration) error {
+	if err := c.l2.HMSet(ctx, key, value, expiration); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+// DeleteByPattern 删除L2中匹配的key, 并淘汰所有节点上对应的L1副本
+func (c *twoTierCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	return deleteByPattern(ctx, c.l2, pattern, func(ctx context.Context, keys []string) (int64, error) {
+		n, err := c.l2.unlink(ctx, keys)
+		if err != nil {
+			return n, err
+		}
+		return n, c.invalidate(ctx, keys...)
+	})
+}
+
+func (c *twoTierCache) HDel(ctx context.Context, key string, fields ...string) error {
+	if err := c.l2.HDel(ctx, key, fields...); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+// localExpiration L1的过期时间不超过L2中剩余的过期时间
+func (c *twoTierCache) localExpiration(ttlCmd *redis.DurationCmd) time.Duration {
+	ttl, err := ttlCmd.Result()
+	if err != nil || ttl <= 0 || ttl > c.l1Expiration {
+		return c.l1Expiration
+	}
+	return ttl
+}
+
+func (c *twoTierCache) GetInt(ctx context.Context, key string) (int64, error) {
+	return c.l2.GetInt(ctx, key)
+}
+
+func (c *twoTierCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.l2.TTL(ctx, key)
+}
+
+// Persist 只会延长L2的过期时间, L1副本仍按自己的过期时间淘汰, 不需要通知
+func (c *twoTierCache) Persist(ctx context.Context, key string) (bool, error) {
+	return c.l2.Persist(ctx, key)
+}
+
+func (c *twoTierCache) Exists(ctx context.Context, keys ...string) (int64, error) {
+	return c.l2.Exists(ctx, keys...)
+}
+
+func (c *twoTierCache) Incr(ctx context.Context, key string, expiration time.Duration) error {
+	if err := c.l2.Incr(ctx, key, expiration); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) IncrBy(ctx context.Context, key string, val int64, expiration time.Duration) error {
+	if err := c.l2.IncrBy(ctx, key, val, expiration); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) IncrByEx(ctx context.Context, key string, val int64, expiration time.Duration, sliding bool) (int64, error) {
+	v, err := c.l2.IncrByEx(ctx, key, val, expiration, sliding)
+	if err != nil {
+		return v, err
+	}
+	return v, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) SetNX(ctx context.Context, key string, val interface{}, expiration time.Duration) (bool, error) {
+	ok, err := c.l2.SetNX(ctx, key, val, expiration)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return ok, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) RPush(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) (int64, error) {
+	n, err := c.l2.RPush(ctx, key, expiration, vals...)
+	if err != nil {
+		return n, err
+	}
+	return n, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) LPush(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) (int64, error) {
+	n, err := c.l2.LPush(ctx, key, expiration, vals...)
+	if err != nil {
+		return n, err
+	}
+	return n, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) RPushTrim(ctx context.Context, key string, maxLen int64, expiration time.Duration, vals ...[]byte) (int64, error) {
+	n, err := c.l2.RPushTrim(ctx, key, maxLen, expiration, vals...)
+	if err != nil {
+		return n, err
+	}
+	return n, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) LPushTrim(ctx context.Context, key string, maxLen int64, expiration time.Duration, vals ...[]byte) (int64, error) {
+	n, err := c.l2.LPushTrim(ctx, key, maxLen, expiration, vals...)
+	if err != nil {
+		return n, err
+	}
+	return n, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) LReplace(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) error {
+	if err := c.l2.LReplace(ctx, key, expiration, vals...); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) LRange(ctx context.Context, key string, start, stop int64) ([][]byte, error) {
+	return c.l2.LRange(ctx, key, start, stop)
+}
+
+func (c *twoTierCache) LTrim(ctx context.Context, key string, start, stop int64) error {
+	if err := c.l2.LTrim(ctx, key, start, stop); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) LLen(ctx context.Context, key string) (int64, error) {
+	return c.l2.LLen(ctx, key)
+}
+
+func (c *twoTierCache) ZAdd(ctx context.Context, key string, member []byte, score float64, expiration time.Duration) error {
+	if err := c.l2.ZAdd(ctx, key, member, score, expiration); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) ZIncrBy(ctx context.Context, key string, member []byte, incr float64, expiration time.Duration) (float64, error) {
+	v, err := c.l2.ZIncrBy(ctx, key, member, incr, expiration)
+	if err != nil {
+		return v, err
+	}
+	return v, c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) ZScore(ctx context.Context, key string, member []byte) (float64, error) {
+	return c.l2.ZScore(ctx, key, member)
+}
+
+func (c *twoTierCache) ZRank(ctx context.Context, key string, member []byte, reverse bool) (int64, error) {
+	return c.l2.ZRank(ctx, key, member, reverse)
+}
+
+func (c *twoTierCache) ZRange(ctx context.Context, key string, start, stop int64, reverse bool) ([]cache.ZMember, error) {
+	return c.l2.ZRange(ctx, key, start, stop, reverse)
+}
+
+func (c *twoTierCache) ZRangeByScore(ctx context.Context, key string, min, max float64, offset, count int64) ([]cache.ZMember, error) {
+	return c.l2.ZRangeByScore(ctx, key, min, max, offset, count)
+}
+
+func (c *twoTierCache) ZRem(ctx context.Context, key string, members ...[]byte) error {
+	if err := c.l2.ZRem(ctx, key, members...); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *twoTierCache) Scan(ctx context.Context, pattern string, fn func(key string) error) error {
+	return c.l2.Scan(ctx, pattern, fn)
+}
+
+// Eval 脚本可能修改传入的任意key, 执行成功后淘汰所有key的L1副本
+func (c *twoTierCache) Eval(ctx context.Context, script *cache.Script, keys []string, args ...interface{}) (interface{}, error) {
+	v, err := c.l2.Eval(ctx, script, keys, args...)
+	if err != nil {
+		return v, err
+	}
+	return v, c.invalidate(ctx, keys...)
+}
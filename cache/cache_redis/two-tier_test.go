@@ -0,0 +1,295 @@
+package cache_redis_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+	"github.com/mengri/utils-store/cache/cache_redis"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+// newTwoTierNodes 在同一个进程内RESP服务上创建多个两级缓存节点, 每个节点有独立的L1
+func newTwoTierNodes(t *testing.T, n int) ([]cache.ICommonCache, *cachetest.Server) {
+	t.Helper()
+	return newTwoTierNodesOn(t, cachetest.StartServer(t, cachetest.ServerOption{}), n)
+}
+
+func newTwoTierNodesOn(t *testing.T, s *cachetest.Server, n int) ([]cache.ICommonCache, *cachetest.Server) {
+	t.Helper()
+	nodes := make([]cache.ICommonCache, 0, n)
+	for i := 0; i < n; i++ {
+		client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+		l1 := cache_memory.NewCommonCache(cache_memory.Option{Namespace: "l1"})
+		node := cache_redis.NewTwoTierCache(client, "test", l1, time.Minute)
+		t.Cleanup(func() {
+			_ = node.(io.Closer).Close()
+			_ = l1.(io.Closer).Close()
+			_ = client.Close()
+		})
+		nodes = append(nodes, node)
+	}
+	return nodes, s
+}
+
+func getString(c cache.ICommonCache, key string) string {
+	v, err := c.Get(context.Background(), key)
+	if err != nil {
+		return err.Error()
+	}
+	return string(v)
+}
+
+func TestTwoTierServesFromL1(t *testing.T) {
+	ctx := context.Background()
+	nodes, s := newTwoTierNodes(t, 1)
+	a := nodes[0]
+
+	if err := a.Set(ctx, "k", []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	// L2被清空后仍然可以从L1读到
+	s.FlushAll()
+	if v := getString(a, "k"); v != "v" {
+		t.Errorf("Get from L1: want v, got %s", v)
+	}
+}
+
+func TestTwoTierL1ExpirationFollowsL2(t *testing.T) {
+	ctx := context.Background()
+	nodes, _ := newTwoTierNodes(t, 2)
+	a, b := nodes[0], nodes[1]
+
+	_ = a.Set(ctx, "k", []byte("v"), 100*time.Millisecond)
+	if v := getString(b, "k"); v != "v" {
+		t.Fatalf("Get: want v, got %s", v)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if _, err := b.Get(ctx, "k"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("L1 copy should expire with L2, got %v", err)
+	}
+}
+
+func TestTwoTierInvalidatesOtherNodes(t *testing.T) {
+	ctx := context.Background()
+	nodes, _ := newTwoTierNodes(t, 2)
+	a, b := nodes[0], nodes[1]
+
+	tests := []struct {
+		name  string
+		setup func() error
+		write func() error
+		want  string
+	}{
+		{"Set", func() error { return a.Set(ctx, "k", []byte("1"), 0) }, func() error { return b.Set(ctx, "k", []byte("2"), 0) }, "2"},
+		{"Del", func() error { return a.Set(ctx, "k", []byte("1"), 0) }, func() error { return b.Del(ctx, "k") }, cache.ErrNotFound.Error()},
+		{"Incr", func() error { return a.Set(ctx, "k", []byte("1"), 0) }, func() error { return b.Incr(ctx, "k", 0) }, "2"},
+		{"IncrByEx", func() error { return a.Set(ctx, "k", []byte("1"), 0) }, func() error {
+			_, err := b.IncrByEx(ctx, "k", 5, time.Minute, false)
+			return err
+		}, "6"},
+		{"CompareAndDelete", func() error { return a.Set(ctx, "k", []byte("1"), 0) }, func() error {
+			_, err := b.CompareAndDelete(ctx, "k", []byte("1"))
+			return err
+		}, cache.ErrNotFound.Error()},
+		{"Expire", func() error { return a.Set(ctx, "k", []byte("1"), 0) }, func() error {
+			_, err := b.Expire(ctx, "k", 0)
+			return err
+		}, cache.ErrNotFound.Error()},
+		{"DeleteByPattern", func() error { return a.Set(ctx, "k", []byte("1"), 0) }, func() error {
+			_, err := b.DeleteByPattern(ctx, "k*")
+			return err
+		}, cache.ErrNotFound.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.setup(); err != nil {
+				t.Fatal(err)
+			}
+			// 两个节点的L1中都有旧值, b的L1需要等待上一个子测试的失效通知
+			if v := getString(a, "k"); v != "1" {
+				t.Fatalf("Get: want 1, got %s", v)
+			}
+			cachetest.Eventually(t, "setup", func() bool {
+				return getString(b, "k") == "1"
+			})
+			if err := tt.write(); err != nil {
+				t.Fatal(err)
+			}
+			if v := getString(b, "k"); v != tt.want {
+				t.Errorf("writer node: want %s, got %s", tt.want, v)
+			}
+			cachetest.Eventually(t, "invalidation of "+tt.name, func() bool {
+				return getString(a, "k") == tt.want
+			})
+		})
+	}
+}
+
+func TestTwoTierHashInvalidation(t *testing.T) {
+	ctx := context.Background()
+	nodes, _ := newTwoTierNodes(t, 2)
+	a, b := nodes[0], nodes[1]
+
+	_ = a.HMSet(ctx, "h", map[string][]byte{"f": []byte("1")}, 0)
+	if v, _ := a.HGetAllBytes(ctx, "h"); string(v["f"]) != "1" {
+		t.Fatalf("HGetAllBytes: want 1, got %q", v["f"])
+	}
+	_ = b.HSet(ctx, "h", "f", []byte("2"), 0)
+	cachetest.Eventually(t, "hash invalidation", func() bool {
+		v, _ := a.HGetAllBytes(ctx, "h")
+		return string(v["f"]) == "2"
+	})
+}
+
+func TestTwoTierEvalInvalidatesKeys(t *testing.T) {
+	ctx := context.Background()
+	nodes, s := newTwoTierNodes(t, 2)
+	a, b := nodes[0], nodes[1]
+
+	script := cache.NewScript(`return redis.call("SET", KEYS[1], ARGV[1])`)
+	s.HandleScript(script.Source(), func(call func(args ...string) (any, error), keys []string, args []string) (any, error) {
+		return call("SET", keys[0], args[0])
+	})
+
+	_ = a.Set(ctx, "k", []byte("1"), 0)
+	if v := getString(b, "k"); v != "1" {
+		t.Fatalf("Get: want 1, got %s", v)
+	}
+	if _, err := a.Eval(ctx, script, []string{"k"}, "2"); err != nil {
+		t.Fatal(err)
+	}
+	cachetest.Eventually(t, "eval invalidation", func() bool {
+		return getString(b, "k") == "2"
+	})
+}
+
+// afterPipeline 在 pipeline 执行完成后调用, 用于在读取L2和回填L1之间插入操作
+type afterPipeline func(cmds []redis.Cmder)
+
+func (h afterPipeline) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h afterPipeline) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return next
+}
+
+func (h afterPipeline) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		h(cmds)
+		return err
+	}
+}
+
+// 读取L2之后、回填L1之前其他节点写入, 不能把读到的旧值回填到L1
+func TestTwoTierSkipsStaleFill(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		setup func(c cache.ICommonCache) error
+		write func(c cache.ICommonCache) error
+		read  func(c cache.ICommonCache) string
+	}{
+		{"Get", func(c cache.ICommonCache) error {
+			return c.Set(ctx, "k", []byte("1"), 0)
+		}, func(c cache.ICommonCache) error {
+			return c.Set(ctx, "k", []byte("2"), 0)
+		}, func(c cache.ICommonCache) string {
+			return getString(c, "k")
+		}},
+		{"MGet", func(c cache.ICommonCache) error {
+			return c.Set(ctx, "k", []byte("1"), 0)
+		}, func(c cache.ICommonCache) error {
+			return c.Set(ctx, "k", []byte("2"), 0)
+		}, func(c cache.ICommonCache) string {
+			v, err := c.MGet(ctx, "k")
+			if err != nil {
+				return err.Error()
+			}
+			return string(v[0])
+		}},
+		{"HGetAll", func(c cache.ICommonCache) error {
+			return c.HSet(ctx, "k", "f", []byte("1"), 0)
+		}, func(c cache.ICommonCache) error {
+			return c.HSet(ctx, "k", "f", []byte("2"), 0)
+		}, func(c cache.ICommonCache) string {
+			v, _ := c.HGetAllBytes(ctx, "k")
+			return string(v["f"])
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := cachetest.StartServer(t, cachetest.ServerOption{})
+			remote, _ := newTwoTierNodesOn(t, s, 1)
+
+			// 远程节点写入后, 等待本节点收到失效通知再继续回填
+			evicted := make(chan struct{}, 1)
+			memory := cache_memory.NewCommonCache(cache_memory.Option{Namespace: "l1"})
+			l1 := cache.Wrap(memory, func(next cache.Handler) cache.Handler {
+				return func(ctx context.Context, op *cache.Operation) error {
+					err := next(ctx, op)
+					if op.Name == "del" && len(op.Keys) > 0 {
+						select {
+						case evicted <- struct{}{}:
+						default:
+						}
+					}
+					return err
+				}
+			})
+			var interleave atomic.Bool
+			client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+			client.AddHook(afterPipeline(func(cmds []redis.Cmder) {
+				// 建立连接时的握手也通过 pipeline 发送, 只在读取时插入写入
+				if name := cmds[0].Name(); name != "get" && name != "hgetall" || !interleave.CompareAndSwap(true, false) {
+					return
+				}
+				if err := tt.write(remote[0]); err != nil {
+					t.Error(err)
+				}
+				select {
+				case <-evicted:
+				case <-time.After(2 * time.Second):
+					t.Error("timeout waiting for the invalidation")
+				}
+			}))
+			local := cache_redis.NewTwoTierCache(client, "test", l1, time.Minute)
+			t.Cleanup(func() {
+				_ = local.(io.Closer).Close()
+				_ = memory.(io.Closer).Close()
+				_ = client.Close()
+			})
+
+			// 等待两个节点都完成订阅
+			channel := local.(cache_redis.IRedisClient).Key("__l1_invalidate")
+			cachetest.Eventually(t, "subscriptions", func() bool {
+				return client.Publish(ctx, channel, `{"node":"","keys":[]}`).Val() == 2
+			})
+			if err := tt.setup(remote[0]); err != nil {
+				t.Fatal(err)
+			}
+			// 等待初始写入的失效通知, 避免与读取期间的通知混淆
+			select {
+			case <-evicted:
+			case <-time.After(2 * time.Second):
+				t.Fatal("timeout waiting for the invalidation of the setup")
+			}
+			interleave.Store(true)
+			if v := tt.read(local); v != "1" {
+				t.Fatalf("read during the remote write: want the old value 1, got %s", v)
+			}
+			if v := tt.read(local); v != "2" {
+				t.Errorf("stale value should not be filled into L1, got %s", v)
+			}
+		})
+	}
+}
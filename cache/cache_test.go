@@ -0,0 +1,329 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_redis"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+type user struct {
+	Id   int64
+	Name string
+}
+
+func TestGetOrLoadMergesConcurrentMisses(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	users := cache.CreateKvCache[user, int64](client, time.Minute)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context, id int64) (*user, error) {
+		calls.Add(1)
+		<-release
+		return &user{Id: id, Name: "a"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := users.GetOrLoad(context.Background(), 1, loader)
+			if err != nil || u.Name != "a" {
+				t.Errorf("GetOrLoad: want a, got %v %v", u, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader should be called once, got %d", n)
+	}
+	u, err := users.Get(context.Background(), 1)
+	if err != nil || u.Name != "a" {
+		t.Errorf("loaded value should be cached, got %v %v", u, err)
+	}
+}
+
+func TestGetOrLoadAcrossInstances(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	// 两个实例使用独立的进程内合并, 模拟两个进程
+	a := cache.CreateKvCache[user, int64](client, time.Minute)
+	b := cache.CreateKvCache[user, int64](client.Clone(), time.Minute)
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context, id int64) (*user, error) {
+		calls.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return &user{Id: id}, nil
+	}
+	var wg sync.WaitGroup
+	for _, c := range []cache.IKVCache[user, int64]{a, b} {
+		wg.Add(1)
+		go func(c cache.IKVCache[user, int64]) {
+			defer wg.Done()
+			if _, err := c.GetOrLoad(context.Background(), 7, loader); err != nil {
+				t.Errorf("GetOrLoad: %v", err)
+			}
+		}(c)
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader should be called once across instances, got %d", n)
+	}
+}
+
+func TestGetOrLoadCancelledCallerDoesNotFailWaiters(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	users := cache.CreateKvCache[user, int64](client, time.Minute)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loader := func(ctx context.Context, id int64) (*user, error) {
+		close(started)
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return &user{Id: id, Name: "a"}, nil
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := users.GetOrLoad(first, 1, loader)
+		firstErr <- err
+	}()
+	<-started
+
+	waiter := make(chan error, 1)
+	go func() {
+		u, err := users.GetOrLoad(context.Background(), 1, loader)
+		if err == nil && u.Name != "a" {
+			err = errors.New("unexpected value " + u.Name)
+		}
+		waiter <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller: want context.Canceled, got %v", err)
+	}
+	close(release)
+	if err := <-waiter; err != nil {
+		t.Errorf("waiter should get the loaded value, got %v", err)
+	}
+}
+
+func TestGetOrLoadWaiterDeadline(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	users := cache.CreateKvCache[user, int64](client, time.Minute)
+
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		_, _ = users.GetOrLoad(context.Background(), 1, func(ctx context.Context, id int64) (*user, error) {
+			<-release
+			return &user{Id: id}, nil
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err := users.GetOrLoad(ctx, 1, func(ctx context.Context, id int64) (*user, error) {
+		t.Error("waiter should not call its own loader")
+		return nil, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestGetOrLoadLoaderError(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	users := cache.CreateKvCache[user, int64](client, time.Minute)
+
+	boom := errors.New("boom")
+	if _, err := users.GetOrLoad(context.Background(), 1, func(ctx context.Context, id int64) (*user, error) {
+		return nil, boom
+	}); !errors.Is(err, boom) {
+		t.Errorf("want loader error, got %v", err)
+	}
+	if _, err := users.GetOrLoad(context.Background(), 1, func(ctx context.Context, id int64) (*user, error) {
+		return nil, nil
+	}); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("nil value: want ErrNotFound, got %v", err)
+	}
+	if _, err := users.GetOrLoad(context.Background(), 1, func(ctx context.Context, id int64) (*user, error) {
+		panic("boom")
+	}); err == nil {
+		t.Error("loader panic should be returned as error")
+	}
+}
+
+func TestTypedCachesUseCodec(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+
+	users := cache.CreateKvCacheWithOptions[user, int64](client, time.Minute, nil, cache.WithCodec(cache.GobCodec))
+	if err := users.Set(ctx, 1, &user{Id: 1, Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := client.Get(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := new(user)
+	if err := cache.GobCodec.Unmarshal(data, raw); err != nil || raw.Name != "a" {
+		t.Errorf("stored value should be gob encoded, got %+v %v", raw, err)
+	}
+	if u, err := users.Get(ctx, 1); err != nil || u.Name != "a" {
+		t.Errorf("Get: want a, got %v %v", u, err)
+	}
+
+	list := cache.CreateListCache[user](client, time.Minute, "list", cache.WithCodec(cache.GobCodec))
+	if err := list.SetAll(ctx, []user{{Id: 1}, {Id: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := list.GetAll(ctx); err != nil || len(all) != 2 || all[1].Id != 2 {
+		t.Errorf("list GetAll: want 2 users, got %v %v", all, err)
+	}
+
+	single := cache.CreateSingletonCache[user](client, time.Minute, "single", cache.WithCodec(cache.GobCodec))
+	if err := single.Set(ctx, &user{Name: "s"}); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := single.Get(ctx); err != nil || u.Name != "s" {
+		t.Errorf("singleton Get: want s, got %v %v", u, err)
+	}
+}
+
+func TestKvCacheBatch(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	users := cache.CreateKvCache[user, int64](client, time.Minute)
+
+	if err := users.SetMany(ctx, map[int64]*user{1: {Id: 1}, 2: {Id: 2}, 3: {Id: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	found, missing, err := users.GetMany(ctx, 1, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[1].Id != 1 || found[2].Id != 2 {
+		t.Errorf("GetMany: want users 1 and 2, got %v", found)
+	}
+	if len(missing) != 1 || missing[0] != 4 {
+		t.Errorf("GetMany: want missing [4], got %v", missing)
+	}
+	if ttl, err := client.TTL(ctx, "3"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("SetMany should apply expiration, got %s %v", ttl, err)
+	}
+
+	if err := users.Delete(ctx, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	found, missing, _ = users.GetMany(ctx, 1, 2, 3)
+	if len(found) != 1 || len(missing) != 2 {
+		t.Errorf("after Delete: want 1 found and 2 missing, got %v %v", found, missing)
+	}
+
+	if found, missing, err := users.GetMany(ctx); err != nil || len(found) != 0 || len(missing) != 0 {
+		t.Errorf("GetMany without keys: want empty result, got %v %v %v", found, missing, err)
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	users := cache.CreateKvCacheWithOptions[user, int64](client, time.Minute, nil, cache.WithNegativeCache(100*time.Millisecond))
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context, id int64) (*user, error) {
+		calls.Add(1)
+		return nil, cache.ErrNotFound
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := users.GetOrLoad(ctx, 1, loader); !errors.Is(err, cache.ErrNotFound) {
+			t.Fatalf("GetOrLoad: want ErrNotFound, got %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("negative result should be cached, loader called %d times", n)
+	}
+	if _, err := users.Get(ctx, 1); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Get on tombstone: want ErrNotFound, got %v", err)
+	}
+	if found, missing, err := users.GetMany(ctx, 1); err != nil || len(found) != 0 || len(missing) != 1 {
+		t.Errorf("GetMany on tombstone: want missing, got %v %v %v", found, missing, err)
+	}
+	if ttl, err := users.TTL(ctx, 1); err != nil || ttl > 100*time.Millisecond {
+		t.Errorf("tombstone should use the negative expiration, got %s %v", ttl, err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, err := users.GetOrLoad(ctx, 1, loader); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("GetOrLoad: want ErrNotFound, got %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader should be called again after the tombstone expires, got %d calls", n)
+	}
+}
+
+func TestWithoutNegativeCache(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	users := cache.CreateKvCache[user, int64](client, time.Minute)
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context, id int64) (*user, error) {
+		calls.Add(1)
+		return nil, nil
+	}
+	for i := 0; i < 2; i++ {
+		_, _ = users.GetOrLoad(ctx, 1, loader)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("misses should not be cached by default, loader called %d times", n)
+	}
+	if n, _ := users.Exists(ctx, 1); n != 0 {
+		t.Errorf("nothing should be written for a miss, got %d keys", n)
+	}
+}
+
+// 包装后的缓存需要与被包装的缓存表现一致
+func TestWrapCommonCache(t *testing.T) {
+	s := cachetest.StartServer(t, cachetest.ServerOption{})
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	var ops atomic.Int64
+	counter := func(next cache.Handler) cache.Handler {
+		return func(ctx context.Context, op *cache.Operation) error {
+			ops.Add(1)
+			return next(ctx, op)
+		}
+	}
+	breaker := cache.NewCircuitBreaker(cache.BreakerOption{})
+	cachetest.TestCommonCache(t, func(t *testing.T, namespace string) cache.ICommonCache {
+		return cache.Wrap(cache_redis.NewCommonCache(client, namespace), breaker.Middleware(), counter)
+	})
+	if ops.Load() == 0 {
+		t.Error("operations should pass through the middleware")
+	}
+}
@@ -0,0 +1,1010 @@
+package cachetest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mengri/utils-store/cache/internal/glob"
+)
+
+// execution 一次命令执行的上下文, 执行期间持有 Server.lock
+type execution struct {
+	server *Server
+	db     *db
+	index  int
+	now    time.Time
+}
+
+// command arity 与Redis的约定一致: 正数为参数个数(包括命令名), 负数为最少参数个数
+type command struct {
+	arity int
+	fn    func(x *execution, args []string) any
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"PING":      {-1, cmdPing},
+		"ECHO":      {2, cmdEcho},
+		"HELLO":     {-1, cmdHello},
+		"AUTH":      {-2, cmdOK},
+		"CLIENT":    {-2, cmdOK},
+		"READONLY":  {1, cmdOK},
+		"READWRITE": {1, cmdOK},
+		"INFO":      {-1, cmdInfo},
+		"CLUSTER":   {-2, cmdCluster},
+		"DBSIZE":    {1, cmdDBSize},
+		"FLUSHDB":   {-1, cmdFlushDB},
+		"FLUSHALL":  {-1, cmdFlushAll},
+
+		"GET":    {2, cmdGet},
+		"SET":    {-3, cmdSet},
+		"SETNX":  {3, cmdSetNX},
+		"MGET":   {-2, cmdMGet},
+		"MSET":   {-3, cmdMSet},
+		"INCR":   {2, cmdIncr},
+		"DECR":   {2, cmdIncr},
+		"INCRBY": {3, cmdIncrBy},
+		"DECRBY": {3, cmdIncrBy},
+
+		"DEL":       {-2, cmdDel},
+		"UNLINK":    {-2, cmdDel},
+		"EXISTS":    {-2, cmdExists},
+		"TYPE":      {2, cmdType},
+		"TTL":       {2, cmdTTL},
+		"PTTL":      {2, cmdTTL},
+		"EXPIRE":    {-3, cmdExpire},
+		"PEXPIRE":   {-3, cmdExpire},
+		"EXPIREAT":  {-3, cmdExpire},
+		"PEXPIREAT": {-3, cmdExpire},
+		"PERSIST":   {2, cmdPersist},
+		"KEYS":      {2, cmdKeys},
+		"SCAN":      {-2, cmdScan},
+
+		"HSET":    {-4, cmdHSet},
+		"HMSET":   {-4, cmdHSet},
+		"HGET":    {3, cmdHGet},
+		"HGETALL": {2, cmdHGetAll},
+		"HDEL":    {-3, cmdHDel},
+		"HLEN":    {2, cmdHLen},
+		"HEXISTS": {3, cmdHExists},
+
+		"RPUSH":  {-3, cmdPush},
+		"LPUSH":  {-3, cmdPush},
+		"LRANGE": {4, cmdLRange},
+		"LTRIM":  {4, cmdLTrim},
+		"LLEN":   {2, cmdLLen},
+
+		"ZADD":             {-4, cmdZAdd},
+		"ZINCRBY":          {4, cmdZIncrBy},
+		"ZSCORE":           {3, cmdZScore},
+		"ZRANK":            {-3, cmdZRank},
+		"ZREVRANK":         {-3, cmdZRank},
+		"ZRANGE":           {-4, cmdZRange},
+		"ZREVRANGE":        {-4, cmdZRange},
+		"ZRANGEBYSCORE":    {-4, cmdZRangeByScore},
+		"ZREVRANGEBYSCORE": {-4, cmdZRangeByScore},
+		"ZREM":             {-3, cmdZRem},
+		"ZCARD":            {2, cmdZCard},
+
+		"XADD":       {-5, cmdXAdd},
+		"XLEN":       {2, cmdXLen},
+		"XRANGE":     {-4, cmdXRange},
+		"XGROUP":     {-2, cmdXGroup},
+		"XREADGROUP": {-7, cmdXReadGroup},
+		"XACK":       {-4, cmdXAck},
+		"XPENDING":   {-3, cmdXPending},
+		"XCLAIM":     {-6, cmdXClaim},
+
+		"EVAL":    {-3, cmdEval},
+		"EVALSHA": {-3, cmdEval},
+		"SCRIPT":  {-2, cmdScript},
+	}
+}
+
+func cmdOK(x *execution, args []string) any {
+	return replyOK
+}
+
+// cmdHello 不支持RESP3, 客户端收到错误后会继续使用RESP2
+func cmdHello(x *execution, args []string) any {
+	return errorReply("ERR unknown command 'HELLO'")
+}
+
+func cmdEcho(x *execution, args []string) any {
+	return args[1]
+}
+
+func cmdDBSize(x *execution, args []string) any {
+	return int64(len(x.db.keys(x.now)))
+}
+
+func cmdFlushDB(x *execution, args []string) any {
+	delete(x.server.dbs, x.index)
+	return replyOK
+}
+
+func cmdFlushAll(x *execution, args []string) any {
+	x.server.dbs = make(map[int]*db)
+	return replyOK
+}
+
+func cmdPing(x *execution, args []string) any {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return statusReply("PONG")
+}
+
+func cmdInfo(x *execution, args []string) any {
+	mode, enabled := "standalone", 0
+	if x.server.opt.Cluster {
+		mode, enabled = "cluster", 1
+	}
+	return fmt.Sprintf("# Server\r\nredis_version:7.2.0\r\nredis_mode:%s\r\n\r\n# Cluster\r\ncluster_enabled:%d\r\n", mode, enabled)
+}
+
+func cmdCluster(x *execution, args []string) any {
+	if !x.server.opt.Cluster {
+		return errorReply("ERR This instance has cluster support disabled")
+	}
+	host, port, err := x.server.nodeAddr()
+	if err != nil {
+		return err
+	}
+	switch strings.ToUpper(args[1]) {
+	case "SLOTS":
+		return []any{[]any{int64(0), int64(16383), []any{host, int64(port), x.server.id}}}
+	case "NODES":
+		return fmt.Sprintf("%s %s:%d@%d myself,master - 0 0 1 connected 0-16383\n", x.server.id, host, port, port+10000)
+	case "INFO":
+		return "cluster_state:ok\r\ncluster_slots_assigned:16384\r\ncluster_known_nodes:1\r\ncluster_size:1\r\n"
+	case "MYID":
+		return x.server.id
+	}
+	return errorReply("ERR unknown subcommand '" + args[1] + "'")
+}
+
+func cmdGet(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindString, x.now)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	return v.str
+}
+
+func cmdSet(x *execution, args []string) any {
+	key := args[1]
+	var (
+		expireAt       time.Time
+		nx, xx, get    bool
+		keepTTL, hasEx bool
+	)
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 >= len(args) || hasEx {
+				return errSyntax
+			}
+			i++
+			n, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil {
+				return errNotInteger
+			}
+			if n <= 0 {
+				return errorReply("ERR invalid expire time in 'set' command")
+			}
+			expireAt = expireTime(opt, n, x.now)
+			hasEx = true
+		default:
+			return errSyntax
+		}
+	}
+	if (nx && xx) || (keepTTL && hasEx) {
+		return errSyntax
+	}
+	old := x.db.get(key, x.now)
+	var oldReply any
+	if get && old != nil {
+		if old.kind != kindString {
+			return errWrongType
+		}
+		oldReply = old.str
+	}
+	if (nx && old != nil) || (xx && old == nil) {
+		if get {
+			return oldReply
+		}
+		return nil
+	}
+	v := &value{kind: kindString, str: args[2], expireAt: expireAt}
+	if keepTTL && old != nil {
+		v.expireAt = old.expireAt
+	}
+	x.db.values[key] = v
+	if get {
+		return oldReply
+	}
+	return replyOK
+}
+
+func expireTime(opt string, n int64, now time.Time) time.Time {
+	switch opt {
+	case "EX", "EXPIRE":
+		return now.Add(time.Duration(n) * time.Second)
+	case "PX", "PEXPIRE":
+		return now.Add(time.Duration(n) * time.Millisecond)
+	case "EXAT", "EXPIREAT":
+		return time.Unix(n, 0)
+	}
+	return time.UnixMilli(n)
+}
+
+func cmdSetNX(x *execution, args []string) any {
+	if x.db.get(args[1], x.now) != nil {
+		return int64(0)
+	}
+	x.db.values[args[1]] = &value{kind: kindString, str: args[2]}
+	return int64(1)
+}
+
+func cmdMGet(x *execution, args []string) any {
+	rs := make([]any, 0, len(args)-1)
+	for _, key := range args[1:] {
+		v := x.db.get(key, x.now)
+		if v == nil || v.kind != kindString {
+			rs = append(rs, nil)
+			continue
+		}
+		rs = append(rs, v.str)
+	}
+	return rs
+}
+
+func cmdMSet(x *execution, args []string) any {
+	if len(args)%2 != 1 {
+		return errArgs(args[0])
+	}
+	for i := 1; i < len(args); i += 2 {
+		x.db.values[args[i]] = &value{kind: kindString, str: args[i+1]}
+	}
+	return replyOK
+}
+
+func cmdIncr(x *execution, args []string) any {
+	if strings.ToUpper(args[0]) == "DECR" {
+		return x.incrBy(args[1], -1)
+	}
+	return x.incrBy(args[1], 1)
+}
+
+func cmdIncrBy(x *execution, args []string) any {
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	if strings.ToUpper(args[0]) == "DECRBY" {
+		n = -n
+	}
+	return x.incrBy(args[1], n)
+}
+
+func (x *execution) incrBy(key string, n int64) any {
+	v, err := x.db.lookup(key, kindString, x.now)
+	if err != nil {
+		return err
+	}
+	var current int64
+	if v != nil {
+		current, err = strconv.ParseInt(v.str, 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+	} else {
+		v = &value{kind: kindString}
+		x.db.values[key] = v
+	}
+	if (n > 0 && current > math.MaxInt64-n) || (n < 0 && current < math.MinInt64-n) {
+		return errorReply("ERR increment or decrement would overflow")
+	}
+	current += n
+	v.str = strconv.FormatInt(current, 10)
+	return current
+}
+
+func cmdDel(x *execution, args []string) any {
+	var n int64
+	for _, key := range args[1:] {
+		if x.db.get(key, x.now) != nil {
+			delete(x.db.values, key)
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExists(x *execution, args []string) any {
+	var n int64
+	for _, key := range args[1:] {
+		if x.db.get(key, x.now) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdType(x *execution, args []string) any {
+	v := x.db.get(args[1], x.now)
+	if v == nil {
+		return statusReply("none")
+	}
+	return statusReply(v.kind.String())
+}
+
+func cmdTTL(x *execution, args []string) any {
+	v := x.db.get(args[1], x.now)
+	if v == nil {
+		return int64(-2)
+	}
+	if v.expireAt.IsZero() {
+		return int64(-1)
+	}
+	ms := v.expireAt.Sub(x.now).Milliseconds()
+	if strings.ToUpper(args[0]) == "TTL" {
+		return (ms + 500) / 1000
+	}
+	return ms
+}
+
+// cmdExpire 实现 EXPIRE/PEXPIRE/EXPIREAT/PEXPIREAT 及 NX/XX/GT/LT 选项, 过期时间已过去时删除key
+func cmdExpire(x *execution, args []string) any {
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	v := x.db.get(args[1], x.now)
+	if v == nil {
+		return int64(0)
+	}
+	expireAt := expireTime(strings.ToUpper(args[0]), n, x.now)
+	for _, opt := range args[3:] {
+		ok := true
+		switch strings.ToUpper(opt) {
+		case "NX":
+			ok = v.expireAt.IsZero()
+		case "XX":
+			ok = !v.expireAt.IsZero()
+		case "GT":
+			ok = !v.expireAt.IsZero() && expireAt.After(v.expireAt)
+		case "LT":
+			ok = v.expireAt.IsZero() || expireAt.Before(v.expireAt)
+		default:
+			return errorReply("ERR Unsupported option " + opt)
+		}
+		if !ok {
+			return int64(0)
+		}
+	}
+	if !expireAt.After(x.now) {
+		delete(x.db.values, args[1])
+		return int64(1)
+	}
+	v.expireAt = expireAt
+	return int64(1)
+}
+
+func cmdPersist(x *execution, args []string) any {
+	v := x.db.get(args[1], x.now)
+	if v == nil || v.expireAt.IsZero() {
+		return int64(0)
+	}
+	v.expireAt = time.Time{}
+	return int64(1)
+}
+
+func cmdKeys(x *execution, args []string) any {
+	rs := make([]string, 0)
+	for _, key := range x.db.keys(x.now) {
+		if glob.Match(args[1], key) {
+			rs = append(rs, key)
+		}
+	}
+	return rs
+}
+
+// cmdScan 游标为按key排序后的位置, 遍历期间新增的key可能被跳过, 与Redis的保证一致
+func cmdScan(x *execution, args []string) any {
+	cursor, err := strconv.Atoi(args[1])
+	if err != nil || cursor < 0 {
+		return errorReply("ERR invalid cursor")
+	}
+	pattern, count, typ := "*", 10, ""
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count < 1 {
+				return errSyntax
+			}
+		case "TYPE":
+			typ = strings.ToLower(args[i+1])
+		default:
+			return errSyntax
+		}
+	}
+	keys := x.db.keys(x.now)
+	start := 0
+	if cursor > 0 {
+		if cursor > len(x.db.cursors) {
+			return errorReply("ERR invalid cursor")
+		}
+		last := x.db.cursors[cursor-1]
+		start = sort.SearchStrings(keys, last)
+		if start < len(keys) && keys[start] == last {
+			start++
+		}
+	}
+	end := min(start+count, len(keys))
+	rs := make([]string, 0)
+	for i := start; i < end; i++ {
+		v := x.db.values[keys[i]]
+		if glob.Match(pattern, keys[i]) && (typ == "" || v.kind.String() == typ) {
+			rs = append(rs, keys[i])
+		}
+	}
+	next := "0"
+	if end < len(keys) {
+		x.db.cursors = append(x.db.cursors, keys[end-1])
+		next = strconv.Itoa(len(x.db.cursors))
+	}
+	return []any{next, rs}
+}
+
+func cmdHSet(x *execution, args []string) any {
+	if len(args)%2 != 0 {
+		return errArgs(args[0])
+	}
+	v, err := x.db.lookupOrCreate(args[1], kindHash, x.now)
+	if err != nil {
+		return err
+	}
+	var added int64
+	for i := 2; i < len(args); i += 2 {
+		if _, has := v.hash[args[i]]; !has {
+			added++
+		}
+		v.hash[args[i]] = args[i+1]
+	}
+	if strings.ToUpper(args[0]) == "HMSET" {
+		return replyOK
+	}
+	return added
+}
+
+func cmdHGet(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindHash, x.now)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	f, has := v.hash[args[2]]
+	if !has {
+		return nil
+	}
+	return f
+}
+
+func cmdHGetAll(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindHash, x.now)
+	if err != nil {
+		return err
+	}
+	rs := make([]string, 0)
+	if v == nil {
+		return rs
+	}
+	for f, fv := range v.hash {
+		rs = append(rs, f, fv)
+	}
+	return rs
+}
+
+func cmdHDel(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindHash, x.now)
+	if err != nil || v == nil {
+		return zeroOr(err)
+	}
+	var n int64
+	for _, f := range args[2:] {
+		if _, has := v.hash[f]; has {
+			delete(v.hash, f)
+			n++
+		}
+	}
+	x.db.removeIfEmpty(args[1], v)
+	return n
+}
+
+func cmdHLen(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindHash, x.now)
+	if err != nil || v == nil {
+		return zeroOr(err)
+	}
+	return int64(len(v.hash))
+}
+
+func cmdHExists(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindHash, x.now)
+	if err != nil || v == nil {
+		return zeroOr(err)
+	}
+	if _, has := v.hash[args[2]]; has {
+		return int64(1)
+	}
+	return int64(0)
+}
+
+func zeroOr(err error) any {
+	if err != nil {
+		return err
+	}
+	return int64(0)
+}
+
+func cmdPush(x *execution, args []string) any {
+	v, err := x.db.lookupOrCreate(args[1], kindList, x.now)
+	if err != nil {
+		return err
+	}
+	if strings.ToUpper(args[0]) == "RPUSH" {
+		v.list = append(v.list, args[2:]...)
+		return int64(len(v.list))
+	}
+	list := make([]string, 0, len(v.list)+len(args)-2)
+	for i := len(args) - 1; i >= 2; i-- {
+		list = append(list, args[i])
+	}
+	v.list = append(list, v.list...)
+	return int64(len(v.list))
+}
+
+// listRange 按Redis的规则转换负数下标, 返回左闭右开的区间
+func listRange(length int, startArg, stopArg string) (int, int, error) {
+	start, err := strconv.Atoi(startArg)
+	if err != nil {
+		return 0, 0, errNotInteger
+	}
+	stop, err := strconv.Atoi(stopArg)
+	if err != nil {
+		return 0, 0, errNotInteger
+	}
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	stop = min(stop, length-1)
+	if start > stop || start >= length {
+		return 0, 0, nil
+	}
+	return start, stop + 1, nil
+}
+
+func cmdLRange(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindList, x.now)
+	if err != nil {
+		return err
+	}
+	rs := make([]string, 0)
+	if v == nil {
+		return rs
+	}
+	start, end, err := listRange(len(v.list), args[2], args[3])
+	if err != nil {
+		return err
+	}
+	return append(rs, v.list[start:end]...)
+}
+
+func cmdLTrim(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindList, x.now)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return replyOK
+	}
+	start, end, err := listRange(len(v.list), args[2], args[3])
+	if err != nil {
+		return err
+	}
+	v.list = append([]string(nil), v.list[start:end]...)
+	x.db.removeIfEmpty(args[1], v)
+	return replyOK
+}
+
+func cmdLLen(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindList, x.now)
+	if err != nil || v == nil {
+		return zeroOr(err)
+	}
+	return int64(len(v.list))
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func cmdZAdd(x *execution, args []string) any {
+	var nx, xx, gt, lt, ch bool
+	i := 2
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		default:
+			break flags
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 || (nx && xx) || (gt && lt) || (nx && (gt || lt)) {
+		return errSyntax
+	}
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := parseFloat(pairs[j])
+		if err != nil {
+			return err
+		}
+		scores = append(scores, score)
+	}
+	v, err := x.db.lookupOrCreate(args[1], kindZSet, x.now)
+	if err != nil {
+		return err
+	}
+	var added, changed int64
+	for j := 0; j < len(pairs); j += 2 {
+		member, score := pairs[j+1], scores[j/2]
+		old, has := v.zset[member]
+		switch {
+		case (nx && has) || (xx && !has):
+			continue
+		case has && ((gt && score <= old) || (lt && score >= old)):
+			continue
+		}
+		if !has {
+			added++
+		} else if old != score {
+			changed++
+		}
+		v.zset[member] = score
+	}
+	x.db.removeIfEmpty(args[1], v)
+	if ch {
+		return added + changed
+	}
+	return added
+}
+
+func cmdZIncrBy(x *execution, args []string) any {
+	incr, err := parseFloat(args[2])
+	if err != nil {
+		return err
+	}
+	v, err := x.db.lookupOrCreate(args[1], kindZSet, x.now)
+	if err != nil {
+		return err
+	}
+	score := v.zset[args[3]] + incr
+	if math.IsNaN(score) {
+		x.db.removeIfEmpty(args[1], v)
+		return errorReply("ERR resulting score is not a number (NaN)")
+	}
+	v.zset[args[3]] = score
+	return formatFloat(score)
+}
+
+func cmdZScore(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindZSet, x.now)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	score, has := v.zset[args[2]]
+	if !has {
+		return nil
+	}
+	return formatFloat(score)
+}
+
+func cmdZRank(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindZSet, x.now)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	members := v.sorted()
+	for i, m := range members {
+		if m.member != args[2] {
+			continue
+		}
+		rank := int64(i)
+		if strings.ToUpper(args[0]) == "ZREVRANK" {
+			rank = int64(len(members) - 1 - i)
+		}
+		return rank
+	}
+	return nil
+}
+
+func zreply(members []zmember, withScores bool) []string {
+	rs := make([]string, 0, len(members)*2)
+	for _, m := range members {
+		rs = append(rs, m.member)
+		if withScores {
+			rs = append(rs, formatFloat(m.score))
+		}
+	}
+	return rs
+}
+
+func reverse(members []zmember) {
+	for i, j := 0, len(members)-1; i < j; i, j = i+1, j-1 {
+		members[i], members[j] = members[j], members[i]
+	}
+}
+
+// cmdZRange 支持 ZRANGE key start stop [REV] [WITHSCORES] 和 ZREVRANGE, 不支持 BYSCORE/BYLEX
+func cmdZRange(x *execution, args []string) any {
+	rev := strings.ToUpper(args[0]) == "ZREVRANGE"
+	withScores := false
+	for _, opt := range args[4:] {
+		switch strings.ToUpper(opt) {
+		case "WITHSCORES":
+			withScores = true
+		case "REV":
+			rev = true
+		default:
+			return errSyntax
+		}
+	}
+	v, err := x.db.lookup(args[1], kindZSet, x.now)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return []string{}
+	}
+	members := v.sorted()
+	if rev {
+		reverse(members)
+	}
+	start, end, err := listRange(len(members), args[2], args[3])
+	if err != nil {
+		return err
+	}
+	return zreply(members[start:end], withScores)
+}
+
+// scoreBound 解析 ZRANGEBYSCORE 的区间, 支持 -inf/+inf 和 ( 开区间
+func scoreBound(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	f, err := parseFloat(strings.TrimPrefix(s, "("))
+	if err != nil {
+		return 0, false, errorReply("ERR min or max is not a float")
+	}
+	return f, exclusive, nil
+}
+
+func cmdZRangeByScore(x *execution, args []string) any {
+	rev := strings.ToUpper(args[0]) == "ZREVRANGEBYSCORE"
+	minArg, maxArg := args[2], args[3]
+	if rev {
+		minArg, maxArg = maxArg, minArg
+	}
+	minScore, minEx, err := scoreBound(minArg)
+	if err != nil {
+		return err
+	}
+	maxScore, maxEx, err := scoreBound(maxArg)
+	if err != nil {
+		return err
+	}
+	withScores, offset, count := false, 0, -1
+	for i := 4; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return errSyntax
+			}
+			if offset, err = strconv.Atoi(args[i+1]); err != nil {
+				return errNotInteger
+			}
+			if count, err = strconv.Atoi(args[i+2]); err != nil {
+				return errNotInteger
+			}
+			i += 2
+		default:
+			return errSyntax
+		}
+	}
+	v, err := x.db.lookup(args[1], kindZSet, x.now)
+	if err != nil {
+		return err
+	}
+	if v == nil || offset < 0 {
+		return []string{}
+	}
+	members := v.sorted()
+	if rev {
+		reverse(members)
+	}
+	matched := make([]zmember, 0)
+	for _, m := range members {
+		if m.score < minScore || m.score > maxScore || (minEx && m.score == minScore) || (maxEx && m.score == maxScore) {
+			continue
+		}
+		matched = append(matched, m)
+	}
+	if offset >= len(matched) {
+		return []string{}
+	}
+	matched = matched[offset:]
+	if count >= 0 && count < len(matched) {
+		matched = matched[:count]
+	}
+	return zreply(matched, withScores)
+}
+
+func cmdZRem(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindZSet, x.now)
+	if err != nil || v == nil {
+		return zeroOr(err)
+	}
+	var n int64
+	for _, m := range args[2:] {
+		if _, has := v.zset[m]; has {
+			delete(v.zset, m)
+			n++
+		}
+	}
+	x.db.removeIfEmpty(args[1], v)
+	return n
+}
+
+func cmdZCard(x *execution, args []string) any {
+	v, err := x.db.lookup(args[1], kindZSet, x.now)
+	if err != nil || v == nil {
+		return zeroOr(err)
+	}
+	return int64(len(v.zset))
+}
+
+func scriptHash(src string) string {
+	h := sha1.Sum([]byte(src))
+	return hex.EncodeToString(h[:])
+}
+
+func cmdEval(x *execution, args []string) any {
+	sha := strings.ToLower(args[1])
+	if strings.ToUpper(args[0]) == "EVAL" {
+		sha = scriptHash(args[1])
+	}
+	fn, has := x.server.scripts[sha]
+	if !has {
+		if strings.ToUpper(args[0]) == "EVALSHA" {
+			return errorReply("NOSCRIPT No matching script. Please use EVAL.")
+		}
+		return errorReply("ERR cachetest: script " + sha + " is not registered, use Server.HandleScript")
+	}
+	numKeys, err := strconv.Atoi(args[2])
+	if err != nil || numKeys < 0 {
+		return errNotInteger
+	}
+	if numKeys > len(args)-3 {
+		return errorReply("ERR Number of keys can't be greater than number of args")
+	}
+	keys, argv := args[3:3+numKeys], args[3+numKeys:]
+	call := func(args ...string) (any, error) {
+		if len(args) == 0 {
+			return nil, errorReply("ERR Please specify at least one argument for this redis lib call")
+		}
+		if err := checkCommand(strings.ToUpper(args[0]), args); err != nil {
+			return nil, err
+		}
+		rs := x.server.call(x.index, args)
+		if _, ok := rs.(blockReply); ok {
+			return nil, nil
+		}
+		if err, ok := rs.(error); ok {
+			return nil, err
+		}
+		return rs, nil
+	}
+	rs, err := fn(call, keys, argv)
+	if err != nil {
+		if e, ok := err.(errorReply); ok {
+			return e
+		}
+		return errorReply("ERR " + err.Error())
+	}
+	return rs
+}
+
+func cmdScript(x *execution, args []string) any {
+	switch strings.ToUpper(args[1]) {
+	case "LOAD":
+		if len(args) != 3 {
+			return errArgs("script|load")
+		}
+		return scriptHash(args[2])
+	case "EXISTS":
+		rs := make([]any, 0, len(args)-2)
+		for _, sha := range args[2:] {
+			if _, has := x.server.scripts[strings.ToLower(sha)]; has {
+				rs = append(rs, int64(1))
+			} else {
+				rs = append(rs, int64(0))
+			}
+		}
+		return rs
+	case "FLUSH":
+		return replyOK
+	}
+	return errorReply("ERR unknown subcommand '" + args[1] + "'")
+}
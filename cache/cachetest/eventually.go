@@ -0,0 +1,18 @@
+package cachetest
+
+import (
+	"testing"
+	"time"
+)
+
+// Eventually 在超时前反复检查 fn, 用于等待失效通知、后台消费等异步操作
+func Eventually(t testing.TB, what string, fn func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
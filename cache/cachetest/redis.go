@@ -0,0 +1,46 @@
+package cachetest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_redis"
+)
+
+// RedisAddrEnv 真实Redis的地址, 进程内服务不执行Lua, 设置后可以通过 ConnectRedis 对随代码发布的脚本运行测试
+const RedisAddrEnv = "CACHETEST_REDIS_ADDR"
+
+// ConnectRedis 连接 RedisAddrEnv 指定的Redis, 测试结束时关闭, 未设置时返回false
+// 多个测试共用同一个Redis, 调用方需要使用不重复的key前缀并自行清理
+func ConnectRedis(t testing.TB) (redis.UniversalClient, bool) {
+	t.Helper()
+	addr := os.Getenv(RedisAddrEnv)
+	if addr == "" {
+		return nil, false
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close()
+		t.Fatalf("connect %s=%s error:%s", RedisAddrEnv, addr, err.Error())
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client, true
+}
+
+// NewRedisCache 启动进程内服务并创建以 test 为前缀的 cache_redis 缓存, 测试结束时关闭
+// 客户端不重试, 服务关闭后操作立即返回错误
+func NewRedisCache(t testing.TB) (cache.ICommonCache, *Server) {
+	t.Helper()
+	s := StartServer(t, ServerOption{})
+	client := redis.NewClient(&redis.Options{Addr: s.Addr(), MaxRetries: -1})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return cache_redis.NewCommonCache(client, "test"), s
+}
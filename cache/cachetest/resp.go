@@ -0,0 +1,122 @@
+package cachetest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// statusReply 简单字符串回复, 如 +OK
+type statusReply string
+
+// errorReply 错误回复, 内容需要包含错误前缀, 如 ERR、WRONGTYPE
+type errorReply string
+
+func (e errorReply) Error() string {
+	return string(e)
+}
+
+var (
+	replyOK    = statusReply("OK")
+	replyQueue = statusReply("QUEUED")
+
+	errSyntax     = errorReply("ERR syntax error")
+	errNotInteger = errorReply("ERR value is not an integer or out of range")
+	errNotFloat   = errorReply("ERR value is not a valid float")
+	errWrongType  = errorReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+)
+
+func errArgs(cmd string) errorReply {
+	return errorReply(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+}
+
+// readCommand 读取客户端发送的命令, 客户端总是以bulk string数组的形式发送命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		// 兼容 redis-cli 等工具发送的inline命令
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid multibulk length %q", line)
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("expected bulk string, got %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(line, "\r\n") {
+		return "", errors.New("protocol error: line without CRLF")
+	}
+	return line[:len(line)-2], nil
+}
+
+// writeReply 按RESP2编码回复, 支持的类型与 ScriptFunc 的返回值一致
+func writeReply(w *bufio.Writer, v any) {
+	switch r := v.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case statusReply:
+		fmt.Fprintf(w, "+%s\r\n", string(r))
+	case errorReply:
+		fmt.Fprintf(w, "-%s\r\n", string(r))
+	case error:
+		fmt.Fprintf(w, "-ERR %s\r\n", r.Error())
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", r)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", r)
+	case bool:
+		// 与Lua脚本的返回值规则一致, true 为1, false 为nil
+		if r {
+			w.WriteString(":1\r\n")
+		} else {
+			w.WriteString("$-1\r\n")
+		}
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(r), r)
+	case []byte:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(r), r)
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(r))
+		for _, s := range r {
+			writeReply(w, s)
+		}
+	case []any:
+		fmt.Fprintf(w, "*%d\r\n", len(r))
+		for _, item := range r {
+			writeReply(w, item)
+		}
+	default:
+		fmt.Fprintf(w, "-ERR unsupported reply type %T\r\n", v)
+	}
+}
@@ -0,0 +1,63 @@
+package cachetest
+
+import (
+	"strconv"
+
+	"github.com/mengri/utils-store/cache/internal/scripts"
+)
+
+// ScriptFunc 脚本的Go实现, call 与Lua中的 redis.call 相同, 在同一个锁内执行
+// call 的返回值: 整数为int64, bulk string 为string, 数组为[]any或[]string, 不存在为nil, 状态回复(如OK)为非string类型
+// 返回值按Redis的规则编码: int64为整数, string为bulk string, nil为空值, []any为数组, bool true为1、false为空值
+type ScriptFunc func(call func(args ...string) (any, error), keys []string, args []string) (any, error)
+
+// HandleScript 注册脚本的Go实现, 通过脚本源码的SHA1匹配 EVAL/EVALSHA
+func (s *Server) HandleScript(src string, fn ScriptFunc) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.scripts[scriptHash(src)] = fn
+}
+
+// registerBuiltinScripts 注册 cache_redis 使用的脚本, 与 cache_redis 共用 internal/scripts 中的源码
+func registerBuiltinScripts(s *Server) {
+	s.scripts[scriptHash(scripts.CompareAndDelete)] = func(call func(args ...string) (any, error), keys []string, args []string) (any, error) {
+		v, err := call("GET", keys[0])
+		if err != nil {
+			return nil, err
+		}
+		if v != args[0] {
+			return int64(0), nil
+		}
+		return call("DEL", keys[0])
+	}
+	s.scripts[scriptHash(scripts.IncrByEx)] = func(call func(args ...string) (any, error), keys []string, args []string) (any, error) {
+		v, err := call("INCRBY", keys[0], args[0])
+		if err != nil {
+			return nil, err
+		}
+		expiration, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || expiration <= 0 {
+			return v, nil
+		}
+		if args[2] != "1" {
+			ttl, err := call("PTTL", keys[0])
+			if err != nil || ttl.(int64) >= 0 {
+				return v, err
+			}
+		}
+		if _, err := call("PEXPIRE", keys[0], args[1]); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	s.scripts[scriptHash(scripts.CompareAndExpire)] = func(call func(args ...string) (any, error), keys []string, args []string) (any, error) {
+		v, err := call("GET", keys[0])
+		if err != nil {
+			return nil, err
+		}
+		if v != args[0] {
+			return int64(0), nil
+		}
+		return call("PEXPIRE", keys[0], args[1])
+	}
+}
@@ -0,0 +1,454 @@
+package cachetest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache/internal/glob"
+)
+
+// ServerOption 进程内Redis服务的配置
+type ServerOption struct {
+	// Addr 监听地址, 默认 127.0.0.1:0
+	Addr string
+	// Cluster 以单节点集群的方式响应 INFO 和 CLUSTER 命令, 所有slot都由当前节点负责
+	Cluster bool
+}
+
+// Server 进程内的RESP服务, 在单元测试中代替Redis
+// 支持 string/hash/list/sorted set/stream、过期、SCAN、MULTI/EXEC 和 pub/sub, 不支持Lua, 脚本需要通过 HandleScript 注册Go实现,
+// 脚本本身需要通过 ConnectRedis 在真实的Redis上测试
+// 所有命令在同一个锁内串行执行, WATCH 不会使事务失败
+type Server struct {
+	opt      ServerOption
+	listener net.Listener
+	id       string
+
+	lock    sync.Mutex
+	dbs     map[int]*db
+	scripts map[string]ScriptFunc
+
+	connLock sync.Mutex
+	conns    map[*conn]struct{}
+	channels map[string]map[*conn]struct{}
+	patterns map[string]map[*conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer 启动服务, 使用完成后需要调用 Close
+func NewServer(opt ServerOption) (*Server, error) {
+	if opt.Addr == "" {
+		opt.Addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", opt.Addr)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 20)
+	_, _ = rand.Read(id)
+	s := &Server{
+		opt:      opt,
+		listener: listener,
+		id:       hex.EncodeToString(id),
+		dbs:      make(map[int]*db),
+		scripts:  make(map[string]ScriptFunc),
+		conns:    make(map[*conn]struct{}),
+		channels: make(map[string]map[*conn]struct{}),
+		patterns: make(map[string]map[*conn]struct{}),
+	}
+	registerBuiltinScripts(s)
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// StartServer 启动服务并在测试结束时关闭, 启动失败时终止测试
+func StartServer(t testing.TB, opt ServerOption) *Server {
+	t.Helper()
+	s, err := NewServer(opt)
+	if err != nil {
+		t.Fatalf("start cachetest server error:%s", err.Error())
+	}
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return s
+}
+
+// Addr 返回监听地址, 可以直接作为 cache_redis.Option 的地址
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// FlushAll 清空所有数据
+func (s *Server) FlushAll() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.dbs = make(map[int]*db)
+}
+
+func (s *Server) Close() error {
+	s.connLock.Lock()
+	if s.closed {
+		s.connLock.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.listener.Close()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.connLock.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) db(index int) *db {
+	d, has := s.dbs[index]
+	if !has {
+		d = newDB()
+		s.dbs[index] = d
+	}
+	return d
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{Conn: nc, w: bufio.NewWriter(nc)}
+		s.connLock.Lock()
+		if s.closed {
+			s.connLock.Unlock()
+			_ = nc.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.connLock.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(c)
+	}
+}
+
+type conn struct {
+	net.Conn
+	wlock sync.Mutex
+	w     *bufio.Writer
+
+	db       int
+	multi    bool
+	multiErr bool
+	queued   [][]string
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+func (c *conn) write(replies ...any) {
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+	for _, r := range replies {
+		writeReply(c.w, r)
+	}
+	_ = c.w.Flush()
+}
+
+func (c *conn) subscribed() bool {
+	return len(c.channels)+len(c.patterns) > 0
+}
+
+func (s *Server) serve(c *conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.unsubscribeAll(c)
+		s.connLock.Lock()
+		delete(s.conns, c)
+		s.connLock.Unlock()
+		_ = c.Close()
+	}()
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		name := strings.ToUpper(args[0])
+		c.write(s.dispatch(c, name, args)...)
+		if name == "QUIT" {
+			return
+		}
+	}
+}
+
+// dispatch 处理连接相关的命令(事务、pub/sub、SELECT), 其余命令交给命令表执行
+func (s *Server) dispatch(c *conn, name string, args []string) []any {
+	if c.subscribed() {
+		switch name {
+		case "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE", "QUIT":
+		case "PING":
+			return []any{[]any{"pong", ""}}
+		default:
+			return []any{errorReply("ERR Can't execute '" + strings.ToLower(name) + "': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")}
+		}
+	}
+	if c.multi {
+		switch name {
+		case "EXEC", "DISCARD", "MULTI", "WATCH":
+		default:
+			if err := checkCommand(name, args); err != nil {
+				c.multiErr = true
+				return []any{err}
+			}
+			c.queued = append(c.queued, args)
+			return []any{replyQueue}
+		}
+	}
+
+	switch name {
+	case "QUIT":
+		return []any{replyOK}
+	case "SELECT":
+		if len(args) != 2 {
+			return []any{errArgs(name)}
+		}
+		index, err := strconv.Atoi(args[1])
+		if err != nil || index < 0 {
+			return []any{errorReply("ERR DB index is out of range")}
+		}
+		c.db = index
+		return []any{replyOK}
+	case "MULTI":
+		if c.multi {
+			return []any{errorReply("ERR MULTI calls can not be nested")}
+		}
+		c.multi, c.multiErr, c.queued = true, false, nil
+		return []any{replyOK}
+	case "DISCARD":
+		if !c.multi {
+			return []any{errorReply("ERR DISCARD without MULTI")}
+		}
+		c.multi, c.multiErr, c.queued = false, false, nil
+		return []any{replyOK}
+	case "EXEC":
+		return []any{s.exec(c)}
+	case "WATCH", "UNWATCH":
+		if c.multi {
+			return []any{errorReply("ERR WATCH inside MULTI is not allowed")}
+		}
+		return []any{replyOK}
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		return s.subscribe(c, name == "PSUBSCRIBE", args)
+	case "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		return s.unsubscribe(c, name == "PUNSUBSCRIBE", args)
+	case "PUBLISH":
+		if len(args) != 3 {
+			return []any{errArgs(name)}
+		}
+		return []any{s.publish(args[1], args[2])}
+	}
+
+	if err := checkCommand(name, args); err != nil {
+		return []any{err}
+	}
+	s.lock.Lock()
+	rs := s.call(c.db, args)
+	s.lock.Unlock()
+	if block, ok := rs.(blockReply); ok {
+		return []any{s.block(c.db, args, time.Duration(block))}
+	}
+	return []any{rs}
+}
+
+// block 释放锁后反复执行阻塞命令, 直到有结果、超时或服务关闭, timeout 为0时一直等待
+func (s *Server) block(index int, args []string, timeout time.Duration) any {
+	deadline := time.Now().Add(timeout)
+	for timeout == 0 || time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		s.connLock.Lock()
+		closed := s.closed
+		s.connLock.Unlock()
+		if closed {
+			return nil
+		}
+		s.lock.Lock()
+		rs := s.call(index, args)
+		s.lock.Unlock()
+		if _, ok := rs.(blockReply); !ok {
+			return rs
+		}
+	}
+	return nil
+}
+
+func (s *Server) exec(c *conn) any {
+	if !c.multi {
+		return errorReply("ERR EXEC without MULTI")
+	}
+	queued, failed := c.queued, c.multiErr
+	c.multi, c.multiErr, c.queued = false, false, nil
+	if failed {
+		return errorReply("EXECABORT Transaction discarded because of previous errors.")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	replies := make([]any, 0, len(queued))
+	for _, args := range queued {
+		rs := s.call(c.db, args)
+		// 与Redis一致, 事务中的阻塞命令不会阻塞
+		if _, ok := rs.(blockReply); ok {
+			rs = nil
+		}
+		replies = append(replies, rs)
+	}
+	return replies
+}
+
+// call 执行命令表中的命令, 调用时需持有 s.lock
+func (s *Server) call(index int, args []string) any {
+	cmd := commands[strings.ToUpper(args[0])]
+	return cmd.fn(&execution{server: s, db: s.db(index), index: index, now: time.Now()}, args)
+}
+
+func checkCommand(name string, args []string) error {
+	cmd, has := commands[name]
+	if !has {
+		return errorReply("ERR unknown command '" + args[0] + "'")
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		return errArgs(name)
+	}
+	return nil
+}
+
+func (s *Server) subscribe(c *conn, pattern bool, args []string) []any {
+	if len(args) < 2 {
+		return []any{errArgs(args[0])}
+	}
+	kind := "subscribe"
+	if pattern {
+		kind = "psubscribe"
+	}
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	replies := make([]any, 0, len(args)-1)
+	for _, ch := range args[1:] {
+		own, all := &c.channels, s.channels
+		if pattern {
+			own, all = &c.patterns, s.patterns
+		}
+		if *own == nil {
+			*own = make(map[string]struct{})
+		}
+		(*own)[ch] = struct{}{}
+		if all[ch] == nil {
+			all[ch] = make(map[*conn]struct{})
+		}
+		all[ch][c] = struct{}{}
+		replies = append(replies, []any{kind, ch, int64(len(c.channels) + len(c.patterns))})
+	}
+	return replies
+}
+
+func (s *Server) unsubscribe(c *conn, pattern bool, args []string) []any {
+	kind := "unsubscribe"
+	if pattern {
+		kind = "punsubscribe"
+	}
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	own, all := c.channels, s.channels
+	if pattern {
+		own, all = c.patterns, s.patterns
+	}
+	names := args[1:]
+	if len(names) == 0 {
+		for ch := range own {
+			names = append(names, ch)
+		}
+		if len(names) == 0 {
+			return []any{[]any{kind, nil, int64(len(c.channels) + len(c.patterns))}}
+		}
+	}
+	replies := make([]any, 0, len(names))
+	for _, ch := range names {
+		delete(own, ch)
+		delete(all[ch], c)
+		if len(all[ch]) == 0 {
+			delete(all, ch)
+		}
+		replies = append(replies, []any{kind, ch, int64(len(c.channels) + len(c.patterns))})
+	}
+	return replies
+}
+
+func (s *Server) unsubscribeAll(c *conn) {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	for ch := range c.channels {
+		delete(s.channels[ch], c)
+		if len(s.channels[ch]) == 0 {
+			delete(s.channels, ch)
+		}
+	}
+	for p := range c.patterns {
+		delete(s.patterns[p], c)
+		if len(s.patterns[p]) == 0 {
+			delete(s.patterns, p)
+		}
+	}
+}
+
+// publish 在锁外推送消息, 避免慢的订阅者阻塞其他连接
+func (s *Server) publish(channel, message string) int64 {
+	type delivery struct {
+		c   *conn
+		msg []any
+	}
+	var deliveries []delivery
+	s.connLock.Lock()
+	for c := range s.channels[channel] {
+		deliveries = append(deliveries, delivery{c: c, msg: []any{"message", channel, message}})
+	}
+	for p, subs := range s.patterns {
+		if !glob.Match(p, channel) {
+			continue
+		}
+		for c := range subs {
+			deliveries = append(deliveries, delivery{c: c, msg: []any{"pmessage", p, channel, message}})
+		}
+	}
+	s.connLock.Unlock()
+
+	for _, d := range deliveries {
+		d.c.write(d.msg)
+	}
+	return int64(len(deliveries))
+}
+
+// nodeAddr 集群模式下返回给客户端的节点地址
+func (s *Server) nodeAddr() (string, int, error) {
+	host, port, err := net.SplitHostPort(s.Addr())
+	if err != nil {
+		return "", 0, err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", 0, errors.New("invalid listen port " + port)
+	}
+	return host, p, nil
+}
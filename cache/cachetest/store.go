@@ -0,0 +1,152 @@
+package cachetest
+
+import (
+	"sort"
+	"time"
+)
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindHash
+	kindList
+	kindZSet
+	kindStream
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindHash:
+		return "hash"
+	case kindList:
+		return "list"
+	case kindZSet:
+		return "zset"
+	case kindStream:
+		return "stream"
+	}
+	return "none"
+}
+
+type value struct {
+	kind     kind
+	str      string
+	hash     map[string]string
+	list     []string
+	zset     map[string]float64
+	stream   *streamValue
+	expireAt time.Time
+}
+
+func (v *value) expired(now time.Time) bool {
+	return !v.expireAt.IsZero() && !now.Before(v.expireAt)
+}
+
+// empty 集合类型的值为空时需要删除key, 与Redis保持一致
+func (v *value) empty() bool {
+	switch v.kind {
+	case kindHash:
+		return len(v.hash) == 0
+	case kindList:
+		return len(v.list) == 0
+	case kindZSet:
+		return len(v.zset) == 0
+	}
+	return false
+}
+
+type zmember struct {
+	member string
+	score  float64
+}
+
+func (v *value) sorted() []zmember {
+	members := make([]zmember, 0, len(v.zset))
+	for m, s := range v.zset {
+		members = append(members, zmember{member: m, score: s})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].score != members[j].score {
+			return members[i].score < members[j].score
+		}
+		return members[i].member < members[j].member
+	})
+	return members
+}
+
+// db 一个逻辑数据库, 过期的key在访问时删除
+type db struct {
+	values map[string]*value
+	// cursors SCAN游标n对应 cursors[n-1], 为上一批返回的最后一个key,
+	// 与Redis一样遍历期间删除已返回的key不会导致其他key被跳过
+	cursors []string
+}
+
+func newDB() *db {
+	return &db{values: make(map[string]*value)}
+}
+
+func (d *db) get(key string, now time.Time) *value {
+	v, has := d.values[key]
+	if !has {
+		return nil
+	}
+	if v.expired(now) {
+		delete(d.values, key)
+		return nil
+	}
+	return v
+}
+
+// lookup 返回指定类型的值, key不存在时返回nil, 类型不一致时返回 errWrongType
+func (d *db) lookup(key string, k kind, now time.Time) (*value, error) {
+	v := d.get(key, now)
+	if v == nil {
+		return nil, nil
+	}
+	if v.kind != k {
+		return nil, errWrongType
+	}
+	return v, nil
+}
+
+// lookupOrCreate 与 lookup 相同, key不存在时创建新的空值
+func (d *db) lookupOrCreate(key string, k kind, now time.Time) (*value, error) {
+	v, err := d.lookup(key, k, now)
+	if err != nil || v != nil {
+		return v, err
+	}
+	v = &value{kind: k}
+	switch k {
+	case kindHash:
+		v.hash = make(map[string]string)
+	case kindZSet:
+		v.zset = make(map[string]float64)
+	case kindStream:
+		v.stream = &streamValue{groups: make(map[string]*streamGroup)}
+	}
+	d.values[key] = v
+	return v, nil
+}
+
+func (d *db) removeIfEmpty(key string, v *value) {
+	if v.empty() {
+		delete(d.values, key)
+	}
+}
+
+func (d *db) keys(now time.Time) []string {
+	keys := make([]string, 0, len(d.values))
+	for key, v := range d.values {
+		if v.expired(now) {
+			delete(d.values, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
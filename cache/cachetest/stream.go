@@ -0,0 +1,511 @@
+package cachetest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// streamID stream消息的ID, 格式为 毫秒-序号
+type streamID struct {
+	ms, seq uint64
+}
+
+func (id streamID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
+
+func (id streamID) less(o streamID) bool {
+	return id.ms < o.ms || (id.ms == o.ms && id.seq < o.seq)
+}
+
+// exclusive 开区间转换为闭区间, 起始ID取下一个, 结束ID取上一个
+func (id streamID) exclusive(end bool) (streamID, error) {
+	switch {
+	case end && id.seq > 0:
+		return streamID{id.ms, id.seq - 1}, nil
+	case end && id.ms > 0:
+		return streamID{id.ms - 1, ^uint64(0)}, nil
+	case !end && id.seq < ^uint64(0):
+		return streamID{id.ms, id.seq + 1}, nil
+	case !end && id.ms < ^uint64(0):
+		return streamID{id.ms + 1, 0}, nil
+	}
+	return id, errorReply("ERR invalid start ID for the interval")
+}
+
+// parseStreamID 解析完整或只有毫秒部分的ID, 只有毫秒部分时序号取 seq
+func parseStreamID(s string, seq uint64) (streamID, error) {
+	ms, rest, hasSeq := strings.Cut(s, "-")
+	id := streamID{seq: seq}
+	var err error
+	if id.ms, err = strconv.ParseUint(ms, 10, 64); err != nil {
+		return id, errorReply("ERR Invalid stream ID specified as stream command argument")
+	}
+	if hasSeq {
+		if id.seq, err = strconv.ParseUint(rest, 10, 64); err != nil {
+			return id, errorReply("ERR Invalid stream ID specified as stream command argument")
+		}
+	}
+	return id, nil
+}
+
+// parseRangeID 解析 XRANGE/XPENDING 的区间, 支持 - 和 + 以及 ( 开头的开区间
+func parseRangeID(s string, end bool) (streamID, error) {
+	switch s {
+	case "-":
+		return streamID{}, nil
+	case "+":
+		return streamID{ms: ^uint64(0), seq: ^uint64(0)}, nil
+	}
+	if rest, ok := strings.CutPrefix(s, "("); ok {
+		id, err := parseStreamID(rest, 0)
+		if err != nil {
+			return id, err
+		}
+		return id.exclusive(end)
+	}
+	if end {
+		return parseStreamID(s, ^uint64(0))
+	}
+	return parseStreamID(s, 0)
+}
+
+type streamEntry struct {
+	id     streamID
+	fields []string
+}
+
+type pendingEntry struct {
+	consumer  string
+	delivered time.Time
+	count     int64
+}
+
+type streamGroup struct {
+	last    streamID
+	pending map[streamID]*pendingEntry
+}
+
+type streamValue struct {
+	entries []streamEntry
+	last    streamID
+	groups  map[string]*streamGroup
+}
+
+func (s *streamValue) find(id streamID) (streamEntry, bool) {
+	i := sort.Search(len(s.entries), func(i int) bool {
+		return !s.entries[i].id.less(id)
+	})
+	if i < len(s.entries) && s.entries[i].id == id {
+		return s.entries[i], true
+	}
+	return streamEntry{}, false
+}
+
+func entryReply(e streamEntry) []any {
+	return []any{e.id.String(), e.fields}
+}
+
+// blockReply 阻塞读取时没有可读的消息, dispatch 在释放锁后等待并重试, 直到超时返回空值
+type blockReply time.Duration
+
+func lookupStream(x *execution, key string) (*streamValue, error) {
+	v, err := x.db.lookup(key, kindStream, x.now)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return v.stream, nil
+}
+
+func createStream(x *execution, key string) (*streamValue, error) {
+	v, err := x.db.lookupOrCreate(key, kindStream, x.now)
+	if err != nil {
+		return nil, err
+	}
+	return v.stream, nil
+}
+
+func lookupGroup(x *execution, cmd, key, group string) (*streamValue, *streamGroup, error) {
+	s, err := lookupStream(x, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s != nil {
+		if g, has := s.groups[group]; has {
+			return s, g, nil
+		}
+	}
+	return nil, nil, errorReply(fmt.Sprintf("NOGROUP No such key '%s' or consumer group '%s' in %s with GROUP option", key, group, cmd))
+}
+
+// cmdXAdd 支持 XADD key [NOMKSTREAM] [MAXLEN [~|=] n] *|id field value ...
+func cmdXAdd(x *execution, args []string) any {
+	i, noMk, maxLen := 2, false, int64(-1)
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NOMKSTREAM":
+			noMk = true
+			continue
+		case "MAXLEN":
+			i++
+			if i < len(args) && (args[i] == "~" || args[i] == "=") {
+				i++
+			}
+			if i >= len(args) {
+				return errSyntax
+			}
+			n, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil || n < 0 {
+				return errNotInteger
+			}
+			maxLen = n
+			continue
+		}
+		break
+	}
+	if i >= len(args) || (len(args)-i-1)%2 != 0 || len(args)-i-1 == 0 {
+		return errArgs("xadd")
+	}
+	s, err := lookupStream(x, args[1])
+	if err != nil {
+		return err
+	}
+	if s == nil && noMk {
+		return nil
+	}
+	if s == nil {
+		if s, err = createStream(x, args[1]); err != nil {
+			return err
+		}
+	}
+	id := streamID{ms: uint64(x.now.UnixMilli())}
+	if args[i] == "*" {
+		if !s.last.less(id) {
+			id = streamID{ms: s.last.ms, seq: s.last.seq + 1}
+		}
+	} else {
+		if id, err = parseStreamID(args[i], 0); err != nil {
+			return err
+		}
+		if !s.last.less(id) {
+			return errorReply("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+		}
+	}
+	s.last = id
+	s.entries = append(s.entries, streamEntry{id: id, fields: append([]string(nil), args[i+1:]...)})
+	if maxLen >= 0 && int64(len(s.entries)) > maxLen {
+		s.entries = s.entries[int64(len(s.entries))-maxLen:]
+	}
+	return id.String()
+}
+
+func cmdXLen(x *execution, args []string) any {
+	s, err := lookupStream(x, args[1])
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return int64(0)
+	}
+	return int64(len(s.entries))
+}
+
+// cmdXRange 支持 XRANGE key start end [COUNT n]
+func cmdXRange(x *execution, args []string) any {
+	start, err := parseRangeID(args[2], false)
+	if err != nil {
+		return err
+	}
+	end, err := parseRangeID(args[3], true)
+	if err != nil {
+		return err
+	}
+	count := -1
+	if len(args) == 6 && strings.ToUpper(args[4]) == "COUNT" {
+		if count, err = strconv.Atoi(args[5]); err != nil {
+			return errNotInteger
+		}
+	} else if len(args) != 4 {
+		return errSyntax
+	}
+	s, err := lookupStream(x, args[1])
+	if err != nil {
+		return err
+	}
+	rs := make([]any, 0)
+	if s == nil {
+		return rs
+	}
+	for _, e := range s.entries {
+		if e.id.less(start) || end.less(e.id) {
+			continue
+		}
+		if count >= 0 && len(rs) >= count {
+			break
+		}
+		rs = append(rs, entryReply(e))
+	}
+	return rs
+}
+
+// cmdXGroup 支持 CREATE key group id|$ [MKSTREAM] 和 DESTROY key group
+func cmdXGroup(x *execution, args []string) any {
+	switch strings.ToUpper(args[1]) {
+	case "CREATE":
+		if len(args) < 5 || len(args) > 6 {
+			return errArgs("xgroup|create")
+		}
+		mk := len(args) == 6 && strings.ToUpper(args[5]) == "MKSTREAM"
+		s, err := lookupStream(x, args[2])
+		if err != nil {
+			return err
+		}
+		if s == nil && !mk {
+			return errorReply("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+		}
+		if s == nil {
+			if s, err = createStream(x, args[2]); err != nil {
+				return err
+			}
+		}
+		if _, has := s.groups[args[3]]; has {
+			return errorReply("BUSYGROUP Consumer Group name already exists")
+		}
+		last := s.last
+		if args[4] != "$" {
+			if last, err = parseStreamID(args[4], 0); err != nil {
+				return err
+			}
+		}
+		s.groups[args[3]] = &streamGroup{last: last, pending: make(map[streamID]*pendingEntry)}
+		return replyOK
+	case "DESTROY":
+		if len(args) != 4 {
+			return errArgs("xgroup|destroy")
+		}
+		s, err := lookupStream(x, args[2])
+		if err != nil {
+			return err
+		}
+		if s == nil {
+			return errorReply("ERR The XGROUP subcommand requires the key to exist.")
+		}
+		if _, has := s.groups[args[3]]; !has {
+			return int64(0)
+		}
+		delete(s.groups, args[3])
+		return int64(1)
+	}
+	return errorReply("ERR unknown subcommand '" + args[1] + "'")
+}
+
+// cmdXReadGroup 支持 XREADGROUP GROUP group consumer [COUNT n] [BLOCK ms] [NOACK] STREAMS key... id...
+// id 为 > 时读取新消息, 否则读取该消费者待确认的消息
+func cmdXReadGroup(x *execution, args []string) any {
+	if strings.ToUpper(args[1]) != "GROUP" {
+		return errSyntax
+	}
+	group, consumer := args[2], args[3]
+	count, block, noAck := -1, time.Duration(-1), false
+	i := 4
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COUNT", "BLOCK":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 {
+				return errNotInteger
+			}
+			if strings.ToUpper(args[i]) == "COUNT" {
+				count = n
+			} else {
+				block = time.Duration(n) * time.Millisecond
+			}
+			i++
+			continue
+		case "NOACK":
+			noAck = true
+			continue
+		case "STREAMS":
+		default:
+			return errSyntax
+		}
+		break
+	}
+	rest := args[i+1:]
+	if i >= len(args) || len(rest) == 0 || len(rest)%2 != 0 {
+		return errorReply("ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.")
+	}
+	keys, ids := rest[:len(rest)/2], rest[len(rest)/2:]
+	rs := make([]any, 0, len(keys))
+	fresh := false
+	for n, key := range keys {
+		s, g, err := lookupGroup(x, "XREADGROUP", key, group)
+		if err != nil {
+			return err
+		}
+		messages := make([]any, 0)
+		if ids[n] == ">" {
+			fresh = true
+			for _, e := range s.entries {
+				if count >= 0 && len(messages) >= count {
+					break
+				}
+				if !g.last.less(e.id) {
+					continue
+				}
+				g.last = e.id
+				if !noAck {
+					g.pending[e.id] = &pendingEntry{consumer: consumer, delivered: x.now, count: 1}
+				}
+				messages = append(messages, entryReply(e))
+			}
+		} else {
+			start, err := parseStreamID(ids[n], 0)
+			if err != nil {
+				return err
+			}
+			for _, id := range sortedPending(g) {
+				p := g.pending[id]
+				if p.consumer != consumer || id.less(start) || (count >= 0 && len(messages) >= count) {
+					continue
+				}
+				e, ok := s.find(id)
+				if !ok {
+					messages = append(messages, []any{id.String(), nil})
+					continue
+				}
+				p.delivered, p.count = x.now, p.count+1
+				messages = append(messages, entryReply(e))
+			}
+		}
+		if len(messages) > 0 || !fresh {
+			rs = append(rs, []any{key, messages})
+		}
+	}
+	if len(rs) == 0 {
+		if block >= 0 {
+			return blockReply(block)
+		}
+		return nil
+	}
+	return rs
+}
+
+func sortedPending(g *streamGroup) []streamID {
+	ids := make([]streamID, 0, len(g.pending))
+	for id := range g.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].less(ids[j])
+	})
+	return ids
+}
+
+func cmdXAck(x *execution, args []string) any {
+	s, err := lookupStream(x, args[1])
+	if err != nil {
+		return err
+	}
+	if s == nil || s.groups[args[2]] == nil {
+		return int64(0)
+	}
+	g := s.groups[args[2]]
+	var n int64
+	for _, arg := range args[3:] {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return err
+		}
+		if _, has := g.pending[id]; has {
+			delete(g.pending, id)
+			n++
+		}
+	}
+	return n
+}
+
+// cmdXPending 只支持扩展格式 XPENDING key group [IDLE ms] start end count [consumer]
+func cmdXPending(x *execution, args []string) any {
+	rest := args[3:]
+	idle := time.Duration(0)
+	if len(rest) > 0 && strings.ToUpper(rest[0]) == "IDLE" {
+		if len(rest) < 2 {
+			return errSyntax
+		}
+		ms, err := strconv.ParseInt(rest[1], 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		idle, rest = time.Duration(ms)*time.Millisecond, rest[2:]
+	}
+	if len(rest) < 3 || len(rest) > 4 {
+		return errorReply("ERR cachetest: only the extended form of XPENDING is supported")
+	}
+	start, err := parseRangeID(rest[0], false)
+	if err != nil {
+		return err
+	}
+	end, err := parseRangeID(rest[1], true)
+	if err != nil {
+		return err
+	}
+	count, err := strconv.Atoi(rest[2])
+	if err != nil {
+		return errNotInteger
+	}
+	_, g, err := lookupGroup(x, "XPENDING", args[1], args[2])
+	if err != nil {
+		return err
+	}
+	rs := make([]any, 0)
+	for _, id := range sortedPending(g) {
+		p := g.pending[id]
+		if len(rs) >= count || id.less(start) || end.less(id) || x.now.Sub(p.delivered) < idle {
+			continue
+		}
+		if len(rest) == 4 && p.consumer != rest[3] {
+			continue
+		}
+		rs = append(rs, []any{id.String(), p.consumer, x.now.Sub(p.delivered).Milliseconds(), p.count})
+	}
+	return rs
+}
+
+// cmdXClaim 支持 XCLAIM key group consumer min-idle-time id..., 不支持其他选项
+func cmdXClaim(x *execution, args []string) any {
+	minIdle, err := strconv.ParseInt(args[4], 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	s, g, err := lookupGroup(x, "XCLAIM", args[1], args[2])
+	if err != nil {
+		return err
+	}
+	rs := make([]any, 0)
+	for _, arg := range args[5:] {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return err
+		}
+		p, has := g.pending[id]
+		if !has || x.now.Sub(p.delivered) < time.Duration(minIdle)*time.Millisecond {
+			continue
+		}
+		e, ok := s.find(id)
+		if !ok {
+			// 消息已经被裁剪, 与Redis一致从待确认列表中删除
+			delete(g.pending, id)
+			continue
+		}
+		p.consumer, p.delivered, p.count = args[3], x.now, p.count+1
+		rs = append(rs, entryReply(e))
+	}
+	return rs
+}
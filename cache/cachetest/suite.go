@@ -0,0 +1,564 @@
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+// Factory 创建以 namespace 为key前缀的缓存, 不同 namespace 的数据必须互相隔离
+type Factory func(t *testing.T, namespace string) cache.ICommonCache
+
+var namespaceSeq atomic.Int64
+
+// TestCommonCache 对 ICommonCache 的实现运行一致性测试
+// 每个子测试使用独立的namespace, 结束后通过 DeleteByPattern 清理, 因此也可以对真实的Redis运行
+func TestCommonCache(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, ctx context.Context, c cache.ICommonCache, factory func() cache.ICommonCache)
+	}{
+		{"Miss", testMiss},
+		{"SetGet", testSetGet},
+		{"TTL", testTTL},
+		{"Expiration", testExpiration},
+		{"Namespace", testNamespace},
+		{"Hash", testHash},
+		{"SetNX", testSetNX},
+		{"Compare", testCompare},
+		{"Incr", testIncr},
+		{"List", testList},
+		{"SortedSet", testSortedSet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			newCache := func() cache.ICommonCache {
+				c := factory(t, fmt.Sprintf("cachetest:%d:%d", time.Now().UnixNano(), namespaceSeq.Add(1)))
+				t.Cleanup(func() {
+					_, _ = c.DeleteByPattern(context.Background(), "*")
+				})
+				return c
+			}
+			tt.fn(t, ctx, newCache(), newCache)
+		})
+	}
+}
+
+func expectNotFound(t *testing.T, op string, err error) {
+	t.Helper()
+	if !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("%s: want ErrNotFound, got %v", op, err)
+	}
+}
+
+func expectNoError(t *testing.T, op string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: unexpected error %v", op, err)
+	}
+}
+
+func testMiss(t *testing.T, ctx context.Context, c cache.ICommonCache, _ func() cache.ICommonCache) {
+	_, err := c.Get(ctx, "missing")
+	expectNotFound(t, "Get", err)
+	_, err = c.GetInt(ctx, "missing")
+	expectNotFound(t, "GetInt", err)
+	_, err = c.HGet(ctx, "missing", "f")
+	expectNotFound(t, "HGet", err)
+	_, err = c.ZScore(ctx, "missing", []byte("m"))
+	expectNotFound(t, "ZScore", err)
+	_, err = c.ZRank(ctx, "missing", []byte("m"), false)
+	expectNotFound(t, "ZRank", err)
+	_, err = c.TTL(ctx, "missing")
+	expectNotFound(t, "TTL", err)
+
+	values, err := c.MGet(ctx, "missing", "missing2")
+	expectNoError(t, "MGet", err)
+	if len(values) != 2 || values[0] != nil || values[1] != nil {
+		t.Errorf("MGet missing keys: want [nil nil], got %q", values)
+	}
+	hash, err := c.HGetAll(ctx, "missing")
+	expectNoError(t, "HGetAll", err)
+	if len(hash) != 0 {
+		t.Errorf("HGetAll missing key: want empty, got %v", hash)
+	}
+	list, err := c.LRange(ctx, "missing", 0, -1)
+	expectNoError(t, "LRange", err)
+	if len(list) != 0 {
+		t.Errorf("LRange missing key: want empty, got %q", list)
+	}
+	n, err := c.LLen(ctx, "missing")
+	expectNoError(t, "LLen", err)
+	if n != 0 {
+		t.Errorf("LLen missing key: want 0, got %d", n)
+	}
+	ok, err := c.Expire(ctx, "missing", time.Minute)
+	expectNoError(t, "Expire", err)
+	if ok {
+		t.Errorf("Expire missing key: want false")
+	}
+	expectNoError(t, "Del", c.Del(ctx, "missing"))
+}
+
+func testSetGet(t *testing.T, ctx context.Context, c cache.ICommonCache, _ func() cache.ICommonCache) {
+	expectNoError(t, "Set", c.Set(ctx, "k", []byte("v1"), 0))
+	expectNoError(t, "Set", c.Set(ctx, "k", []byte("v2"), 0))
+	v, err := c.Get(ctx, "k")
+	expectNoError(t, "Get", err)
+	if string(v) != "v2" {
+		t.Errorf("Get after overwrite: want v2, got %q", v)
+	}
+	expectNoError(t, "Set empty", c.Set(ctx, "empty", []byte{}, 0))
+	v, err = c.Get(ctx, "empty")
+	expectNoError(t, "Get empty", err)
+	if len(v) != 0 {
+		t.Errorf("Get empty value: got %q", v)
+	}
+
+	expectNoError(t, "MSet", c.MSet(ctx, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, 0))
+	values, err := c.MGet(ctx, "a", "missing", "b")
+	expectNoError(t, "MGet", err)
+	if len(values) != 3 || string(values[0]) != "1" || values[1] != nil || string(values[2]) != "2" {
+		t.Errorf("MGet: want [1 nil 2], got %q", values)
+	}
+
+	expectNoError(t, "Del", c.Del(ctx, "k", "a"))
+	_, err = c.Get(ctx, "k")
+	expectNotFound(t, "Get after Del", err)
+	_, err = c.Get(ctx, "a")
+	expectNotFound(t, "Get after Del", err)
+	if _, err := c.Get(ctx, "b"); err != nil {
+		t.Errorf("Del removed an unrelated key: %v", err)
+	}
+}
+
+func testTTL(t *testing.T, ctx context.Context, c cache.ICommonCache, _ func() cache.ICommonCache) {
+	expectNoError(t, "Set", c.Set(ctx, "short", []byte("v"), time.Millisecond*200))
+	ttl, err := c.TTL(ctx, "short")
+	expectNoError(t, "TTL", err)
+	if ttl <= 0 || ttl > time.Millisecond*200 {
+		t.Errorf("TTL: want (0, 200ms], got %s", ttl)
+	}
+
+	expectNoError(t, "Set", c.Set(ctx, "forever", []byte("v"), 0))
+	ttl, err = c.TTL(ctx, "forever")
+	expectNoError(t, "TTL", err)
+	if ttl >= 0 {
+		t.Errorf("TTL without expiration: want negative, got %s", ttl)
+	}
+	ok, err := c.Expire(ctx, "forever", time.Minute)
+	expectNoError(t, "Expire", err)
+	ttl, _ = c.TTL(ctx, "forever")
+	if !ok || ttl <= time.Second*50 || ttl > time.Minute {
+		t.Errorf("Expire: want true and ttl about 1m, got %v %s", ok, ttl)
+	}
+	ok, err = c.Expire(ctx, "forever", 0)
+	expectNoError(t, "Expire(0)", err)
+	if _, err := c.Get(ctx, "forever"); !ok || !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Expire(0) should delete the key, got %v %v", ok, err)
+	}
+
+	expectNoError(t, "Set", c.Set(ctx, "batch1", []byte("v"), 0))
+	expectNoError(t, "Set", c.Set(ctx, "batch2", []byte("v"), 0))
+	expectNoError(t, "MExpire", c.MExpire(ctx, []string{"batch1", "batch2", "missing"}, time.Minute))
+	for _, key := range []string{"batch1", "batch2"} {
+		if ttl, _ := c.TTL(ctx, key); ttl <= time.Second*50 || ttl > time.Minute {
+			t.Errorf("MExpire %s: want ttl about 1m, got %s", key, ttl)
+		}
+	}
+	if n, _ := c.Exists(ctx, "missing"); n != 0 {
+		t.Errorf("MExpire should not create missing keys")
+	}
+	expectNoError(t, "MExpire(0)", c.MExpire(ctx, []string{"batch1", "batch2"}, 0))
+	if n, _ := c.Exists(ctx, "batch1", "batch2"); n != 0 {
+		t.Errorf("MExpire(0) should delete the keys, %d left", n)
+	}
+
+	expectNoError(t, "HSet", c.HSet(ctx, "hash", "f", []byte("v"), time.Millisecond*200))
+	_, err = c.RPush(ctx, "list", time.Millisecond*200, []byte("v"))
+	expectNoError(t, "RPush", err)
+	expectNoError(t, "ZAdd", c.ZAdd(ctx, "zset", []byte("m"), 1, time.Millisecond*200))
+
+	time.Sleep(time.Millisecond * 300)
+	_, err = c.Get(ctx, "short")
+	expectNotFound(t, "Get after expiration", err)
+	_, err = c.TTL(ctx, "short")
+	expectNotFound(t, "TTL after expiration", err)
+	_, err = c.HGet(ctx, "hash", "f")
+	expectNotFound(t, "HGet after expiration", err)
+	if n, _ := c.LLen(ctx, "list"); n != 0 {
+		t.Errorf("LLen after expiration: want 0, got %d", n)
+	}
+	_, err = c.ZScore(ctx, "zset", []byte("m"))
+	expectNotFound(t, "ZScore after expiration", err)
+}
+
+func testExpiration(t *testing.T, ctx context.Context, c cache.ICommonCache, _ func() cache.ICommonCache) {
+	expectNoError(t, "Set", c.Set(ctx, "a", []byte("v"), 0))
+	expectNoError(t, "HSet", c.HSet(ctx, "h", "f", []byte("v"), 0))
+	n, err := c.Exists(ctx, "a", "h", "missing", "a")
+	expectNoError(t, "Exists", err)
+	if n != 3 {
+		t.Errorf("Exists: want 3, got %d", n)
+	}
+	if n, err := c.Exists(ctx); err != nil || n != 0 {
+		t.Errorf("Exists without keys: want 0, got %d %v", n, err)
+	}
+
+	ok, err := c.ExpireAt(ctx, "a", time.Now().Add(time.Minute))
+	expectNoError(t, "ExpireAt", err)
+	ttl, _ := c.TTL(ctx, "a")
+	if !ok || ttl <= time.Second*50 || ttl > time.Minute {
+		t.Errorf("ExpireAt: want true and ttl about 1m, got %v %s", ok, ttl)
+	}
+	ok, err = c.ExpireAt(ctx, "missing", time.Now().Add(time.Minute))
+	expectNoError(t, "ExpireAt missing", err)
+	if ok {
+		t.Errorf("ExpireAt missing key: want false")
+	}
+
+	ok, err = c.Persist(ctx, "a")
+	expectNoError(t, "Persist", err)
+	ttl, _ = c.TTL(ctx, "a")
+	if !ok || ttl >= 0 {
+		t.Errorf("Persist: want true and negative ttl, got %v %s", ok, ttl)
+	}
+	ok, err = c.Persist(ctx, "a")
+	expectNoError(t, "Persist", err)
+	if ok {
+		t.Errorf("Persist without expiration: want false")
+	}
+	ok, err = c.Persist(ctx, "missing")
+	expectNoError(t, "Persist missing", err)
+	if ok {
+		t.Errorf("Persist missing key: want false")
+	}
+
+	ok, err = c.ExpireAt(ctx, "h", time.Now().Add(-time.Second))
+	expectNoError(t, "ExpireAt in the past", err)
+	if n, _ := c.Exists(ctx, "h"); !ok || n != 0 {
+		t.Errorf("ExpireAt in the past should delete the key, got %v %d", ok, n)
+	}
+}
+
+func testNamespace(t *testing.T, ctx context.Context, a cache.ICommonCache, newCache func() cache.ICommonCache) {
+	b := newCache()
+	expectNoError(t, "Set", a.Set(ctx, "k", []byte("a"), 0))
+	expectNoError(t, "Set", a.Set(ctx, "k2", []byte("a"), 0))
+	_, err := b.Get(ctx, "k")
+	expectNotFound(t, "Get from another namespace", err)
+	expectNoError(t, "Set", b.Set(ctx, "k", []byte("b"), 0))
+
+	clone := a.Clone()
+	v, err := clone.Get(ctx, "k")
+	expectNoError(t, "Get from clone", err)
+	if string(v) != "a" {
+		t.Errorf("Clone should share the namespace: want a, got %q", v)
+	}
+
+	var keys []string
+	expectNoError(t, "Scan", a.Scan(ctx, "*", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}))
+	sort.Strings(keys)
+	if fmt.Sprint(keys) != "[k k2]" {
+		t.Errorf("Scan should return keys of its own namespace without prefix: want [k k2], got %v", keys)
+	}
+
+	n, err := b.DeleteByPattern(ctx, "*")
+	expectNoError(t, "DeleteByPattern", err)
+	if n != 1 {
+		t.Errorf("DeleteByPattern: want 1 deleted, got %d", n)
+	}
+	if _, err := a.Get(ctx, "k"); err != nil {
+		t.Errorf("DeleteByPattern removed a key of another namespace: %v", err)
+	}
+}
+
+func testHash(t *testing.T, ctx context.Context, c cache.ICommonCache, _ func() cache.ICommonCache) {
+	expectNoError(t, "HSet", c.HSet(ctx, "h", "a", []byte("1"), 0))
+	expectNoError(t, "HMSet", c.HMSet(ctx, "h", map[string][]byte{"b": []byte("2"), "c": []byte("3")}, 0))
+	v, err := c.HGet(ctx, "h", "b")
+	expectNoError(t, "HGet", err)
+	if string(v) != "2" {
+		t.Errorf("HGet: want 2, got %q", v)
+	}
+	_, err = c.HGet(ctx, "h", "missing")
+	expectNotFound(t, "HGet missing field", err)
+
+	all, err := c.HGetAll(ctx, "h")
+	expectNoError(t, "HGetAll", err)
+	if len(all) != 3 || all["a"] != "1" || all["b"] != "2" || all["c"] != "3" {
+		t.Errorf("HGetAll: want map[a:1 b:2 c:3], got %v", all)
+	}
+	bytesAll, err := c.HGetAllBytes(ctx, "h")
+	expectNoError(t, "HGetAllBytes", err)
+	if len(bytesAll) != 3 || string(bytesAll["c"]) != "3" {
+		t.Errorf("HGetAllBytes: got %q", bytesAll)
+	}
+
+	expectNoError(t, "HDel", c.HDel(ctx, "h", "a", "missing"))
+	all, _ = c.HGetAll(ctx, "h")
+	if _, has := all["a"]; has || len(all) != 2 {
+		t.Errorf("HDel: got %v", all)
+	}
+	ttl, err := c.TTL(ctx, "h")
+	expectNoError(t, "TTL", err)
+	if ttl >= 0 {
+		t.Errorf("hash written without expiration should not expire, got ttl %s", ttl)
+	}
+
+	expectNoError(t, "HMSet", c.HMSet(ctx, "h2", map[string][]byte{"a": []byte("1")}, time.Minute))
+	if ttl, _ := c.TTL(ctx, "h2"); ttl <= 0 {
+		t.Errorf("HMSet with expiration: want positive ttl, got %s", ttl)
+	}
+}
+
+func testSetNX(t *testing.T, ctx context.Context, c cache.ICommonCache, _ func() cache.ICommonCache) {
+	const workers = 32
+	var (
+		wg     sync.WaitGroup
+		winner atomic.Int64
+		wins   atomic.Int64
+		errs   atomic.Int64
+		start  = make(chan struct{})
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			ok, err := c.SetNX(ctx, "nx", []byte(fmt.Sprint(i)), time.Minute)
+			if err != nil {
+				errs.Add(1)
+				return
+			}
+			if ok {
+				wins.Add(1)
+				winner.Store(int64(i))
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	if errs.Load() > 0 || wins.Load() != 1 {
+		t.Fatalf("SetNX race: want exactly 1 winner and no error, got %d winners %d errors", wins.Load(), errs.Load())
+	}
+	v, err := c.Get(ctx, "nx")
+	expectNoError(t, "Get", err)
+	if string(v) != fmt.Sprint(winner.Load()) {
+		t.Errorf("SetNX: value should be written by the winner %d, got %q", winner.Load(), v)
+	}
+	if ttl, _ := c.TTL(ctx, "nx"); ttl <= 0 {
+		t.Errorf("SetNX with expiration: want positive ttl, got %s", ttl)
+	}
+}
+
+func testCompare(t *testing.T, ctx context.Context, c cache.ICommonCache, _ func() cache.ICommonCache) {
+	expectNoError(t, "Set", c.Set(ctx, "lock", []byte("token"), time.Minute))
+	ok, err := c.CompareAndExpire(ctx, "lock", []byte("other"), time.Hour)
+	expectNoError(t, "CompareAndExpire", err)
+	if ok {
+		t.Errorf("CompareAndExpire with wrong value: want false")
+	}
+	ok, err = c.CompareAndExpire(ctx, "lock", []byte("token"), time.Hour)
+	expectNoError(t, "CompareAndExpire", err)
+	if ttl, _ := c.TTL(ctx, "lock"); !ok || ttl <= time.Minute {
+		t.Errorf("CompareAndExpire: want true and ttl about 1h, got %v %s", ok, ttl)
+	}
+
+	ok, err = c.CompareAndDelete(ctx, "lock", []byte("other"))
+	expectNoError(t, "CompareAndDelete", err)
+	if ok {
+		t.Errorf("CompareAndDelete with wrong value: want false")
+	}
+	ok, err = c.CompareAndDelete(ctx, "lock", []byte("token"))
+	expectNoError(t, "CompareAndDelete", err)
+	if !ok {
+		t.Errorf("CompareAndDelete: want true")
+	}
+	_, err = c.Get(ctx, "lock")
+	expectNotFound(t, "Get after CompareAndDelete", err)
+	ok, err = c.CompareAndDelete(ctx, "lock", []byte("token"))
+	if err != nil || ok {
+		t.Errorf("CompareAndDelete missing key: want false, got %v %v", ok, err)
+	}
+}
+
+func testIncr(t *testing.T, ctx context.Context, c cache.ICommonCache, _ func() cache.ICommonCache) {
+	expectNoError(t, "Incr", c.Incr(ctx, "n", 0))
+	expectNoError(t, "IncrBy", c.IncrBy(ctx, "n", 5, 0))
+	n, err := c.GetInt(ctx, "n")
+	expectNoError(t, "GetInt", err)
+	if n != 6 {
+		t.Errorf("Incr then IncrBy 5: want 6, got %d", n)
+	}
+	n, err = c.IncrByEx(ctx, "n", -10, 0, false)
+	expectNoError(t, "IncrByEx", err)
+	if n != -4 {
+		t.Errorf("IncrByEx -10: want -4, got %d", n)
+	}
+
+	_, err = c.IncrByEx(ctx, "fixed", 1, time.Minute, false)
+	expectNoError(t, "IncrByEx", err)
+	expectNoError(t, "Expire", expire(ctx, c, "fixed", time.Second*10))
+	_, err = c.IncrByEx(ctx, "fixed", 1, time.Minute, false)
+	expectNoError(t, "IncrByEx", err)
+	if ttl, _ := c.TTL(ctx, "fixed"); ttl <= 0 || ttl > time.Second*10 {
+		t.Errorf("IncrByEx without sliding should keep the existing ttl, got %s", ttl)
+	}
+	_, err = c.IncrByEx(ctx, "fixed", 1, time.Minute, true)
+	expectNoError(t, "IncrByEx", err)
+	if ttl, _ := c.TTL(ctx, "fixed"); ttl <= time.Second*10 {
+		t.Errorf("IncrByEx with sliding should reset the ttl, got %s", ttl)
+	}
+	if n, _ := c.GetInt(ctx, "fixed"); n != 3 {
+		t.Errorf("IncrByEx three times: want 3, got %d", n)
+	}
+
+	expectNoError(t, "Set", c.Set(ctx, "text", []byte("abc"), 0))
+	if _, err := c.GetInt(ctx, "text"); err == nil {
+		t.Errorf("GetInt on a non integer value should fail")
+	}
+	if err := c.Incr(ctx, "text", 0); err == nil {
+		t.Errorf("Incr on a non integer value should fail")
+	}
+}
+
+func expire(ctx context.Context, c cache.ICommonCache, key string, expiration time.Duration) error {
+	ok, err := c.Expire(ctx, key, expiration)
+	if err == nil && !ok {
+		return cache.ErrNotFound
+	}
+	return err
+}
+
+func testList(t *testing.T, ctx context.Context, c cache.ICommonCache, _ func() cache.ICommonCache) {
+	n, err := c.RPush(ctx, "l", 0, []byte("c"), []byte("d"))
+	expectNoError(t, "RPush", err)
+	if n != 2 {
+		t.Errorf("RPush: want length 2, got %d", n)
+	}
+	n, err = c.LPush(ctx, "l", 0, []byte("b"), []byte("a"))
+	expectNoError(t, "LPush", err)
+	if n != 4 {
+		t.Errorf("LPush: want length 4, got %d", n)
+	}
+	list, err := c.LRange(ctx, "l", 0, -1)
+	expectNoError(t, "LRange", err)
+	if fmt.Sprintf("%s", list) != "[a b c d]" {
+		t.Errorf("LRange: want [a b c d], got %s", list)
+	}
+	list, _ = c.LRange(ctx, "l", -2, -1)
+	if fmt.Sprintf("%s", list) != "[c d]" {
+		t.Errorf("LRange -2 -1: want [c d], got %s", list)
+	}
+	expectNoError(t, "LTrim", c.LTrim(ctx, "l", 1, 2))
+	list, _ = c.LRange(ctx, "l", 0, -1)
+	if fmt.Sprintf("%s", list) != "[b c]" {
+		t.Errorf("LTrim 1 2: want [b c], got %s", list)
+	}
+	if n, _ := c.LLen(ctx, "l"); n != 2 {
+		t.Errorf("LLen: want 2, got %d", n)
+	}
+
+	n, err = c.RPushTrim(ctx, "l", 3, 0, []byte("d"), []byte("e"))
+	expectNoError(t, "RPushTrim", err)
+	list, _ = c.LRange(ctx, "l", 0, -1)
+	if n != 3 || fmt.Sprintf("%s", list) != "[c d e]" {
+		t.Errorf("RPushTrim: want 3 [c d e], got %d %s", n, list)
+	}
+	n, err = c.LPushTrim(ctx, "l", 2, 0, []byte("b"))
+	expectNoError(t, "LPushTrim", err)
+	list, _ = c.LRange(ctx, "l", 0, -1)
+	if n != 2 || fmt.Sprintf("%s", list) != "[b c]" {
+		t.Errorf("LPushTrim: want 2 [b c], got %d %s", n, list)
+	}
+
+	expectNoError(t, "LReplace", c.LReplace(ctx, "l", time.Minute, []byte("x"), []byte("y")))
+	list, _ = c.LRange(ctx, "l", 0, -1)
+	if fmt.Sprintf("%s", list) != "[x y]" {
+		t.Errorf("LReplace: want [x y], got %s", list)
+	}
+	if ttl, err := c.TTL(ctx, "l"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("LReplace should set expiration, got %s %v", ttl, err)
+	}
+	// LReplace 与DEL一样不关心原来的类型
+	expectNoError(t, "Set", c.Set(ctx, "s", []byte("v"), 0))
+	expectNoError(t, "LReplace over string", c.LReplace(ctx, "s", 0, []byte("a")))
+	if list, _ := c.LRange(ctx, "s", 0, -1); fmt.Sprintf("%s", list) != "[a]" {
+		t.Errorf("LReplace over string: want [a], got %s", list)
+	}
+	expectNoError(t, "LReplace empty", c.LReplace(ctx, "l", 0))
+	if n, _ := c.Exists(ctx, "l"); n != 0 {
+		t.Errorf("LReplace without values should delete the list, got %d", n)
+	}
+}
+
+func testSortedSet(t *testing.T, ctx context.Context, c cache.ICommonCache, _ func() cache.ICommonCache) {
+	expectNoError(t, "ZAdd", c.ZAdd(ctx, "z", []byte("a"), 1, 0))
+	expectNoError(t, "ZAdd", c.ZAdd(ctx, "z", []byte("b"), 2, 0))
+	expectNoError(t, "ZAdd", c.ZAdd(ctx, "z", []byte("c"), 3, 0))
+	score, err := c.ZIncrBy(ctx, "z", []byte("a"), 2.5, 0)
+	expectNoError(t, "ZIncrBy", err)
+	if score != 3.5 {
+		t.Errorf("ZIncrBy: want 3.5, got %v", score)
+	}
+	score, err = c.ZScore(ctx, "z", []byte("b"))
+	expectNoError(t, "ZScore", err)
+	if score != 2 {
+		t.Errorf("ZScore: want 2, got %v", score)
+	}
+	_, err = c.ZScore(ctx, "z", []byte("missing"))
+	expectNotFound(t, "ZScore missing member", err)
+
+	rank, err := c.ZRank(ctx, "z", []byte("a"), false)
+	expectNoError(t, "ZRank", err)
+	if rank != 2 {
+		t.Errorf("ZRank: want 2, got %d", rank)
+	}
+	rank, _ = c.ZRank(ctx, "z", []byte("a"), true)
+	if rank != 0 {
+		t.Errorf("ZRank reverse: want 0, got %d", rank)
+	}
+
+	members, err := c.ZRange(ctx, "z", 0, -1, false)
+	expectNoError(t, "ZRange", err)
+	if zmembers(members) != "[b:2 c:3 a:3.5]" {
+		t.Errorf("ZRange: want [b:2 c:3 a:3.5], got %s", zmembers(members))
+	}
+	members, _ = c.ZRange(ctx, "z", 0, 0, true)
+	if zmembers(members) != "[a:3.5]" {
+		t.Errorf("ZRange reverse: want [a:3.5], got %s", zmembers(members))
+	}
+	members, err = c.ZRangeByScore(ctx, "z", 2.5, 10, 0, 0)
+	expectNoError(t, "ZRangeByScore", err)
+	if zmembers(members) != "[c:3 a:3.5]" {
+		t.Errorf("ZRangeByScore: want [c:3 a:3.5], got %s", zmembers(members))
+	}
+	members, _ = c.ZRangeByScore(ctx, "z", 0, 10, 1, 1)
+	if zmembers(members) != "[c:3]" {
+		t.Errorf("ZRangeByScore offset 1 count 1: want [c:3], got %s", zmembers(members))
+	}
+
+	expectNoError(t, "ZRem", c.ZRem(ctx, "z", []byte("b"), []byte("missing")))
+	_, err = c.ZRank(ctx, "z", []byte("b"), false)
+	expectNotFound(t, "ZRank after ZRem", err)
+}
+
+func zmembers(members []cache.ZMember) string {
+	rs := make([]string, 0, len(members))
+	for _, m := range members {
+		rs = append(rs, fmt.Sprintf("%s:%v", m.Member, m.Score))
+	}
+	return fmt.Sprint(rs)
+}
@@ -0,0 +1,319 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("cache circuit breaker is open")
+
+// CircuitOpenError 熔断期间快速失败返回的错误, errors.Is(err, ErrCircuitOpen) 为true
+type CircuitOpenError struct {
+	Op string
+	// RetryAfter 距离进入半开状态的剩余时间, 半开状态下为0
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("cache %s rejected: circuit breaker is open, retry after %s", e.Op, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+type BreakerState int32
+
+const (
+	// BreakerClosed 正常放行所有操作
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 所有操作快速失败, 持续 OpenTimeout 后进入半开
+	BreakerOpen
+	// BreakerHalfOpen 只放行少量试探操作, 全部成功后关闭, 任意失败重新打开
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int32(s))
+}
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = time.Second * 10
+)
+
+// BreakerOption 熔断器配置, 未设置的项使用默认值
+type BreakerOption struct {
+	// FailureThreshold 连续失败多少次后打开, 默认5
+	FailureThreshold int
+	// OpenTimeout 打开状态持续的时间, 默认10秒
+	OpenTimeout time.Duration
+	// HalfOpenRequests 半开状态下放行的试探操作数, 默认1
+	HalfOpenRequests int
+	// QueueSize 大于0时, 熔断期间的 Set/MSet/Del 不返回错误, 而是进入队列(最多 QueueSize 个), 关闭后按顺序重放
+	// 队列已满时返回 CircuitOpenError, 队列重放完成之前其他操作(包括读取)继续返回 CircuitOpenError
+	QueueSize int
+	// IsFailure 判断错误是否计入失败, 默认 ErrNotFound、ErrNotSupported 和 context.Canceled 之外的错误都计入
+	IsFailure func(err error) bool
+	// OnStateChange 状态变化时调用, 不能阻塞
+	OnStateChange func(from, to BreakerState)
+}
+
+type queuedWrite struct {
+	ctx  context.Context
+	op   *Operation
+	next Handler
+}
+
+// CircuitBreaker 缓存熔断器, 通过 Middleware 与 Wrap 组合使用:
+//
+//	breaker := cache.NewCircuitBreaker(cache.BreakerOption{})
+//	client = cache.Wrap(client, breaker.Middleware(), cache.Timeout(time.Millisecond*200))
+type CircuitBreaker struct {
+	opt BreakerOption
+
+	lock     sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// generation 每次状态变化加1, 用于忽略状态变化之前放行的操作的结果
+	generation uint64
+	probes     int
+	successes  int
+	queue      []queuedWrite
+	replaying  bool
+}
+
+func NewCircuitBreaker(opt BreakerOption) *CircuitBreaker {
+	if opt.FailureThreshold <= 0 {
+		opt.FailureThreshold = defaultFailureThreshold
+	}
+	if opt.OpenTimeout <= 0 {
+		opt.OpenTimeout = defaultOpenTimeout
+	}
+	if opt.HalfOpenRequests <= 0 {
+		opt.HalfOpenRequests = 1
+	}
+	if opt.IsFailure == nil {
+		opt.IsFailure = isBreakerFailure
+	}
+	return &CircuitBreaker{opt: opt}
+}
+
+func isBreakerFailure(err error) bool {
+	return err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrNotSupported) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, ErrCircuitOpen)
+}
+
+// State 返回当前状态, 打开状态超过 OpenTimeout 但还没有新的操作时仍返回 BreakerOpen
+func (b *CircuitBreaker) State() BreakerState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+// Queued 返回等待重放的写操作数
+func (b *CircuitBreaker) Queued() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.queue)
+}
+
+func (b *CircuitBreaker) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			if b.opt.QueueSize > 0 && queueable(op) {
+				if queued, err := b.enqueue(ctx, op, next); queued {
+					return err
+				}
+			}
+			generation, probe, err := b.allow(op)
+			if err != nil {
+				return err
+			}
+			err = next(ctx, op)
+			b.record(generation, probe, err)
+			return err
+		}
+	}
+}
+
+func queueable(op *Operation) bool {
+	switch op.Name {
+	case "set", "mset", "del":
+		return true
+	}
+	return false
+}
+
+// enqueue 熔断期间或队列尚未重放完成时写操作进入队列, 保证重放的写操作不会覆盖之后的写入
+// 打开状态超时后由队列中最早的写操作作为半开状态的试探
+func (b *CircuitBreaker) enqueue(ctx context.Context, op *Operation, next Handler) (bool, error) {
+	var changes []BreakerState
+	defer func() { b.notify(changes) }()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state == BreakerClosed && len(b.queue) == 0 && !b.replaying {
+		return false, nil
+	}
+	if len(b.queue) >= b.opt.QueueSize {
+		return true, b.openError(op)
+	}
+	b.queue = append(b.queue, queuedWrite{ctx: context.WithoutCancel(ctx), op: op, next: next})
+	changes = b.resume()
+	return true, nil
+}
+
+// resume 打开状态超时后进入半开, 并开始重放队列, 调用时需持有锁
+func (b *CircuitBreaker) resume() []BreakerState {
+	var changes []BreakerState
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.opt.OpenTimeout {
+		changes = b.transition(BreakerHalfOpen)
+	}
+	if b.state == BreakerHalfOpen && !b.replaying && b.probes < b.opt.HalfOpenRequests {
+		b.replaying = true
+		go b.replay()
+	}
+	return changes
+}
+
+func (b *CircuitBreaker) allow(op *Operation) (uint64, bool, error) {
+	var changes []BreakerState
+	defer func() { b.notify(changes) }()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.queue) > 0 || b.replaying {
+		// 队列重放完成之前缓存中可能还有被排队的 Del 删除的旧值, 其他操作继续快速失败
+		changes = b.resume()
+		return 0, false, b.openError(op)
+	}
+	switch b.state {
+	case BreakerClosed:
+		return b.generation, false, nil
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.opt.OpenTimeout {
+			return 0, false, b.openError(op)
+		}
+		changes = b.transition(BreakerHalfOpen)
+	}
+	if b.probes >= b.opt.HalfOpenRequests {
+		return 0, false, b.openError(op)
+	}
+	b.probes++
+	return b.generation, true, nil
+}
+
+func (b *CircuitBreaker) record(generation uint64, probe bool, err error) {
+	var changes []BreakerState
+	defer func() { b.notify(changes) }()
+
+	failed := b.opt.IsFailure(err)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if generation != b.generation {
+		return
+	}
+	switch {
+	case failed && (probe || b.failures+1 >= b.opt.FailureThreshold):
+		changes = b.transition(BreakerOpen)
+	case failed:
+		b.failures++
+	case probe:
+		b.successes++
+		if b.successes >= b.opt.HalfOpenRequests {
+			changes = b.transition(BreakerClosed)
+		}
+	default:
+		b.failures = 0
+	}
+}
+
+// transition 调用时需持有锁, 返回状态变化序列(from, to)供解锁后通知
+func (b *CircuitBreaker) transition(to BreakerState) []BreakerState {
+	from := b.state
+	b.state = to
+	b.generation++
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+	if to == BreakerOpen {
+		b.openedAt = time.Now()
+	}
+	if to == BreakerClosed && len(b.queue) > 0 && !b.replaying {
+		b.replaying = true
+		go b.replay()
+	}
+	return []BreakerState{from, to}
+}
+
+func (b *CircuitBreaker) notify(changes []BreakerState) {
+	if len(changes) == 2 && b.opt.OnStateChange != nil {
+		b.opt.OnStateChange(changes[0], changes[1])
+	}
+}
+
+// replay 按顺序重放队列中的写操作, 半开状态下重放的操作作为试探
+// 失败时重新打开熔断器并停止, 剩余的操作在下次关闭后继续重放
+func (b *CircuitBreaker) replay() {
+	for {
+		b.lock.Lock()
+		if len(b.queue) == 0 || b.state == BreakerOpen ||
+			(b.state == BreakerHalfOpen && b.probes >= b.opt.HalfOpenRequests) {
+			b.replaying = false
+			b.lock.Unlock()
+			return
+		}
+		probe := b.state == BreakerHalfOpen
+		if probe {
+			b.probes++
+		}
+		w := b.queue[0]
+		generation := b.generation
+		b.lock.Unlock()
+
+		err := w.next(w.ctx, w.op)
+		b.record(generation, probe, err)
+		if b.opt.IsFailure(err) {
+			log.Printf("replay cache %s %v error:%s", w.op.Name, w.op.Keys, err.Error())
+			b.stopReplay(generation)
+			return
+		}
+		b.lock.Lock()
+		b.queue = b.queue[1:]
+		b.lock.Unlock()
+	}
+}
+
+func (b *CircuitBreaker) stopReplay(generation uint64) {
+	var changes []BreakerState
+	defer func() { b.notify(changes) }()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.replaying = false
+	if b.state == BreakerClosed && b.generation == generation {
+		changes = b.transition(BreakerOpen)
+	}
+}
+
+func (b *CircuitBreaker) openError(op *Operation) error {
+	retryAfter := time.Duration(0)
+	if b.state == BreakerOpen {
+		retryAfter = max(b.opt.OpenTimeout-time.Since(b.openedAt), 0)
+	}
+	return &CircuitOpenError{Op: op.Name, RetryAfter: retryAfter}
+}
@@ -0,0 +1,244 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+var errUnavailable = errors.New("connection refused")
+
+// outage 模拟Redis不可用, down 为true时所有操作失败, 名称为 held 的操作在 hold 关闭之前阻塞
+type outage struct {
+	down atomic.Bool
+	lock sync.Mutex
+	held string
+	hold chan struct{}
+}
+
+func (o *outage) middleware(next cache.Handler) cache.Handler {
+	return func(ctx context.Context, op *cache.Operation) error {
+		if o.down.Load() {
+			return errUnavailable
+		}
+		o.lock.Lock()
+		held, hold := o.held, o.hold
+		o.lock.Unlock()
+		if hold != nil && op.Name == held {
+			<-hold
+		}
+		return next(ctx, op)
+	}
+}
+
+func (o *outage) holdOps(name string) func() {
+	hold := make(chan struct{})
+	o.lock.Lock()
+	o.held, o.hold = name, hold
+	o.lock.Unlock()
+	return func() { close(hold) }
+}
+
+type stateLog struct {
+	lock    sync.Mutex
+	changes []string
+}
+
+func (l *stateLog) record(from, to cache.BreakerState) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.changes = append(l.changes, from.String()+"->"+to.String())
+}
+
+func (l *stateLog) get() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]string(nil), l.changes...)
+}
+
+func newBreakerCache(t *testing.T, opt cache.BreakerOption) (cache.ICommonCache, *cache.CircuitBreaker, *outage, *stateLog) {
+	t.Helper()
+	redisCache, _ := cachetest.NewRedisCache(t)
+	o := &outage{}
+	l := &stateLog{}
+	opt.OnStateChange = l.record
+	breaker := cache.NewCircuitBreaker(opt)
+	return cache.Wrap(redisCache, breaker.Middleware(), o.middleware), breaker, o, l
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	ctx := context.Background()
+	client, breaker, o, l := newBreakerCache(t, cache.BreakerOption{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond})
+	_ = client.Set(ctx, "a", []byte("1"), time.Minute)
+
+	o.down.Store(true)
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get(ctx, "a"); !errors.Is(err, errUnavailable) {
+			t.Fatalf("Get %d: want the backend error before opening, got %v", i, err)
+		}
+	}
+	if s := breaker.State(); s != cache.BreakerOpen {
+		t.Fatalf("want open after 2 failures, got %s", s)
+	}
+	_, err := client.Get(ctx, "a")
+	var openErr *cache.CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, cache.ErrCircuitOpen) {
+		t.Fatalf("want CircuitOpenError while open, got %v", err)
+	}
+	if openErr.Op != "get" || openErr.RetryAfter <= 0 || openErr.RetryAfter > 50*time.Millisecond {
+		t.Errorf("unexpected open error %+v", openErr)
+	}
+
+	o.down.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	if v, err := client.Get(ctx, "a"); err != nil || string(v) != "1" {
+		t.Fatalf("probe: want 1, got %q %v", v, err)
+	}
+	if s := breaker.State(); s != cache.BreakerClosed {
+		t.Errorf("want closed after a successful probe, got %s", s)
+	}
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if got := l.get(); !equalStrings(got, want) {
+		t.Errorf("state changes: want %v, got %v", want, got)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	ctx := context.Background()
+	client, breaker, o, _ := newBreakerCache(t, cache.BreakerOption{FailureThreshold: 2})
+
+	for i := 0; i < 3; i++ {
+		o.down.Store(true)
+		_, _ = client.Get(ctx, "a")
+		o.down.Store(false)
+		if _, err := client.Get(ctx, "a"); !errors.Is(err, cache.ErrNotFound) {
+			t.Fatalf("Get: want ErrNotFound, got %v", err)
+		}
+	}
+	if s := breaker.State(); s != cache.BreakerClosed {
+		t.Errorf("non-consecutive failures should not open the breaker, got %s", s)
+	}
+}
+
+func TestCircuitBreakerProbeFailureReopens(t *testing.T) {
+	ctx := context.Background()
+	client, breaker, o, l := newBreakerCache(t, cache.BreakerOption{FailureThreshold: 1, OpenTimeout: 30 * time.Millisecond})
+
+	o.down.Store(true)
+	_, _ = client.Get(ctx, "a")
+	time.Sleep(40 * time.Millisecond)
+	if _, err := client.Get(ctx, "a"); !errors.Is(err, errUnavailable) {
+		t.Fatalf("probe: want the backend error, got %v", err)
+	}
+	if s := breaker.State(); s != cache.BreakerOpen {
+		t.Fatalf("want open after a failed probe, got %s", s)
+	}
+	if _, err := client.Get(ctx, "a"); !errors.Is(err, cache.ErrCircuitOpen) {
+		t.Errorf("want fast fail after reopening, got %v", err)
+	}
+	want := []string{"closed->open", "open->half-open", "half-open->open"}
+	if got := l.get(); !equalStrings(got, want) {
+		t.Errorf("state changes: want %v, got %v", want, got)
+	}
+}
+
+func TestCircuitBreakerQueueFull(t *testing.T) {
+	ctx := context.Background()
+	client, breaker, o, _ := newBreakerCache(t, cache.BreakerOption{FailureThreshold: 1, QueueSize: 2})
+
+	o.down.Store(true)
+	_, _ = client.Get(ctx, "a")
+	for _, key := range []string{"a", "b"} {
+		if err := client.Del(ctx, key); err != nil {
+			t.Fatalf("Del %s: want queued, got %v", key, err)
+		}
+	}
+	if err := client.Del(ctx, "c"); !errors.Is(err, cache.ErrCircuitOpen) {
+		t.Errorf("want ErrCircuitOpen when the queue is full, got %v", err)
+	}
+	if n := breaker.Queued(); n != 2 {
+		t.Errorf("want 2 queued writes, got %d", n)
+	}
+}
+
+// 队列重放完成之前读取继续走回源, 不会读到排队中的 Del 还没有删除的旧值
+func TestCircuitBreakerReplaysQueueBeforeReads(t *testing.T) {
+	ctx := context.Background()
+	client, breaker, o, _ := newBreakerCache(t, cache.BreakerOption{FailureThreshold: 1, OpenTimeout: 30 * time.Millisecond, QueueSize: 10})
+	users := cache.CreateKvCache[user, int64](client, time.Minute)
+	_ = users.Set(ctx, 1, &user{Id: 1, Name: "old"})
+	_ = users.Set(ctx, 2, &user{Id: 2, Name: "old"})
+
+	o.down.Store(true)
+	_, _ = client.Get(ctx, "a")
+	if err := users.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete: want queued, got %v", err)
+	}
+	for _, name := range []string{"v1", "v2"} {
+		if err := users.Set(ctx, 2, &user{Id: 2, Name: name}); err != nil {
+			t.Fatalf("Set %s: want queued, got %v", name, err)
+		}
+	}
+	if n := breaker.Queued(); n != 3 {
+		t.Fatalf("want 3 queued writes, got %d", n)
+	}
+
+	o.down.Store(false)
+	release := o.holdOps("set")
+	time.Sleep(40 * time.Millisecond)
+	var loads atomic.Int32
+	loader := func(ctx context.Context, id int64) (*user, error) {
+		loads.Add(1)
+		return &user{Id: id, Name: "db"}, nil
+	}
+	// 读取触发半开并开始重放, 试探的 Del 成功后关闭, 之后的重放被阻塞在 Set 上
+	if u, err := users.GetOrLoad(ctx, 1, loader); err != nil || u.Name != "db" {
+		t.Fatalf("GetOrLoad during replay: want db, got %v %v", u, err)
+	}
+	cachetest.Eventually(t, "closed", func() bool { return breaker.State() == cache.BreakerClosed })
+	if _, err := users.Get(ctx, 2); !errors.Is(err, cache.ErrCircuitOpen) {
+		t.Errorf("Get during replay: want ErrCircuitOpen instead of a stale value, got %v", err)
+	}
+	if u, err := users.GetOrLoad(ctx, 2, loader); err != nil || u.Name != "db" {
+		t.Errorf("GetOrLoad during replay: want db, got %v %v", u, err)
+	}
+	if err := users.Set(ctx, 2, &user{Id: 2, Name: "v3"}); err != nil {
+		t.Fatalf("Set during replay: want queued, got %v", err)
+	}
+
+	release()
+	cachetest.Eventually(t, "reads to resume", func() bool {
+		_, err := users.Get(ctx, 1)
+		return !errors.Is(err, cache.ErrCircuitOpen)
+	})
+	if n, s := breaker.Queued(), breaker.State(); n != 0 || s != cache.BreakerClosed {
+		t.Errorf("want closed with an empty queue, got %s with %d queued", s, n)
+	}
+	if _, err := users.Get(ctx, 1); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("user 1: want deleted by the replay, got %v", err)
+	}
+	if u, err := users.Get(ctx, 2); err != nil || u.Name != "v3" {
+		t.Errorf("user 2: want the last queued write v3, got %v %v", u, err)
+	}
+	if u, err := users.GetOrLoad(ctx, 1, loader); err != nil || u.Name != "db" || loads.Load() != 3 {
+		t.Errorf("GetOrLoad after replay: want a second load, got %v %v after %d loads", u, err, loads.Load())
+	}
+}
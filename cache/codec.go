@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+var (
+	JSONCodec   Codec = jsonCodec{}
+	GobCodec    Codec = gobCodec{}
+	BinaryCodec Codec = binaryCodec{}
+	RawCodec    Codec = rawCodec{}
+)
+
+// Codec 缓存值的编解码, Marshal 接收值或指针, Unmarshal 接收指针
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// gobCodec 保留Go类型信息, 接口类型的字段需要先通过 gob.Register 注册具体类型
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// binaryCodec 紧凑的二进制格式, 值需实现 encoding.BinaryMarshaler/BinaryUnmarshaler,
+// 否则必须是 encoding/binary 支持的定长类型(数值、定长数组及只包含定长字段的结构体)
+type binaryCodec struct{}
+
+func (binaryCodec) Marshal(v any) ([]byte, error) {
+	if m, ok := v.(encoding.BinaryMarshaler); ok {
+		return m.MarshalBinary()
+	}
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (binaryCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(encoding.BinaryUnmarshaler); ok {
+		return m.UnmarshalBinary(data)
+	}
+	return binary.Read(bytes.NewReader(data), binary.LittleEndian, v)
+}
+
+// rawCodec 不做编码, 只支持 []byte 和 string
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	switch t := v.(type) {
+	case []byte:
+		return t, nil
+	case *[]byte:
+		return *t, nil
+	case string:
+		return []byte(t), nil
+	case *string:
+		return []byte(*t), nil
+	}
+	return nil, fmt.Errorf("raw codec: unsupported type %T", v)
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	switch t := v.(type) {
+	case *[]byte:
+		*t = append((*t)[:0], data...)
+		return nil
+	case *string:
+		*t = string(data)
+		return nil
+	}
+	return fmt.Errorf("raw codec: unsupported type %T", v)
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type codecValue struct {
+	Id   int64
+	Name string
+	Tags []string
+}
+
+type point struct {
+	X, Y int32
+}
+
+// version 实现 encoding.BinaryMarshaler, binaryCodec 优先使用
+type version struct {
+	major, minor byte
+}
+
+func (v version) MarshalBinary() ([]byte, error) {
+	return []byte{v.major, v.minor}, nil
+}
+
+func (v *version) UnmarshalBinary(data []byte) error {
+	v.major, v.minor = data[0], data[1]
+	return nil
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	value := codecValue{Id: 1, Name: "a", Tags: []string{"x", "y"}}
+	for name, codec := range map[string]Codec{"json": JSONCodec, "gob": GobCodec} {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.Marshal(&value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := new(codecValue)
+			if err := codec.Unmarshal(data, got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, value) {
+				t.Errorf("want %+v, got %+v", value, *got)
+			}
+		})
+	}
+}
+
+func TestBinaryCodec(t *testing.T) {
+	data, err := BinaryCodec.Marshal(&point{X: 1, Y: -2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 8 {
+		t.Errorf("fixed-size struct: want 8 bytes, got %d", len(data))
+	}
+	p := new(point)
+	if err := BinaryCodec.Unmarshal(data, p); err != nil || *p != (point{X: 1, Y: -2}) {
+		t.Errorf("want {1 -2}, got %+v %v", *p, err)
+	}
+
+	data, err = BinaryCodec.Marshal(version{major: 1, minor: 2})
+	if err != nil || !bytes.Equal(data, []byte{1, 2}) {
+		t.Fatalf("BinaryMarshaler: want [1 2], got %v %v", data, err)
+	}
+	v := new(version)
+	if err := BinaryCodec.Unmarshal(data, v); err != nil || *v != (version{major: 1, minor: 2}) {
+		t.Errorf("BinaryUnmarshaler: want {1 2}, got %+v %v", *v, err)
+	}
+
+	if _, err := BinaryCodec.Marshal(&codecValue{}); err == nil {
+		t.Error("variable-size struct should be rejected")
+	}
+}
+
+func TestRawCodec(t *testing.T) {
+	for _, v := range []any{[]byte("raw"), "raw", new(string), new([]byte)} {
+		if s, ok := v.(*string); ok {
+			*s = "raw"
+		}
+		if b, ok := v.(*[]byte); ok {
+			*b = []byte("raw")
+		}
+		data, err := RawCodec.Marshal(v)
+		if err != nil || string(data) != "raw" {
+			t.Errorf("Marshal %T: want raw, got %q %v", v, data, err)
+		}
+	}
+	var s string
+	if err := RawCodec.Unmarshal([]byte("raw"), &s); err != nil || s != "raw" {
+		t.Errorf("Unmarshal string: want raw, got %q %v", s, err)
+	}
+	var b []byte
+	if err := RawCodec.Unmarshal([]byte("raw"), &b); err != nil || string(b) != "raw" {
+		t.Errorf("Unmarshal []byte: want raw, got %q %v", b, err)
+	}
+	if _, err := RawCodec.Marshal(1); err == nil {
+		t.Error("Marshal int should fail")
+	}
+	if err := RawCodec.Unmarshal([]byte("1"), new(int)); err == nil {
+		t.Error("Unmarshal int should fail")
+	}
+}
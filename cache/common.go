@@ -2,23 +2,113 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrNotFound 缓存未命中, 所有 ICommonCache 的实现都需要将未命中转换为该错误
+var ErrNotFound = errors.New("cache: not found")
+
+type ZMember struct {
+	Member []byte
+	Score  float64
+}
+
 type ICommonCache interface {
 	Get(ctx context.Context, key string) ([]byte, error)
 	GetInt(ctx context.Context, key string) (int64, error)
 	Del(ctx context.Context, keys ...string) error
 	Set(ctx context.Context, key string, val []byte, expiration time.Duration) error
 
+	// TTL 返回key的剩余过期时间, key不存在时返回 ErrNotFound, 永不过期时返回值小于0
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Expire 重新设置过期时间并返回key是否存在, expiration 小于等于0时删除key
+	Expire(ctx context.Context, key string, expiration time.Duration) (bool, error)
+	// MExpire 批量重新设置过期时间, 不存在的key被忽略, expiration 小于等于0时删除keys
+	MExpire(ctx context.Context, keys []string, expiration time.Duration) error
+	// ExpireAt 设置过期时间点并返回key是否存在, t 不晚于当前时间时删除key
+	ExpireAt(ctx context.Context, key string, t time.Time) (bool, error)
+	// Persist 移除过期时间, 返回是否移除成功, key不存在或本来就永不过期时返回false
+	Persist(ctx context.Context, key string) (bool, error)
+	// Exists 返回keys中存在的数量, 重复的key重复计算
+	Exists(ctx context.Context, keys ...string) (int64, error)
+
+	// MGet 批量读取, 返回值与keys一一对应, 未命中的key对应位置为nil
+	MGet(ctx context.Context, keys ...string) ([][]byte, error)
+	MSet(ctx context.Context, values map[string][]byte, expiration time.Duration) error
+
 	HMSet(ctx context.Context, key string, value map[string][]byte, expiration time.Duration) error
 	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	HGetAllBytes(ctx context.Context, key string) (map[string][]byte, error)
+	HGet(ctx context.Context, key string, field string) ([]byte, error)
+	HSet(ctx context.Context, key string, field string, val []byte, expiration time.Duration) error
 	HDel(ctx context.Context, key string, fields ...string) error
 
+	// RPush/LPush 写入列表并返回列表长度, expiration 大于0时重新设置过期时间
+	RPush(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) (int64, error)
+	LPush(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) (int64, error)
+	// LRange/LTrim 的下标规则与Redis一致, 负数表示从列表末尾开始计算
+	LRange(ctx context.Context, key string, start, stop int64) ([][]byte, error)
+	LTrim(ctx context.Context, key string, start, stop int64) error
+	LLen(ctx context.Context, key string) (int64, error)
+	// RPushTrim/LPushTrim 与 RPush/LPush 相同, 并在同一个事务中将列表裁剪到最多 maxLen 个元素,
+	// RPushTrim 保留末尾的元素, LPushTrim 保留开头的元素, 返回裁剪后的长度, maxLen 小于等于0时不裁剪
+	RPushTrim(ctx context.Context, key string, maxLen int64, expiration time.Duration, vals ...[]byte) (int64, error)
+	LPushTrim(ctx context.Context, key string, maxLen int64, expiration time.Duration, vals ...[]byte) (int64, error)
+	// LReplace 在同一个事务中删除原列表并写入 vals, vals 为空时只删除
+	LReplace(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) error
+
+	// ZAdd/ZIncrBy expiration 大于0时重新设置过期时间
+	ZAdd(ctx context.Context, key string, member []byte, score float64, expiration time.Duration) error
+	ZIncrBy(ctx context.Context, key string, member []byte, incr float64, expiration time.Duration) (float64, error)
+	ZScore(ctx context.Context, key string, member []byte) (float64, error)
+	// ZRank reverse 为true时按分数从高到低计算排名, 成员不存在时返回 ErrNotFound
+	ZRank(ctx context.Context, key string, member []byte, reverse bool) (int64, error)
+	ZRange(ctx context.Context, key string, start, stop int64, reverse bool) ([]ZMember, error)
+	// ZRangeByScore 按分数从低到高返回 [min, max] 之间的成员, count 小于等于0时不限制数量
+	ZRangeByScore(ctx context.Context, key string, min, max float64, offset, count int64) ([]ZMember, error)
+	ZRem(ctx context.Context, key string, members ...[]byte) error
+
 	Incr(ctx context.Context, key string, expiration time.Duration) error
 	IncrBy(ctx context.Context, key string, val int64, expiration time.Duration) error
+	// IncrByEx 原子增加并返回增加后的值, sliding 为false时只在key新建时设置过期时间, 为true时每次增加都重新设置过期时间
+	IncrByEx(ctx context.Context, key string, val int64, expiration time.Duration, sliding bool) (int64, error)
 
 	SetNX(ctx context.Context, key string, val interface{}, expiration time.Duration) (bool, error)
+	// CompareAndDelete 当前值等于val时删除, 返回是否删除
+	CompareAndDelete(ctx context.Context, key string, val []byte) (bool, error)
+	// CompareAndExpire 当前值等于val时重新设置过期时间, 返回是否设置成功
+	CompareAndExpire(ctx context.Context, key string, val []byte, expiration time.Duration) (bool, error)
+
+	// Scan 遍历匹配 pattern 的key, pattern 的语法与Redis SCAN MATCH一致, 且与传给其他方法的key一样不包含缓存前缀,
+	// 遍历期间被修改的key可能重复出现或被遗漏, fn 返回错误时停止遍历并返回该错误
+	Scan(ctx context.Context, pattern string, fn func(key string) error) error
+	// DeleteByPattern 删除匹配 pattern 的key, 返回删除的数量
+	DeleteByPattern(ctx context.Context, pattern string) (int64, error)
+
+	// Eval 原子执行Lua脚本, keys 会自动加上缓存前缀, 不支持脚本的实现返回 ErrNotSupported
+	Eval(ctx context.Context, script *Script, keys []string, args ...interface{}) (interface{}, error)
 
 	Clone() ICommonCache
 }
+
+// IWrapper 装饰 ICommonCache 的实现(如指标统计)通过 Unwrap 返回被装饰的实例
+type IWrapper interface {
+	Unwrap() ICommonCache
+}
+
+// Unwrap 逐层解除装饰, 直到找到实现了 T 的实例
+func Unwrap[T any](client ICommonCache) (T, bool) {
+	for client != nil {
+		if t, ok := client.(T); ok {
+			return t, true
+		}
+		w, ok := client.(IWrapper)
+		if !ok {
+			break
+		}
+		client = w.Unwrap()
+	}
+	var t T
+	return t, false
+}
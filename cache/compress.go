@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+const defaultCompressThreshold = 1024
+
+type Compression byte
+
+// 写入的数据总是以一个头字节标记压缩算法, 未达到阈值的数据以 compressNone 开头
+// 不以头字节开头的数据是开启压缩前写入的旧数据, 按未压缩处理, 只有JSON等文本编码能保证旧数据不以头字节开头,
+// 二进制或原始编码的缓存开启压缩时需要更换key(如 WithGeneration), 否则旧数据可能被误判
+const (
+	CompressFlate Compression = 0x01
+	CompressGzip  Compression = 0x02
+	// compressNone 不使用0x00, 避免与负缓存的空标记冲突
+	compressNone Compression = 0x03
+)
+
+type compressCodec struct {
+	codec     Codec
+	threshold int
+	algorithm Compression
+}
+
+// CompressCodec 编码结果超过 threshold 字节时进行压缩, 默认使用 flate 算法
+func CompressCodec(codec Codec, threshold int, algorithm ...Compression) Codec {
+	if threshold <= 0 {
+		threshold = defaultCompressThreshold
+	}
+	c := &compressCodec{
+		codec:     codec,
+		threshold: threshold,
+		algorithm: CompressFlate,
+	}
+	if len(algorithm) > 0 && algorithm[0] == CompressGzip {
+		c.algorithm = CompressGzip
+	}
+	return c
+}
+
+func (c *compressCodec) Marshal(v any) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < c.threshold {
+		return plain(data), nil
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Grow(len(data)/2 + 1)
+	buf.WriteByte(byte(c.algorithm))
+	var w io.WriteCloser
+	switch c.algorithm {
+	case CompressGzip:
+		w = gzip.NewWriter(buf)
+	default:
+		w, _ = flate.NewWriter(buf, flate.DefaultCompression)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() > len(data) {
+		return plain(data), nil
+	}
+	return buf.Bytes(), nil
+}
+
+func plain(data []byte) []byte {
+	rs := make([]byte, 0, len(data)+1)
+	rs = append(rs, byte(compressNone))
+	return append(rs, data...)
+}
+
+func (c *compressCodec) Unmarshal(data []byte, v any) error {
+	data, err := decompress(data)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(data, v)
+}
+
+// decompress 按头字节解压, 头字节匹配但解压失败时返回错误
+func decompress(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	var r io.ReadCloser
+	switch Compression(data[0]) {
+	case compressNone:
+		return data[1:], nil
+	case CompressFlate:
+		r = flate.NewReader(bytes.NewReader(data[1:]))
+	case CompressGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("cache: decompress: %w", err)
+		}
+		r = gr
+	default:
+		return data, nil
+	}
+	defer r.Close()
+	rs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cache: decompress: %w", err)
+	}
+	return rs, nil
+}
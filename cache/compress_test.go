@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	large := strings.Repeat("compressible ", 200)
+	for _, algorithm := range []Compression{CompressFlate, CompressGzip} {
+		codec := CompressCodec(JSONCodec, 64, algorithm)
+		for _, v := range []string{"small", large} {
+			data, err := codec.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v == large && (Compression(data[0]) != algorithm || len(data) >= len(large)) {
+				t.Errorf("large value should be compressed with %d, got header %d size %d", algorithm, data[0], len(data))
+			}
+			if v == "small" && Compression(data[0]) != compressNone {
+				t.Errorf("small value should be stored with the plain header, got %d", data[0])
+			}
+			var got string
+			if err := codec.Unmarshal(data, &got); err != nil || got != v {
+				t.Errorf("round trip: want %d bytes, got %d bytes %v", len(v), len(got), err)
+			}
+		}
+	}
+}
+
+// 原始编码的数据可能以压缩头字节开头, 写入时必须加上头字节才能区分
+func TestCompressRawPayloadStartingWithHeaderByte(t *testing.T) {
+	codec := CompressCodec(RawCodec, 1024)
+	flated, err := CompressCodec(RawCodec, 1).Marshal([]byte(strings.Repeat("a", 100)))
+	if err != nil || Compression(flated[0]) != CompressFlate {
+		t.Fatalf("want flate payload, got %v", err)
+	}
+	// 该值本身恰好是一段合法的压缩数据, 不能被解压
+	data, err := codec.Marshal(flated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	if err := codec.Unmarshal(data, &got); err != nil || !bytes.Equal(got, flated) {
+		t.Errorf("raw payload was altered: %v", err)
+	}
+}
+
+func TestCompressReadsLegacyPlainData(t *testing.T) {
+	codec := CompressCodec(JSONCodec, 64)
+	var got string
+	if err := codec.Unmarshal([]byte(`"legacy"`), &got); err != nil || got != "legacy" {
+		t.Errorf("legacy JSON: want legacy, got %q %v", got, err)
+	}
+}
+
+func TestCompressCorruptedData(t *testing.T) {
+	codec := CompressCodec(RawCodec, 64)
+	var got []byte
+	if err := codec.Unmarshal([]byte{byte(CompressGzip), 1, 2, 3}, &got); err == nil {
+		t.Error("corrupted gzip payload should fail")
+	}
+	if err := codec.Unmarshal([]byte{byte(CompressFlate), 0xff, 0xff}, &got); err == nil {
+		t.Error("corrupted flate payload should fail")
+	}
+}
+
+func TestCompressedValueIsNotTombstone(t *testing.T) {
+	data, err := CompressCodec(RawCodec, 1024).Marshal(tombstone[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isTombstone(data) {
+		t.Error("plain header must not produce the tombstone marker")
+	}
+}
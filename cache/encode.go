@@ -1,20 +1,18 @@
 package cache
 
-import "encoding/json"
-
-func decodeList[T any](bytes []byte) ([]T, error) {
+func decodeList[T any](codec Codec, bytes []byte) ([]T, error) {
 
 	t := make([]T, 0)
-	err := json.Unmarshal(bytes, &t)
+	err := codec.Unmarshal(bytes, &t)
 	if err != nil {
 		return nil, err
 	}
 
 	return t, nil
 }
-func encode[T any](t T) ([]byte, error) {
+func encode[T any](codec Codec, t T) ([]byte, error) {
 
-	bytes, err := json.Marshal(t)
+	bytes, err := codec.Marshal(t)
 	if err != nil {
 		return nil, err
 	}
@@ -22,10 +20,10 @@ func encode[T any](t T) ([]byte, error) {
 	return bytes, nil
 
 }
-func decode[T any](bytes []byte) (*T, error) {
+func decode[T any](codec Codec, bytes []byte) (*T, error) {
 
 	t := new(T)
-	err := json.Unmarshal(bytes, t)
+	err := codec.Unmarshal(bytes, t)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,101 @@
+package events
+
+import (
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_redis"
+)
+
+const (
+	defaultBatchSize     = 10
+	defaultBlock         = time.Second * 5
+	defaultClaimIdle     = time.Minute
+	defaultMaxDeliveries = 5
+	deadLetterSuffix     = ":dead"
+	payloadField         = "data"
+
+	// 读取失败后的重试间隔
+	minBackoff = time.Millisecond * 100
+	maxBackoff = time.Second * 5
+)
+
+// Option 事件发布与订阅的可选配置
+type Option func(o *options)
+
+type options struct {
+	codec         cache.Codec
+	batchSize     int64
+	block         time.Duration
+	claimIdle     time.Duration
+	maxDeliveries int64
+	deadLetter    string
+	maxLen        int64
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		codec:         cache.JSONCodec,
+		batchSize:     defaultBatchSize,
+		block:         defaultBlock,
+		claimIdle:     defaultClaimIdle,
+		maxDeliveries: defaultMaxDeliveries,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCodec 事件的编解码方式, 默认为 cache.JSONCodec
+func WithCodec(codec cache.Codec) Option {
+	return func(o *options) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithBatchSize stream 每次读取的最大消息数
+func WithBatchSize(n int64) Option {
+	return func(o *options) {
+		o.batchSize = n
+	}
+}
+
+// WithClaimIdle stream 中已投递但超过 idle 仍未确认的消息会被重新认领处理
+func WithClaimIdle(idle time.Duration) Option {
+	return func(o *options) {
+		o.claimIdle = idle
+	}
+}
+
+// WithMaxDeliveries 消息投递次数超过 n 后转入死信stream
+func WithMaxDeliveries(n int64) Option {
+	return func(o *options) {
+		o.maxDeliveries = n
+	}
+}
+
+// WithDeadLetter 死信stream的名称, 默认为 stream 名称加上 ":dead"
+func WithDeadLetter(stream string) Option {
+	return func(o *options) {
+		o.deadLetter = stream
+	}
+}
+
+// WithMaxLen stream 保留的大致最大长度, 0表示不限制
+func WithMaxLen(n int64) Option {
+	return func(o *options) {
+		o.maxLen = n
+	}
+}
+
+// redisClient 事件功能依赖Redis, client 需要由 cache_redis 创建
+func redisClient(client cache.ICommonCache) (cache_redis.IRedisClient, error) {
+	rc, ok := cache.Unwrap[cache_redis.IRedisClient](client)
+	if !ok {
+		return nil, cache.ErrNotSupported
+	}
+	return rc, nil
+}
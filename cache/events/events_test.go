@@ -0,0 +1,304 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+	"github.com/mengri/utils-store/cache/cache_redis"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+type event struct {
+	Id int64
+}
+
+// consume 在后台消费, 测试结束时停止
+func consume[T any](t *testing.T, c *StreamConsumer[T], handler func(ctx context.Context, msg *Message[T]) error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Consume(ctx, handler)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Consume: want context.Canceled, got %v", err)
+		}
+	})
+}
+
+func pending(client cache.ICommonCache, stream, group string) ([]redis.XPendingExt, error) {
+	rc := client.(cache_redis.IRedisClient)
+	return rc.RedisClient().XPendingExt(context.Background(), &redis.XPendingExtArgs{
+		Stream: rc.Key(stream), Group: group, Start: "-", End: "+", Count: 100,
+	}).Result()
+}
+
+func pendingCount(t *testing.T, client cache.ICommonCache, stream, group string) int {
+	t.Helper()
+	p, err := pending(client, stream, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(p)
+}
+
+// waitGroup 消费组从创建时的最新位置开始读取, 写入前需要等待 Consume 创建消费组
+func waitGroup(t *testing.T, client cache.ICommonCache, stream, group string) {
+	t.Helper()
+	cachetest.Eventually(t, "consumer group", func() bool {
+		_, err := pending(client, stream, group)
+		return err == nil
+	})
+}
+
+func deadLetters(t *testing.T, client cache.ICommonCache, stream string) []redis.XMessage {
+	t.Helper()
+	rc := client.(cache_redis.IRedisClient)
+	messages, err := rc.RedisClient().XRange(context.Background(), rc.Key(stream), "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestRequiresRedis(t *testing.T) {
+	client := cache_memory.NewCommonCache(cache_memory.Option{})
+	defer client.(io.Closer).Close()
+	if _, err := NewPublisher[event](client, "c"); !errors.Is(err, cache.ErrNotSupported) {
+		t.Errorf("NewPublisher: want ErrNotSupported, got %v", err)
+	}
+	if _, err := NewStreamConsumer[event](client, "s", "g", "c"); !errors.Is(err, cache.ErrNotSupported) {
+		t.Errorf("NewStreamConsumer: want ErrNotSupported, got %v", err)
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	pub, err := NewPublisher[event](client, "changed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := NewSubscriber[event](client, []string{"changed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan int64, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = sub.Subscribe(ctx, func(ctx context.Context, e *event) error {
+			received <- e.Id
+			return nil
+		})
+	}()
+	// 订阅建立之前发布的事件会丢失, 持续发布直到收到
+	deadline := time.After(3 * time.Second)
+	for {
+		_ = pub.Publish(ctx, &event{Id: 7})
+		select {
+		case id := <-received:
+			if id != 7 {
+				t.Errorf("want event 7, got %d", id)
+			}
+			return
+		case <-deadline:
+			t.Fatal("timeout waiting for event")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func TestStreamConsume(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	producer, err := NewStreamProducer[event](client, "events", WithMaxLen(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer, err := NewStreamConsumer[event](client, "events", "g", "c1", WithClaimIdle(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lock sync.Mutex
+	var got []int64
+	consume(t, consumer, func(ctx context.Context, msg *Message[event]) error {
+		lock.Lock()
+		defer lock.Unlock()
+		got = append(got, msg.Value.Id)
+		return nil
+	})
+	waitGroup(t, client, "events", "g")
+
+	for i := int64(1); i <= 3; i++ {
+		if _, err := producer.Add(context.Background(), &event{Id: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cachetest.Eventually(t, "3 events", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(got) == 3
+	})
+	if n := pendingCount(t, client, "events", "g"); n != 0 {
+		t.Errorf("handled messages should be acked, %d pending", n)
+	}
+}
+
+func TestStreamDeadLetter(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	producer, _ := NewStreamProducer[event](client, "jobs")
+	consumer, err := NewStreamConsumer[event](client, "jobs", "g", "c1",
+		WithClaimIdle(50*time.Millisecond), WithMaxDeliveries(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lock sync.Mutex
+	deliveries := make(map[int64][]int64)
+	consume(t, consumer, func(ctx context.Context, msg *Message[event]) error {
+		lock.Lock()
+		defer lock.Unlock()
+		deliveries[msg.Value.Id] = append(deliveries[msg.Value.Id], msg.Deliveries)
+		if msg.Value.Id == 1 {
+			return errors.New("always fails")
+		}
+		return nil
+	})
+	waitGroup(t, client, "jobs", "g")
+
+	id, _ := producer.Add(context.Background(), &event{Id: 1})
+	_, _ = producer.Add(context.Background(), &event{Id: 2})
+	// 无法解码的消息直接转入死信
+	rc := client.(cache_redis.IRedisClient)
+	badId, _ := rc.RedisClient().XAdd(context.Background(), &redis.XAddArgs{
+		Stream: rc.Key("jobs"), Values: map[string]interface{}{"other": "x"},
+	}).Result()
+
+	cachetest.Eventually(t, "dead letters", func() bool {
+		return len(deadLetters(t, client, "jobs:dead")) == 2
+	})
+	reasons := make(map[string]string)
+	for _, m := range deadLetters(t, client, "jobs:dead") {
+		reasons[m.Values["source_id"].(string)] = m.Values["reason"].(string)
+	}
+	if reasons[id] != "exceeded 2 deliveries" {
+		t.Errorf("failing message: want exceeded 2 deliveries, got %q", reasons[id])
+	}
+	if reasons[badId] == "" {
+		t.Errorf("undecodable message should be dead-lettered, got %v", reasons)
+	}
+	if n := pendingCount(t, client, "jobs", "g"); n != 0 {
+		t.Errorf("dead-lettered messages should be acked, %d pending", n)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if got := deliveries[1]; len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("failing message: want deliveries [1 2], got %v", got)
+	}
+	if got := deliveries[2]; len(got) != 1 {
+		t.Errorf("successful message should be delivered once, got %v", got)
+	}
+}
+
+// Redis重启后读取失败不会使 Consume 退出, 恢复后重新创建消费组并继续消费
+func TestStreamConsumeSurvivesRestart(t *testing.T) {
+	client, s := cachetest.NewRedisCache(t)
+	producer, _ := NewStreamProducer[event](client, "events")
+	consumer, _ := NewStreamConsumer[event](client, "events", "g", "c1", WithClaimIdle(200*time.Millisecond))
+
+	received := make(chan int64, 10)
+	consume(t, consumer, func(ctx context.Context, msg *Message[event]) error {
+		received <- msg.Value.Id
+		return nil
+	})
+	waitGroup(t, client, "events", "g")
+
+	addr := s.Addr()
+	_ = s.Close()
+	time.Sleep(300 * time.Millisecond)
+	cachetest.StartServer(t, cachetest.ServerOption{Addr: addr})
+
+	waitGroup(t, client, "events", "g")
+	if _, err := producer.Add(context.Background(), &event{Id: 9}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-received:
+		if id != 9 {
+			t.Errorf("want event 9, got %d", id)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("consumer should resume after the restart")
+	}
+}
+
+// claimIdle 为0时不能把 Block 设为0, 否则读取会永久阻塞
+func TestStreamReadBlock(t *testing.T) {
+	tests := []struct {
+		idle time.Duration
+		want time.Duration
+	}{
+		{0, defaultBlock},
+		{100 * time.Millisecond, 100 * time.Millisecond},
+		{time.Minute, defaultBlock},
+	}
+	for _, tt := range tests {
+		c := &StreamConsumer[event]{opts: newOptions([]Option{WithClaimIdle(tt.idle)})}
+		if got := c.readBlock(); got != tt.want {
+			t.Errorf("claimIdle %s: want block %s, got %s", tt.idle, tt.want, got)
+		}
+	}
+}
+
+// 一次认领需要遍历完所有超时的待确认消息, 而不只是第一批
+func TestStreamReclaimAllPending(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	ctx := context.Background()
+	producer, _ := NewStreamProducer[event](client, "jobs")
+	consumer, err := NewStreamConsumer[event](client, "jobs", "g", "c1",
+		WithClaimIdle(20*time.Millisecond), WithBatchSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := consumer.createGroup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 5; i++ {
+		_, _ = producer.Add(ctx, &event{Id: i})
+	}
+	// 另一个消费者读取后不确认, 模拟消费者崩溃
+	rc := client.(cache_redis.IRedisClient)
+	if err := rc.RedisClient().XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "g", Consumer: "c0", Streams: []string{rc.Key("jobs"), ">"},
+	}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	var got []int64
+	err = consumer.reclaim(ctx, func(ctx context.Context, msg *Message[event]) error {
+		got = append(got, msg.Value.Id)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 {
+		t.Errorf("want all 5 pending messages reclaimed in one pass, got %v", got)
+	}
+	if n := pendingCount(t, client, "jobs", "g"); n != 0 {
+		t.Errorf("reclaimed messages should be acked, %d pending", n)
+	}
+}
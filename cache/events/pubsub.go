@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"log"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+// Publisher 通过 Redis Pub/Sub 发布事件, 不保证送达, 发布时没有在线的订阅者事件会丢失
+type Publisher[T any] struct {
+	client  redis.UniversalClient
+	channel string
+	codec   cache.Codec
+}
+
+func NewPublisher[T any](client cache.ICommonCache, channel string, opts ...Option) (*Publisher[T], error) {
+	rc, err := redisClient(client)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	return &Publisher[T]{
+		client:  rc.RedisClient(),
+		channel: rc.Key(channel),
+		codec:   o.codec,
+	}, nil
+}
+
+func (p *Publisher[T]) Publish(ctx context.Context, t *T) error {
+	data, err := p.codec.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return p.client.Publish(ctx, p.channel, data).Err()
+}
+
+type Subscriber[T any] struct {
+	client   redis.UniversalClient
+	channels []string
+	codec    cache.Codec
+}
+
+func NewSubscriber[T any](client cache.ICommonCache, channels []string, opts ...Option) (*Subscriber[T], error) {
+	rc, err := redisClient(client)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	keys := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		keys = append(keys, rc.Key(ch))
+	}
+	return &Subscriber[T]{
+		client:   rc.RedisClient(),
+		channels: keys,
+		codec:    o.codec,
+	}, nil
+}
+
+// Subscribe 订阅并阻塞处理事件直到ctx结束, 无法解码的事件和handler返回的错误只记录日志
+func (s *Subscriber[T]) Subscribe(ctx context.Context, handler func(ctx context.Context, t *T) error) error {
+	pubsub := s.client.Subscribe(ctx, s.channels...)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			t := new(T)
+			if err := s.codec.Unmarshal([]byte(msg.Payload), t); err != nil {
+				log.Printf("decode event from %s error:%s", msg.Channel, err.Error())
+				continue
+			}
+			if err := handler(ctx, t); err != nil {
+				log.Printf("handle event from %s error:%s", msg.Channel, err.Error())
+			}
+		}
+	}
+}
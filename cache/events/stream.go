@@ -0,0 +1,274 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+// Message stream中的消息, Deliveries 为该消息被投递的次数
+type Message[T any] struct {
+	ID         string
+	Value      *T
+	Deliveries int64
+}
+
+// StreamProducer 向 Redis Stream 写入事件
+type StreamProducer[T any] struct {
+	client redis.UniversalClient
+	stream string
+	codec  cache.Codec
+	maxLen int64
+}
+
+func NewStreamProducer[T any](client cache.ICommonCache, stream string, opts ...Option) (*StreamProducer[T], error) {
+	rc, err := redisClient(client)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	return &StreamProducer[T]{
+		client: rc.RedisClient(),
+		stream: rc.Key(stream),
+		codec:  o.codec,
+		maxLen: o.maxLen,
+	}, nil
+}
+
+func (p *StreamProducer[T]) Add(ctx context.Context, t *T) (string, error) {
+	data, err := p.codec.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	args := &redis.XAddArgs{
+		Stream: p.stream,
+		Values: map[string]interface{}{payloadField: data},
+	}
+	if p.maxLen > 0 {
+		args.MaxLen = p.maxLen
+		args.Approx = true
+	}
+	return p.client.XAdd(ctx, args).Result()
+}
+
+// StreamConsumer 通过消费组读取 Redis Stream, 保证至少一次投递:
+// handler 返回nil时确认消息, 返回错误的消息保留在待确认列表中, 超过 claimIdle 后被重新认领,
+// 投递次数超过 maxDeliveries 或无法解码的消息转入死信stream, 死信同样是至少一次写入, 可以通过 source_id 去重
+type StreamConsumer[T any] struct {
+	client     redis.UniversalClient
+	stream     string
+	group      string
+	consumer   string
+	deadLetter string
+	opts       *options
+}
+
+func NewStreamConsumer[T any](client cache.ICommonCache, stream, group, consumer string, opts ...Option) (*StreamConsumer[T], error) {
+	rc, err := redisClient(client)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	deadLetter := o.deadLetter
+	if deadLetter == "" {
+		deadLetter = fmt.Sprint(stream, deadLetterSuffix)
+	}
+	return &StreamConsumer[T]{
+		client:     rc.RedisClient(),
+		stream:     rc.Key(stream),
+		group:      group,
+		consumer:   consumer,
+		deadLetter: rc.Key(deadLetter),
+		opts:       o,
+	}, nil
+}
+
+// Consume 阻塞消费直到ctx结束, 读取失败时按指数退避重试, 消费组不存在(如Redis重启后数据丢失)时重新创建
+func (c *StreamConsumer[T]) Consume(ctx context.Context, handler func(ctx context.Context, msg *Message[T]) error) error {
+	if err := c.createGroup(ctx); err != nil {
+		return err
+	}
+
+	backoff := minBackoff
+	lastClaim := time.Now()
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= c.opts.claimIdle {
+			if err := c.reclaim(ctx, handler); err != nil && ctx.Err() == nil {
+				log.Printf("reclaim stream %s error:%s", c.stream, err.Error())
+			}
+			lastClaim = time.Now()
+		}
+
+		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.group,
+			Consumer: c.consumer,
+			Streams:  []string{c.stream, ">"},
+			Count:    c.opts.batchSize,
+			Block:    c.readBlock(),
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			log.Printf("read stream %s error:%s, retry in %s", c.stream, err.Error(), backoff)
+			if !sleep(ctx, backoff) {
+				break
+			}
+			backoff = min(backoff*2, maxBackoff)
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				if err := c.createGroup(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("create stream %s group %s error:%s", c.stream, c.group, err.Error())
+				}
+			}
+			continue
+		}
+		backoff = minBackoff
+		for _, s := range streams {
+			for _, m := range s.Messages {
+				c.handle(ctx, m, 1, handler)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+func (c *StreamConsumer[T]) createGroup(ctx context.Context) error {
+	err := c.client.XGroupCreateMkStream(ctx, c.stream, c.group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// sleep 等待 d, ctx结束时提前返回false
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// readBlock 读取的阻塞时间, 不超过 claimIdle 以便按时认领, claimIdle 为0时不限制(Block 为0表示永久阻塞)
+func (c *StreamConsumer[T]) readBlock() time.Duration {
+	if c.opts.claimIdle > 0 {
+		return min(c.opts.block, c.opts.claimIdle)
+	}
+	return c.opts.block
+}
+
+// reclaim 分批认领所有超时未确认的消息, 直到待确认列表遍历完, 投递次数过多的消息转入死信stream
+func (c *StreamConsumer[T]) reclaim(ctx context.Context, handler func(ctx context.Context, msg *Message[T]) error) error {
+	cursor := "-"
+	for ctx.Err() == nil {
+		next, err := c.reclaimBatch(ctx, cursor, handler)
+		if err != nil || next == "" {
+			return err
+		}
+		cursor = next
+	}
+	return ctx.Err()
+}
+
+// reclaimBatch 认领 cursor 之后的一批消息, 返回下一批的起始位置, 没有更多消息时返回空
+func (c *StreamConsumer[T]) reclaimBatch(ctx context.Context, cursor string, handler func(ctx context.Context, msg *Message[T]) error) (string, error) {
+	pending, err := c.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: c.stream,
+		Group:  c.group,
+		Idle:   c.opts.claimIdle,
+		Start:  cursor,
+		End:    "+",
+		Count:  c.opts.batchSize,
+	}).Result()
+	if err != nil {
+		return "", err
+	}
+	deliveries := make(map[string]int64, len(pending))
+	ids := make([]string, 0, len(pending))
+	for _, p := range pending {
+		deliveries[p.ID] = p.RetryCount
+		ids = append(ids, p.ID)
+	}
+	if len(ids) == 0 {
+		return "", nil
+	}
+	messages, err := c.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   c.stream,
+		Group:    c.group,
+		Consumer: c.consumer,
+		MinIdle:  c.opts.claimIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return "", err
+	}
+	for _, m := range messages {
+		// XCLAIM 会将投递次数加一
+		n := deliveries[m.ID] + 1
+		if c.opts.maxDeliveries > 0 && n > c.opts.maxDeliveries {
+			c.deadLettering(ctx, m, fmt.Sprintf("exceeded %d deliveries", c.opts.maxDeliveries))
+			continue
+		}
+		c.handle(ctx, m, n, handler)
+	}
+	if int64(len(ids)) < c.opts.batchSize {
+		return "", nil
+	}
+	// 区间以 ( 开头表示不包含起始ID
+	return "(" + ids[len(ids)-1], nil
+}
+
+func (c *StreamConsumer[T]) handle(ctx context.Context, m redis.XMessage, deliveries int64, handler func(ctx context.Context, msg *Message[T]) error) {
+	t, err := c.decode(m)
+	if err != nil {
+		c.deadLettering(ctx, m, err.Error())
+		return
+	}
+	if err := handler(ctx, &Message[T]{ID: m.ID, Value: t, Deliveries: deliveries}); err != nil {
+		log.Printf("handle stream %s message %s error:%s", c.stream, m.ID, err.Error())
+		return
+	}
+	if err := c.client.XAck(ctx, c.stream, c.group, m.ID).Err(); err != nil {
+		log.Printf("ack stream %s message %s error:%s", c.stream, m.ID, err.Error())
+	}
+}
+
+func (c *StreamConsumer[T]) decode(m redis.XMessage) (*T, error) {
+	data, ok := m.Values[payloadField].(string)
+	if !ok {
+		return nil, fmt.Errorf("message %s has no %s field", m.ID, payloadField)
+	}
+	t := new(T)
+	if err := c.opts.codec.Unmarshal([]byte(data), t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (c *StreamConsumer[T]) deadLettering(ctx context.Context, m redis.XMessage, reason string) {
+	values := make(map[string]interface{}, len(m.Values)+2)
+	for k, v := range m.Values {
+		values[k] = v
+	}
+	values["source_id"] = m.ID
+	values["reason"] = reason
+	// 死信stream和原stream在集群中可能位于不同的slot, 不能放在同一个事务中:
+	// 先写入死信再确认, 确认失败时消息仍在待确认列表中, 下次认领时会再次写入死信, 不会丢失
+	if err := c.client.XAdd(ctx, &redis.XAddArgs{Stream: c.deadLetter, Values: values}).Err(); err != nil {
+		log.Printf("move stream %s message %s to dead letter error:%s", c.stream, m.ID, err.Error())
+		return
+	}
+	if err := c.client.XAck(ctx, c.stream, c.group, m.ID).Err(); err != nil {
+		log.Printf("ack dead letter stream %s message %s error:%s", c.stream, m.ID, err.Error())
+	}
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// jitterBuckets SetMany 开启过期时间抖动时, 将key随机分为最多 jitterBuckets 组, 每组使用不同的过期时间写入
+const jitterBuckets = 8
+
+// IKeyExpiration 按key查询和修改过期时间, 语义与 ICommonCache 的同名方法一致, 负缓存的空值也视为存在
+type IKeyExpiration[K comparable] interface {
+	// TTL key不存在时返回 ErrNotFound, 永不过期时返回值小于0
+	TTL(ctx context.Context, k K) (time.Duration, error)
+	// Expire expiration 小于等于0时删除key
+	Expire(ctx context.Context, k K, expiration time.Duration) (bool, error)
+	// ExpireAt t 不晚于当前时间时删除key
+	ExpireAt(ctx context.Context, k K, t time.Time) (bool, error)
+	Persist(ctx context.Context, k K) (bool, error)
+	// Exists 返回keys中存在的数量
+	Exists(ctx context.Context, keys ...K) (int64, error)
+}
+
+// IExpiration 只对应一个key的缓存(单例、列表、有序集合)查询和修改过期时间, 语义与 IKeyExpiration 一致
+type IExpiration interface {
+	TTL(ctx context.Context) (time.Duration, error)
+	Expire(ctx context.Context, expiration time.Duration) (bool, error)
+	ExpireAt(ctx context.Context, t time.Time) (bool, error)
+	Persist(ctx context.Context) (bool, error)
+	Exists(ctx context.Context) (bool, error)
+}
+
+// expirationPolicy 类型化缓存写入和读取时的过期时间策略
+type expirationPolicy struct {
+	expiration time.Duration
+	jitter     time.Duration
+	sliding    bool
+}
+
+func newExpirationPolicy(expiration time.Duration, o *options) expirationPolicy {
+	return expirationPolicy{
+		expiration: expiration,
+		jitter:     o.ttlJitter,
+		sliding:    o.slidingExpiration,
+	}
+}
+
+// ttl 返回本次写入使用的过期时间, expiration 小于等于0表示永不过期, 此时不添加抖动
+func (p expirationPolicy) ttl() time.Duration {
+	if p.expiration <= 0 || p.jitter <= 0 {
+		return p.expiration
+	}
+	return p.expiration + time.Duration(rand.Int63n(int64(p.jitter)))
+}
+
+// touch 开启滑动过期时, 读取命中后重新设置过期时间, 失败不影响本次读取
+// 多个key通过 MExpire 批量设置, 开启抖动时与 SetMany 一样随机分组, 每组使用不同的过期时间
+func (p expirationPolicy) touch(ctx context.Context, client ICommonCache, keys ...string) {
+	if !p.sliding || p.expiration <= 0 || len(keys) == 0 {
+		return
+	}
+	if len(keys) == 1 {
+		_, _ = client.Expire(ctx, keys[0], p.ttl())
+		return
+	}
+	if p.jitter <= 0 {
+		_ = client.MExpire(ctx, keys, p.expiration)
+		return
+	}
+	buckets := make([][]string, jitterBuckets)
+	for _, key := range keys {
+		i := rand.Intn(jitterBuckets)
+		buckets[i] = append(buckets[i], key)
+	}
+	for _, bucket := range buckets {
+		if len(bucket) > 0 {
+			_ = client.MExpire(ctx, bucket, p.ttl())
+		}
+	}
+}
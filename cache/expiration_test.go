@@ -0,0 +1,278 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+// 滑动过期时 GetMany 命中的key通过一次批量操作续期
+func TestSlidingExpirationGetMany(t *testing.T) {
+	ctx := context.Background()
+	redisCache, _ := cachetest.NewRedisCache(t)
+	var expires, expiredKeys atomic.Int32
+	client := cache.Wrap(redisCache, func(next cache.Handler) cache.Handler {
+		return func(ctx context.Context, op *cache.Operation) error {
+			if op.Name == "pexpire" {
+				expires.Add(1)
+				expiredKeys.Add(int32(len(op.Keys)))
+			}
+			return next(ctx, op)
+		}
+	})
+	users := cache.CreateKvCacheWithOptions[user, int64](client, time.Minute, nil, cache.WithSlidingExpiration())
+
+	_ = users.SetMany(ctx, map[int64]*user{1: {Id: 1}, 2: {Id: 2}, 3: {Id: 3}})
+	for _, id := range []int64{1, 2, 3} {
+		_, _ = users.Expire(ctx, id, 10*time.Second)
+	}
+	expires.Store(0)
+	expiredKeys.Store(0)
+
+	if found, _, err := users.GetMany(ctx, 1, 2, 3, 4); err != nil || len(found) != 3 {
+		t.Fatalf("GetMany: want 3 users, got %v %v", found, err)
+	}
+	if n, keys := expires.Load(), expiredKeys.Load(); n != 1 || keys != 3 {
+		t.Errorf("want one batch expire for 3 keys, got %d calls for %d keys", n, keys)
+	}
+	for _, id := range []int64{1, 2, 3} {
+		if ttl, _ := users.TTL(ctx, id); ttl <= 50*time.Second {
+			t.Errorf("user %d: GetMany should extend the expiration, got %s", id, ttl)
+		}
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	users := cache.CreateKvCacheWithOptions[user, int64](client, time.Second, nil, cache.WithRefreshAhead(0.9))
+
+	_ = users.Set(ctx, 1, &user{Id: 1, Name: "old"})
+	time.Sleep(150 * time.Millisecond)
+	refreshed := make(chan struct{})
+	u, err := users.GetOrLoad(ctx, 1, func(ctx context.Context, id int64) (*user, error) {
+		defer close(refreshed)
+		return &user{Id: id, Name: "new"}, nil
+	})
+	if err != nil || u.Name != "old" {
+		t.Fatalf("GetOrLoad should return the cached value, got %v %v", u, err)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("value close to expiring should be refreshed in the background")
+	}
+	cachetest.Eventually(t, "refreshed value to be written back", func() bool {
+		u, _ := users.Get(ctx, 1)
+		return u != nil && u.Name == "new"
+	})
+	if ttl, _ := users.TTL(ctx, 1); ttl <= 900*time.Millisecond {
+		t.Errorf("refresh should reset the expiration, got %s", ttl)
+	}
+}
+
+// ratio 不在 (0, 1) 范围内时不开启刷新, ratio 大于等于1时每次命中都会刷新
+func TestRefreshAheadIgnoresInvalidRatio(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	for _, ratio := range []float64{0, 1, 1.5, -0.2, math.NaN()} {
+		t.Run(fmt.Sprint(ratio), func(t *testing.T) {
+			users := cache.CreateKvCacheWithOptions[user, int64](client, time.Second, nil, cache.WithRefreshAhead(ratio))
+			_ = users.Set(ctx, 1, &user{Id: 1, Name: "old"})
+			var loads atomic.Int32
+			u, err := users.GetOrLoad(ctx, 1, func(ctx context.Context, id int64) (*user, error) {
+				loads.Add(1)
+				return &user{Id: id, Name: "new"}, nil
+			})
+			if err != nil || u.Name != "old" {
+				t.Fatalf("GetOrLoad: want the cached value, got %v %v", u, err)
+			}
+			time.Sleep(50 * time.Millisecond)
+			if n := loads.Load(); n != 0 {
+				t.Errorf("ratio %v should not refresh, got %d loads", ratio, n)
+			}
+		})
+	}
+}
+
+// 只有 IKVCache.GetOrLoad 支持后台刷新, 其他缓存忽略 WithRefreshAhead
+func TestRefreshAheadIgnoredByOtherCaches(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	for name, create := range map[string]func() error{
+		"singleton": func() error {
+			return cache.CreateSingletonCache[user](client, time.Minute, "s", cache.WithRefreshAhead(0.2)).Set(ctx, &user{Id: 1})
+		},
+		"list": func() error {
+			return cache.CreateListCache[user](client, time.Minute, "l", cache.WithNativeList(), cache.WithRefreshAhead(0.2)).SetAll(ctx, []user{{Id: 1}})
+		},
+		"hash": func() error {
+			return cache.CreateHashCache[user, int64, string](client, time.Minute, nil, cache.WithRefreshAhead(0.2)).SetField(ctx, 1, "f", &user{Id: 1})
+		},
+		"sorted set": func() error {
+			return cache.CreateSortedSetCache[user](client, time.Minute, "z", cache.WithRefreshAhead(0.2)).SetScore(ctx, user{Id: 1}, 1)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := create(); err != nil {
+				t.Errorf("cache with WithRefreshAhead should work as usual, got %v", err)
+			}
+		})
+	}
+}
+
+// expirable 单个缓存项的过期操作, 用于对不同类型的缓存运行相同的检查
+type expirable struct {
+	set      func(ctx context.Context) error
+	ttl      func(ctx context.Context) (time.Duration, error)
+	expire   func(ctx context.Context, expiration time.Duration) (bool, error)
+	expireAt func(ctx context.Context, t time.Time) (bool, error)
+	persist  func(ctx context.Context) (bool, error)
+	exists   func(ctx context.Context) (bool, error)
+}
+
+func keyExpirable[K comparable](c cache.IKeyExpiration[K], k K, set func(ctx context.Context) error) expirable {
+	return expirable{
+		set:      set,
+		ttl:      func(ctx context.Context) (time.Duration, error) { return c.TTL(ctx, k) },
+		expire:   func(ctx context.Context, d time.Duration) (bool, error) { return c.Expire(ctx, k, d) },
+		expireAt: func(ctx context.Context, t time.Time) (bool, error) { return c.ExpireAt(ctx, k, t) },
+		persist:  func(ctx context.Context) (bool, error) { return c.Persist(ctx, k) },
+		exists: func(ctx context.Context) (bool, error) {
+			n, err := c.Exists(ctx, k)
+			return n > 0, err
+		},
+	}
+}
+
+func singleExpirable(c cache.IExpiration, set func(ctx context.Context) error) expirable {
+	return expirable{
+		set:      set,
+		ttl:      c.TTL,
+		expire:   c.Expire,
+		expireAt: c.ExpireAt,
+		persist:  c.Persist,
+		exists:   c.Exists,
+	}
+}
+
+func TestTypedExpiration(t *testing.T) {
+	ctx := context.Background()
+	redisCache, _ := cachetest.NewRedisCache(t)
+	memoryCache := cache_memory.NewCommonCache(cache_memory.Option{})
+	defer memoryCache.(io.Closer).Close()
+
+	for backend, client := range map[string]cache.ICommonCache{"redis": redisCache, "memory": memoryCache} {
+		kv := cache.CreateKvCache[user, int64](client, time.Minute)
+		versioned := cache.CreateKvCacheWithOptions[user, int64](client, time.Minute, nil, cache.WithGeneration("users"))
+		hash := cache.CreateHashCache[user, int64, string](client, time.Minute, func(k int64) string { return fmt.Sprint("hash:", k) })
+		counter := cache.CreateCounterCache[int64](client, time.Minute, cache.ExpireFixed, func(k int64) string { return fmt.Sprint("counter:", k) })
+		singleton := cache.CreateSingletonCache[user](client, time.Minute, "singleton")
+		list := cache.CreateListCache[user](client, time.Minute, "list")
+		nativeList := cache.CreateListCache[user](client, time.Minute, "native-list", cache.WithNativeList())
+		sortedSet := cache.CreateSortedSetCache[user](client, time.Minute, "sorted-set")
+		u := &user{Id: 1, Name: "a"}
+
+		for name, e := range map[string]expirable{
+			"kv": keyExpirable[int64](kv, 1, func(ctx context.Context) error { return kv.Set(ctx, 1, u) }),
+			"kv with generation": keyExpirable[int64](versioned, 1, func(ctx context.Context) error {
+				return versioned.Set(ctx, 1, u)
+			}),
+			"hash": keyExpirable[int64](hash, 1, func(ctx context.Context) error { return hash.SetField(ctx, 1, "a", u) }),
+			"counter": keyExpirable[int64](counter, 1, func(ctx context.Context) error {
+				_, err := counter.Incr(ctx, 1)
+				return err
+			}),
+			"singleton":   singleExpirable(singleton, func(ctx context.Context) error { return singleton.Set(ctx, u) }),
+			"list":        singleExpirable(list, func(ctx context.Context) error { return list.SetAll(ctx, []user{*u}) }),
+			"native list": singleExpirable(nativeList, func(ctx context.Context) error { return nativeList.Append(ctx, *u) }),
+			"sorted set":  singleExpirable(sortedSet, func(ctx context.Context) error { return sortedSet.SetScore(ctx, *u, 1) }),
+		} {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				checkExpiration(t, ctx, e)
+			})
+		}
+	}
+}
+
+func checkExpiration(t *testing.T, ctx context.Context, e expirable) {
+	t.Helper()
+	if ok, err := e.exists(ctx); err != nil || ok {
+		t.Fatalf("Exists before set: want false, got %v %v", ok, err)
+	}
+	if _, err := e.ttl(ctx); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("TTL of a missing key: want ErrNotFound, got %v", err)
+	}
+	if ok, err := e.expire(ctx, time.Minute); err != nil || ok {
+		t.Errorf("Expire of a missing key: want false, got %v %v", ok, err)
+	}
+	if ok, err := e.persist(ctx); err != nil || ok {
+		t.Errorf("Persist of a missing key: want false, got %v %v", ok, err)
+	}
+
+	if err := e.set(ctx); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if ok, err := e.exists(ctx); err != nil || !ok {
+		t.Fatalf("Exists after set: want true, got %v %v", ok, err)
+	}
+	if ttl, err := e.ttl(ctx); err != nil || ttl <= 50*time.Second || ttl > time.Minute {
+		t.Errorf("TTL after set: want about 1m, got %s %v", ttl, err)
+	}
+	if ok, err := e.expire(ctx, 10*time.Second); err != nil || !ok {
+		t.Errorf("Expire: want true, got %v %v", ok, err)
+	}
+	if ttl, _ := e.ttl(ctx); ttl <= 9*time.Second || ttl > 10*time.Second {
+		t.Errorf("TTL after Expire: want about 10s, got %s", ttl)
+	}
+	if ok, err := e.expireAt(ctx, time.Now().Add(20*time.Second)); err != nil || !ok {
+		t.Errorf("ExpireAt: want true, got %v %v", ok, err)
+	}
+	if ttl, _ := e.ttl(ctx); ttl <= 19*time.Second || ttl > 20*time.Second {
+		t.Errorf("TTL after ExpireAt: want about 20s, got %s", ttl)
+	}
+	if ok, err := e.persist(ctx); err != nil || !ok {
+		t.Errorf("Persist: want true, got %v %v", ok, err)
+	}
+	if ttl, err := e.ttl(ctx); err != nil || ttl >= 0 {
+		t.Errorf("TTL after Persist: want negative, got %s %v", ttl, err)
+	}
+	if ok, _ := e.persist(ctx); ok {
+		t.Error("Persist without expiration: want false")
+	}
+	if ok, err := e.expire(ctx, 20*time.Millisecond); err != nil || !ok {
+		t.Errorf("Expire: want true, got %v %v", ok, err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if ok, err := e.exists(ctx); err != nil || ok {
+		t.Errorf("Exists after expiring: want false, got %v %v", ok, err)
+	}
+}
+
+func TestKeyExpirationExistsMany(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	users := cache.CreateKvCache[user, int64](client, time.Minute)
+	counter := cache.CreateCounterCache[int64](client, time.Minute, cache.ExpireFixed, func(k int64) string { return fmt.Sprint("counter:", k) })
+
+	_ = users.SetMany(ctx, map[int64]*user{1: {Id: 1}, 2: {Id: 2}})
+	_, _ = counter.Incr(ctx, 3)
+	if n, err := users.Exists(ctx, 1, 2, 3); err != nil || n != 2 {
+		t.Errorf("kv Exists: want 2, got %d %v", n, err)
+	}
+	if n, err := counter.Exists(ctx, 1, 3); err != nil || n != 1 {
+		t.Errorf("counter Exists: want 1, got %d %v", n, err)
+	}
+	if n, err := users.Exists(ctx); err != nil || n != 0 {
+		t.Errorf("Exists without keys: want 0, got %d %v", n, err)
+	}
+}
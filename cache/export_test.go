@@ -0,0 +1,4 @@
+package cache
+
+// AddTagScript 供 cache_test 在进程内RESP服务中模拟脚本
+var AddTagScript = addTagScript
@@ -0,0 +1,35 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"*", "a/b:c", true},
+		{"user:*", "user:1", true},
+		{"user:*", "order:1", false},
+		{"**:1", "user:1", true},
+		{"user:?", "user:1", true},
+		{"user:?", "user:10", false},
+		{"user:[12]", "user:2", true},
+		{"user:[^12]", "user:2", false},
+		{"user:[^12]", "user:3", true},
+		{"user:[a-c]", "user:b", true},
+		{"user:[c-a]", "user:b", true},
+		{"user:[a-c]", "user:d", false},
+		{"user:[\\]]", "user:]", true},
+		{"app\\[1\\]:*", "app[1]:x", true},
+		{"app\\[1\\]:*", "app1:x", false},
+		{"app[1", "app[1", true},
+		{"a\\*", "a*", true},
+		{"a\\*", "ab", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Package scripts cache_redis 使用的Lua脚本源码, cachetest 通过相同的源码注册脚本的Go实现
+package scripts
+
+const (
+	CompareAndDelete = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+	// IncrByEx 增加与设置过期时间在同一个脚本中完成, 非滑动模式只在key没有过期时间(新建)时设置
+	IncrByEx = `
+local v = redis.call("INCRBY", KEYS[1], ARGV[1])
+local expiration = tonumber(ARGV[2])
+if expiration > 0 and (ARGV[3] == "1" or redis.call("PTTL", KEYS[1]) < 0) then
+	redis.call("PEXPIRE", KEYS[1], expiration)
+end
+return v`
+
+	CompareAndExpire = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+)
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 代数和标签集合使用 __ 开头的前缀, 避免与业务key冲突
+const (
+	generationKeyPrefix = "__gen:"
+	tagKeyPrefix        = "__tag:"
+)
+
+func generationKey(group string) string {
+	return fmt.Sprint(generationKeyPrefix, group)
+}
+
+func tagKey(tag string) string {
+	return fmt.Sprint(tagKeyPrefix, tag)
+}
+
+// generation 读取分组当前的代数, 从未递增过的分组为0
+func generation(ctx context.Context, client ICommonCache, group string) (int64, error) {
+	gen, err := client.GetInt(ctx, generationKey(group))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return gen, nil
+}
+
+// BumpGeneration 将分组的代数加一并返回新的代数, 使用 WithGeneration(group) 创建的缓存中已写入的值全部失效,
+// 旧值不会被删除, 过期后由缓存自行清理
+func BumpGeneration(ctx context.Context, client ICommonCache, group string) (int64, error) {
+	return client.IncrByEx(ctx, generationKey(group), 1, 0, false)
+}
+
+// addTagScript 将key加入标签集合, 标签集合的过期时间只会延长, 有永不过期的key加入时标签也不再过期
+var addTagScript = NewScript(`
+local existed = redis.call("EXISTS", KEYS[1])
+redis.call("HSET", KEYS[1], ARGV[1], "1")
+local expiration = tonumber(ARGV[2])
+if expiration <= 0 then
+	redis.call("PERSIST", KEYS[1])
+	return 1
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if existed == 0 or (ttl >= 0 and ttl < expiration) then
+	redis.call("PEXPIRE", KEYS[1], expiration)
+end
+return 1`)
+
+// tagLock 不支持Lua脚本的实现(进程内缓存)通过进程内的锁保证读取和延长过期时间的原子性
+var tagLock sync.Mutex
+
+// addTags 将key加入标签集合, 标签集合的过期时间会延长到不短于新加入的key, 避免key仍存在而标签已过期
+func addTags(ctx context.Context, client ICommonCache, key string, expiration time.Duration, tags []string) error {
+	for _, tag := range tags {
+		_, err := client.Eval(ctx, addTagScript, []string{tagKey(tag)}, key, expiration.Milliseconds())
+		if errors.Is(err, ErrNotSupported) {
+			err = addTagLocal(ctx, client, tagKey(tag), key, expiration)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addTagLocal(ctx context.Context, client ICommonCache, tk, key string, expiration time.Duration) error {
+	tagLock.Lock()
+	defer tagLock.Unlock()
+
+	existed, err := client.Exists(ctx, tk)
+	if err != nil {
+		return err
+	}
+	if err := client.HSet(ctx, tk, key, []byte("1"), 0); err != nil {
+		return err
+	}
+	if expiration <= 0 {
+		_, err := client.Persist(ctx, tk)
+		return err
+	}
+	ttl, err := client.TTL(ctx, tk)
+	if err != nil {
+		return err
+	}
+	if existed == 0 || (ttl >= 0 && ttl < expiration) {
+		_, err = client.Expire(ctx, tk, expiration)
+	}
+	return err
+}
+
+// InvalidateTags 删除所有带有任一标签的key, 删除期间新加入标签的key不受影响
+func InvalidateTags(ctx context.Context, client ICommonCache, tags ...string) error {
+	for _, tag := range tags {
+		tk := tagKey(tag)
+		members, err := client.HGetAllBytes(ctx, tk)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return err
+		}
+		if len(members) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(members))
+		for key := range members {
+			keys = append(keys, key)
+		}
+		if err := client.Del(ctx, keys...); err != nil {
+			return err
+		}
+		if err := client.HDel(ctx, tk, keys...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
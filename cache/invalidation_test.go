@@ -0,0 +1,146 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+	"github.com/mengri/utils-store/cache/cache_redis"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+// handleAddTag 在进程内RESP服务中模拟 addTagScript
+func handleAddTag(s *cachetest.Server) {
+	s.HandleScript(cache.AddTagScript.Source(), func(call func(args ...string) (any, error), keys []string, args []string) (any, error) {
+		existed, err := call("EXISTS", keys[0])
+		if err != nil {
+			return nil, err
+		}
+		if _, err := call("HSET", keys[0], args[0], "1"); err != nil {
+			return nil, err
+		}
+		expiration, _ := strconv.ParseInt(args[1], 10, 64)
+		if expiration <= 0 {
+			_, err := call("PERSIST", keys[0])
+			return int64(1), err
+		}
+		ttl, err := call("PTTL", keys[0])
+		if err != nil {
+			return nil, err
+		}
+		if existed.(int64) == 0 || (ttl.(int64) >= 0 && ttl.(int64) < expiration) {
+			_, err = call("PEXPIRE", keys[0], args[1])
+		}
+		return int64(1), err
+	})
+}
+
+// tagBackends Redis通过脚本、进程内缓存通过本地锁维护标签, 两者需要有相同的行为
+// 设置了 cachetest.RedisAddrEnv 时同时在真实的Redis上执行 addTagScript
+func tagBackends(t *testing.T) map[string]cache.ICommonCache {
+	t.Helper()
+	redisCache, s := cachetest.NewRedisCache(t)
+	handleAddTag(s)
+	memoryCache := cache_memory.NewCommonCache(cache_memory.Option{})
+	t.Cleanup(func() {
+		_ = memoryCache.(io.Closer).Close()
+	})
+	backends := map[string]cache.ICommonCache{"redis": redisCache, "memory": memoryCache}
+	if client, ok := cachetest.ConnectRedis(t); ok {
+		c := cache_redis.NewCommonCache(client, fmt.Sprint("invalidation-test:", time.Now().UnixNano()))
+		t.Cleanup(func() {
+			_, _ = c.DeleteByPattern(context.Background(), "*")
+		})
+		backends["redis-server"] = c
+	}
+	return backends
+}
+
+func TestGeneration(t *testing.T) {
+	ctx := context.Background()
+	client, _ := cachetest.NewRedisCache(t)
+	users := cache.CreateKvCacheWithOptions[user, int64](client, time.Minute, nil, cache.WithGeneration("users"))
+
+	_ = users.Set(ctx, 1, &user{Id: 1})
+	if gen, err := cache.BumpGeneration(ctx, client, "users"); err != nil || gen != 1 {
+		t.Fatalf("BumpGeneration: want 1, got %d %v", gen, err)
+	}
+	if _, err := users.Get(ctx, 1); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("values written before the bump should be invalid, got %v", err)
+	}
+	if gen, err := client.GetInt(ctx, "__gen:users"); err != nil || gen != 1 {
+		t.Errorf("generation should be stored under __gen:, got %d %v", gen, err)
+	}
+	_ = users.Set(ctx, 1, &user{Id: 1})
+	if u, err := users.Get(ctx, 1); err != nil || u.Id != 1 {
+		t.Errorf("values written after the bump should be readable, got %v %v", u, err)
+	}
+}
+
+func TestInvalidateTags(t *testing.T) {
+	ctx := context.Background()
+	for name, client := range tagBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			users := cache.CreateKvCache[user, int64](client, time.Minute)
+			config := cache.CreateSingletonCache[user](client, time.Minute, "config")
+
+			_ = users.SetWithTags(ctx, 1, &user{Id: 1}, "tenant:1")
+			_ = users.SetWithTags(ctx, 2, &user{Id: 2}, "tenant:2")
+			_ = config.SetWithTags(ctx, &user{}, "tenant:1", "global")
+			if n, _ := client.Exists(ctx, "__tag:tenant:1", "__tag:global"); n != 2 {
+				t.Fatalf("tag sets should be stored under __tag:, got %d", n)
+			}
+
+			if err := cache.InvalidateTags(ctx, client, "tenant:1", "unknown"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := users.Get(ctx, 1); !errors.Is(err, cache.ErrNotFound) {
+				t.Errorf("tagged user should be deleted, got %v", err)
+			}
+			if _, err := config.Get(ctx); !errors.Is(err, cache.ErrNotFound) {
+				t.Errorf("tagged singleton should be deleted, got %v", err)
+			}
+			if _, err := users.Get(ctx, 2); err != nil {
+				t.Errorf("user with another tag should be kept, got %v", err)
+			}
+		})
+	}
+}
+
+// 标签集合的过期时间只会延长, 并发写入不同过期时间的key时取最大值
+func TestTagExpirationOnlyExtends(t *testing.T) {
+	ctx := context.Background()
+	for name, client := range tagBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			long := cache.CreateKvCache[user, int64](client, 2*time.Minute)
+			short := cache.CreateKvCache[user, int64](client, 10*time.Second)
+
+			_ = long.SetWithTags(ctx, 1, &user{Id: 1}, "t")
+			_ = short.SetWithTags(ctx, 2, &user{Id: 2}, "t")
+			if ttl, _ := client.TTL(ctx, "__tag:t"); ttl <= time.Minute {
+				t.Errorf("a shorter key should not shorten the tag, got %s", ttl)
+			}
+
+			var wg sync.WaitGroup
+			for i := 1; i <= 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					users := cache.CreateKvCache[user, int64](client, time.Duration(i)*time.Minute)
+					_ = users.SetWithTags(ctx, int64(i), &user{Id: int64(i)}, "c")
+				}(i)
+			}
+			wg.Wait()
+			if ttl, _ := client.TTL(ctx, "__tag:c"); ttl <= 9*time.Minute {
+				t.Errorf("tag should live as long as its longest key, got %s", ttl)
+			}
+		})
+	}
+}
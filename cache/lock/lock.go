@@ -0,0 +1,226 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mengri/utils/autowire-v2"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+const (
+	retryInterval = time.Millisecond * 100
+)
+
+var (
+	_ ILocker = (*imlLocker)(nil)
+
+	ErrNotObtained = errors.New("lock: not obtained")
+	ErrNotHeld     = errors.New("lock: not held")
+	// ErrInvalidTTL 锁必须有过期时间, 否则持有者崩溃后锁永远不会释放
+	ErrInvalidTTL = errors.New("lock: ttl must be positive")
+)
+
+type ILocker interface {
+	// Lock 获取锁, 获取失败时重试直到ctx结束, ttl 小于等于0时返回 ErrInvalidTTL
+	Lock(ctx context.Context, name string, ttl time.Duration) (ILock, error)
+	// TryLock 尝试获取一次锁, 锁已被占用时返回 ErrNotObtained
+	TryLock(ctx context.Context, name string, ttl time.Duration) (ILock, error)
+	// WithLock 持有锁执行fn, 锁丢失时取消传给fn的ctx
+	WithLock(ctx context.Context, name string, ttl time.Duration, fn func(ctx context.Context) error) error
+}
+
+// ILock 已获取的锁, 持有期间每 ttl/3 自动续期, 直到 Unlock 或续期失败
+// 续期失败且租约剩余时间不足 ttl/3 时认为锁已丢失, 在租约到期、其他进程可能获取锁之前关闭 Lost
+type ILock interface {
+	Name() string
+	Token() string
+	// Lost 锁被释放或续期失败时关闭
+	Lost() <-chan struct{}
+	Extend(ctx context.Context, ttl time.Duration) error
+	Unlock(ctx context.Context) error
+}
+
+type imlLocker struct {
+	client cache.ICommonCache `autowired:""`
+}
+
+func NewLocker(client cache.ICommonCache) ILocker {
+	return &imlLocker{client: client}
+}
+
+func (l *imlLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (ILock, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+	token := uuid.NewString()
+	ok, err := l.client.SetNX(ctx, lockKey(name), token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotObtained
+	}
+	return newLock(l.client, name, token, ttl), nil
+}
+
+func (l *imlLocker) Lock(ctx context.Context, name string, ttl time.Duration) (ILock, error) {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	for {
+		lk, err := l.TryLock(ctx, name, ttl)
+		if !errors.Is(err, ErrNotObtained) {
+			return lk, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *imlLocker) WithLock(ctx context.Context, name string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	lk, err := l.Lock(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+	defer lk.Unlock(context.WithoutCancel(ctx))
+
+	fnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lk.Lost():
+			cancel()
+		case <-fnCtx.Done():
+		}
+	}()
+	return fn(fnCtx)
+}
+
+type imlLock struct {
+	client cache.ICommonCache
+	name   string
+	token  string
+
+	lost     chan struct{}
+	lostOnce sync.Once
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newLock(client cache.ICommonCache, name, token string, ttl time.Duration) *imlLock {
+	lk := &imlLock{
+		client: client,
+		name:   name,
+		token:  token,
+		lost:   make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	go lk.renew(ttl)
+	return lk
+}
+
+func (l *imlLock) Name() string {
+	return l.name
+}
+
+func (l *imlLock) Token() string {
+	return l.token
+}
+
+func (l *imlLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *imlLock) Extend(ctx context.Context, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	ok, err := l.client.CompareAndExpire(ctx, lockKey(l.name), []byte(l.token), ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		l.markLost()
+		return ErrNotHeld
+	}
+	return nil
+}
+
+func (l *imlLock) Unlock(ctx context.Context) error {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+	defer l.markLost()
+	ok, err := l.client.CompareAndDelete(ctx, lockKey(l.name), []byte(l.token))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+func (l *imlLock) renew(ttl time.Duration) {
+	interval := ttl / 3
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// deadline 之后租约剩余时间不足 interval, 仍未续期成功就认为锁已丢失
+	deadline := time.Now().Add(ttl - interval)
+	timer := time.NewTimer(ttl - interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-timer.C:
+			l.markLost()
+			return
+		case <-ticker.C:
+		}
+		start := time.Now()
+		// 续期请求不能超过 deadline, 否则请求阻塞期间锁可能已经被其他进程获取
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		err := l.Extend(ctx, ttl)
+		cancel()
+		if err == nil {
+			deadline = start.Add(ttl - interval)
+			timer.Reset(time.Until(deadline))
+			continue
+		}
+		if errors.Is(err, ErrNotHeld) {
+			return
+		}
+		if !time.Now().Before(deadline) {
+			l.markLost()
+			return
+		}
+	}
+}
+
+func (l *imlLock) markLost() {
+	l.lostOnce.Do(func() {
+		close(l.lost)
+	})
+}
+
+func lockKey(name string) string {
+	return fmt.Sprint("lock:", name)
+}
+
+func init() {
+	autowire.Auto[ILocker](func() ILocker {
+		return new(imlLocker)
+	})
+}
@@ -0,0 +1,195 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+func TestLockBackends(t *testing.T) {
+	redisCache, _ := cachetest.NewRedisCache(t)
+	memoryCache := cache_memory.NewCommonCache(cache_memory.Option{})
+	defer memoryCache.(io.Closer).Close()
+
+	for name, client := range map[string]cache.ICommonCache{"redis": redisCache, "memory": memoryCache} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			locker := NewLocker(client)
+
+			lk, err := locker.TryLock(ctx, "job", time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := locker.TryLock(ctx, "job", time.Second); !errors.Is(err, ErrNotObtained) {
+				t.Errorf("second TryLock: want ErrNotObtained, got %v", err)
+			}
+			if err := lk.Unlock(ctx); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-lk.Lost():
+			default:
+				t.Error("Lost should be closed after Unlock")
+			}
+			if err := lk.Unlock(ctx); !errors.Is(err, ErrNotHeld) {
+				t.Errorf("second Unlock: want ErrNotHeld, got %v", err)
+			}
+
+			lk2, err := locker.TryLock(ctx, "job", time.Second)
+			if err != nil {
+				t.Fatalf("TryLock after Unlock: %v", err)
+			}
+			defer lk2.Unlock(ctx)
+			// 旧的持有者不能释放新持有者的锁
+			if err := lk.Unlock(ctx); !errors.Is(err, ErrNotHeld) {
+				t.Errorf("stale Unlock: want ErrNotHeld, got %v", err)
+			}
+			if _, err := locker.TryLock(ctx, "job", time.Second); !errors.Is(err, ErrNotObtained) {
+				t.Errorf("lock should still be held by the new holder, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLockRejectsNonPositiveTTL(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	locker := NewLocker(client)
+	ctx := context.Background()
+
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		if _, err := locker.TryLock(ctx, "job", ttl); !errors.Is(err, ErrInvalidTTL) {
+			t.Errorf("TryLock(%s): want ErrInvalidTTL, got %v", ttl, err)
+		}
+		if _, err := locker.Lock(ctx, "job", ttl); !errors.Is(err, ErrInvalidTTL) {
+			t.Errorf("Lock(%s): want ErrInvalidTTL, got %v", ttl, err)
+		}
+	}
+	lk, err := locker.TryLock(ctx, "job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lk.Unlock(ctx)
+	if err := lk.Extend(ctx, 0); !errors.Is(err, ErrInvalidTTL) {
+		t.Errorf("Extend(0): want ErrInvalidTTL, got %v", err)
+	}
+	if n, _ := client.Exists(ctx, lockKey("job")); n != 1 {
+		t.Error("Extend(0) must not release the lock")
+	}
+}
+
+func TestLockWaitsForRelease(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	locker := NewLocker(client)
+	ctx := context.Background()
+
+	lk, err := locker.TryLock(ctx, "job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(150*time.Millisecond, func() {
+		_ = lk.Unlock(ctx)
+	})
+	start := time.Now()
+	lk2, err := locker.Lock(ctx, "job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lk2.Unlock(ctx)
+	if time.Since(start) < 150*time.Millisecond {
+		t.Error("Lock returned before the holder released it")
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := locker.Lock(waitCtx, "job", time.Second); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Lock on held lock: want DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestLockRenewal(t *testing.T) {
+	client, _ := cachetest.NewRedisCache(t)
+	locker := NewLocker(client)
+	ctx := context.Background()
+
+	ttl := 150 * time.Millisecond
+	lk, err := locker.TryLock(ctx, "job", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lk.Unlock(ctx)
+
+	time.Sleep(3 * ttl)
+	select {
+	case <-lk.Lost():
+		t.Fatal("lock should be renewed while held")
+	default:
+	}
+	if _, err := locker.TryLock(ctx, "job", ttl); !errors.Is(err, ErrNotObtained) {
+		t.Errorf("renewed lock should still be held, got %v", err)
+	}
+}
+
+func TestLockLostWhenTakenOver(t *testing.T) {
+	client, s := cachetest.NewRedisCache(t)
+	locker := NewLocker(client)
+	ctx := context.Background()
+
+	lk, err := locker.TryLock(ctx, "job", 150*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FlushAll()
+	select {
+	case <-lk.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("Lost should be closed when the lock is gone")
+	}
+}
+
+// 续期请求失败时必须在租约到期前通知持有者, 而不是等到租约已经到期
+func TestLockLostBeforeLeaseEnds(t *testing.T) {
+	client, s := cachetest.NewRedisCache(t)
+	locker := NewLocker(client)
+	ctx := context.Background()
+
+	ttl := 300 * time.Millisecond
+	acquired := time.Now()
+	lk, err := locker.TryLock(ctx, "job", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+
+	select {
+	case <-lk.Lost():
+		if elapsed := time.Since(acquired); elapsed >= ttl {
+			t.Errorf("lost reported after the lease ended: %s", elapsed)
+		}
+	case <-time.After(2 * ttl):
+		t.Fatal("Lost should be closed when renewal keeps failing")
+	}
+}
+
+func TestWithLockCancelsOnLost(t *testing.T) {
+	client, s := cachetest.NewRedisCache(t)
+	locker := NewLocker(client)
+
+	err := locker.WithLock(context.Background(), "job", 150*time.Millisecond, func(ctx context.Context) error {
+		s.FlushAll()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return errors.New("ctx was not cancelled")
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets 延迟直方图默认的分桶上限, 单位为秒
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+var _ IMetrics = (*Collector)(nil)
+
+type OpStats struct {
+	Calls  int64 `json:"calls"`
+	Errors int64 `json:"errors"`
+	Bytes  int64 `json:"bytes"`
+	// LatencySum 累计耗时(秒)
+	LatencySum float64 `json:"latency_sum"`
+	// Buckets 与 Collector 的分桶上限一一对应, 为耗时小于等于该上限的累计次数
+	Buckets []int64 `json:"buckets"`
+}
+
+type CacheStats struct {
+	Hits   int64               `json:"hits"`
+	Misses int64               `json:"misses"`
+	Ops    map[string]*OpStats `json:"ops"`
+}
+
+// HitRatio 命中率, 没有读取时返回0
+func (s *CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Collector 在进程内汇总指标, 可通过 PublishExpvar 或 WritePrometheus 导出
+type Collector struct {
+	lock    sync.Mutex
+	buckets []float64
+	caches  map[string]*CacheStats
+}
+
+// NewCollector buckets 为空时使用 DefaultBuckets
+func NewCollector(buckets ...float64) *Collector {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	bs := append([]float64(nil), buckets...)
+	sort.Float64s(bs)
+	return &Collector{
+		buckets: bs,
+		caches:  make(map[string]*CacheStats),
+	}
+}
+
+func (c *Collector) Hit(name string, n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cache(name).Hits += int64(n)
+}
+
+func (c *Collector) Miss(name string, n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cache(name).Misses += int64(n)
+}
+
+func (c *Collector) Error(name, op string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.op(name, op).Errors++
+}
+
+func (c *Collector) Bytes(name, op string, n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.op(name, op).Bytes += int64(n)
+}
+
+func (c *Collector) Latency(name, op string, d time.Duration) {
+	seconds := d.Seconds()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s := c.op(name, op)
+	s.Calls++
+	s.LatencySum += seconds
+	for i, b := range c.buckets {
+		if seconds <= b {
+			s.Buckets[i]++
+		}
+	}
+}
+
+// Snapshot 返回当前指标的副本
+func (c *Collector) Snapshot() map[string]*CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	rs := make(map[string]*CacheStats, len(c.caches))
+	for name, s := range c.caches {
+		cs := &CacheStats{
+			Hits:   s.Hits,
+			Misses: s.Misses,
+			Ops:    make(map[string]*OpStats, len(s.Ops)),
+		}
+		for op, o := range s.Ops {
+			v := *o
+			v.Buckets = append([]int64(nil), o.Buckets...)
+			cs.Ops[op] = &v
+		}
+		rs[name] = cs
+	}
+	return rs
+}
+
+// Reset 清空所有指标
+func (c *Collector) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.caches = make(map[string]*CacheStats)
+}
+
+func (c *Collector) cache(name string) *CacheStats {
+	s, has := c.caches[name]
+	if !has {
+		s = &CacheStats{Ops: make(map[string]*OpStats)}
+		c.caches[name] = s
+	}
+	return s
+}
+
+func (c *Collector) op(name, op string) *OpStats {
+	s := c.cache(name)
+	o, has := s.Ops[op]
+	if !has {
+		o = &OpStats{Buckets: make([]int64, len(c.buckets))}
+		s.Ops[op] = o
+	}
+	return o
+}
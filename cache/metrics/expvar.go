@@ -0,0 +1,11 @@
+package metrics
+
+import "expvar"
+
+// PublishExpvar 将 collector 的指标以 name 发布到 expvar, 可通过 /debug/vars 查看
+// 与 expvar.Publish 一样, 重复发布同一个 name 会panic
+func PublishExpvar(name string, c *Collector) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return c.Snapshot()
+	}))
+}
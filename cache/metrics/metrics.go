@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+// IMetrics 缓存指标的记录接口, name 为缓存名称, op 为操作名称(get/set/mget等)
+type IMetrics interface {
+	Hit(name string, n int)
+	Miss(name string, n int)
+	Error(name, op string)
+	Bytes(name, op string, n int)
+	Latency(name, op string, d time.Duration)
+}
+
+// Middleware 返回记录指标的中间件, 所有操作都以 name 作为缓存名称记录
+// 记录每个操作的耗时、错误和读写字节数, 读取缓存值的操作(Operation.Lookup)同时记录命中和未命中的key数量
+// 命中率按底层key的读取统计, GetOrLoad 回源过程中的读取也会计入; WrapKV 等类型化包装按调用统计命中率,
+// 同时使用时必须使用不同的 name, 否则同一次读取会在同一个名称下计算两次
+func Middleware(name string, m IMetrics) cache.Middleware {
+	return func(next cache.Handler) cache.Handler {
+		return func(ctx context.Context, op *cache.Operation) error {
+			start := time.Now()
+			err := next(ctx, op)
+			observe(m, name, op.Name, start, err)
+			if n := op.Written + op.Read; n > 0 {
+				m.Bytes(name, op.Name, n)
+			}
+			if op.Lookup && (err == nil || errors.Is(err, cache.ErrNotFound)) {
+				if op.Hits > 0 {
+					m.Hit(name, op.Hits)
+				}
+				if misses := len(op.Keys) - op.Hits; misses > 0 {
+					m.Miss(name, misses)
+				}
+			}
+			return err
+		}
+	}
+}
+
+// Wrap 返回记录指标的 ICommonCache, 等同于 cache.Wrap(client, Middleware(name, m))
+func Wrap(name string, client cache.ICommonCache, m IMetrics) cache.ICommonCache {
+	return cache.Wrap(client, Middleware(name, m))
+}
+
+// observe 记录一次操作的耗时, ErrNotFound 和 ErrNotSupported 不计为错误
+func observe(m IMetrics, name, op string, start time.Time, err error) {
+	m.Latency(name, op, time.Since(start))
+	if err != nil && !errors.Is(err, cache.ErrNotFound) && !errors.Is(err, cache.ErrNotSupported) {
+		m.Error(name, op)
+	}
+}
+
+// lookup 记录一次单key读取的命中情况
+func lookup(m IMetrics, name string, err error) {
+	switch {
+	case err == nil:
+		m.Hit(name, 1)
+	case errors.Is(err, cache.ErrNotFound):
+		m.Miss(name, 1)
+	}
+}
@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+type user struct {
+	Id   int64
+	Name string
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	collector := NewCollector()
+	redisCache, _ := cachetest.NewRedisCache(t)
+	client := Wrap("redis", redisCache, collector)
+
+	_ = client.Set(ctx, "a", []byte("hello"), time.Minute)
+	_, _ = client.Get(ctx, "a")
+	_, _ = client.Get(ctx, "missing")
+	_, _ = client.MGet(ctx, "a", "b", "c")
+	_, _ = client.HGetAll(ctx, "no-hash")
+	_ = client.Incr(ctx, "a", 0)
+
+	stats := collector.Snapshot()["redis"]
+	if stats == nil {
+		t.Fatal("no stats recorded")
+	}
+	if stats.Hits != 2 || stats.Misses != 4 {
+		t.Errorf("want 2 hits and 4 misses, got %d %d", stats.Hits, stats.Misses)
+	}
+	if set := stats.Ops["set"]; set == nil || set.Calls != 1 || set.Bytes != 5 {
+		t.Errorf("set: want 1 call writing 5 bytes, got %+v", set)
+	}
+	if get := stats.Ops["get"]; get == nil || get.Calls != 2 || get.Bytes != 5 || get.Errors != 0 {
+		t.Errorf("get: want 2 calls reading 5 bytes without errors, got %+v", get)
+	}
+	if incr := stats.Ops["incr"]; incr == nil || incr.Errors != 1 {
+		t.Errorf("incr on a non-integer value should be recorded as an error, got %+v", incr)
+	}
+}
+
+// 并发未命中时只有一个调用执行loader, 其余等待加载结果的调用同样计为未命中
+func TestWrapKVGetOrLoadCountsWaitersAsMisses(t *testing.T) {
+	ctx := context.Background()
+	collector := NewCollector()
+	redisCache, _ := cachetest.NewRedisCache(t)
+	users := WrapKV("users", cache.CreateKvCache[user, int64](redisCache, time.Minute), collector)
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = users.GetOrLoad(ctx, 1, func(ctx context.Context, id int64) (*user, error) {
+				<-release
+				return &user{Id: id}, nil
+			})
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if _, err := users.GetOrLoad(ctx, 1, func(ctx context.Context, id int64) (*user, error) {
+		return nil, errors.New("should be cached")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := collector.Snapshot()["users"]
+	if stats.Hits != 1 || stats.Misses != 5 {
+		t.Errorf("want 1 hit and 5 misses, got %d %d", stats.Hits, stats.Misses)
+	}
+	if load := stats.Ops["load"]; load == nil || load.Calls != 1 {
+		t.Errorf("loader should run once, got %+v", load)
+	}
+}
+
+// loader 中嵌套的 GetOrLoad 只记录到内层缓存
+func TestWrapKVGetOrLoadNested(t *testing.T) {
+	ctx := context.Background()
+	collector := NewCollector()
+	client, _ := cachetest.NewRedisCache(t)
+	inner := WrapKV("inner", cache.CreateKvCache[user, int64](client, time.Minute, func(id int64) string { return "inner" }), collector)
+	outer := WrapKV("outer", cache.CreateKvCache[user, int64](client, time.Minute), collector)
+
+	_, err := outer.GetOrLoad(ctx, 1, func(ctx context.Context, id int64) (*user, error) {
+		return inner.GetOrLoad(ctx, id, func(ctx context.Context, id int64) (*user, error) {
+			return &user{Id: id}, nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := collector.Snapshot()
+	if stats["outer"].Hits != 0 || stats["outer"].Misses != 1 || stats["inner"].Misses != 1 {
+		t.Errorf("want one miss on each cache, got outer %+v inner %+v", stats["outer"], stats["inner"])
+	}
+}
+
+// 后台刷新在 GetOrLoad 返回后调用loader, 与并发的读取之间不能有数据竞争(go test -race)
+func TestWrapKVWithRefreshAhead(t *testing.T) {
+	ctx := context.Background()
+	collector := NewCollector()
+	redisCache, _ := cachetest.NewRedisCache(t)
+	base := cache.CreateKvCacheWithOptions[user, int64](redisCache, time.Second, nil, cache.WithRefreshAhead(0.95))
+	users := WrapKV("users", base, collector)
+
+	var loads atomic.Int32
+	loader := func(ctx context.Context, id int64) (*user, error) {
+		loads.Add(1)
+		return &user{Id: id}, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := users.GetOrLoad(ctx, 1, loader); err != nil {
+					t.Error(err)
+					return
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+	cachetest.Eventually(t, "background refresh", func() bool {
+		return loads.Load() >= 2
+	})
+	stats := collector.Snapshot()["users"]
+	if stats.Hits+stats.Misses != 200 || stats.Hits == 0 {
+		t.Errorf("every GetOrLoad should be counted once, got %d hits and %d misses", stats.Hits, stats.Misses)
+	}
+}
+
+// 类型化包装和底层中间件使用不同的名称时, 每次读取在各自的名称下只计算一次
+func TestWrapKVOnWrappedClient(t *testing.T) {
+	ctx := context.Background()
+	collector := NewCollector()
+	redisCache, _ := cachetest.NewRedisCache(t)
+	client := Wrap("redis", redisCache, collector)
+	users := WrapKV("users", cache.CreateKvCache[user, int64](client, time.Minute), collector)
+
+	_ = users.Set(ctx, 1, &user{Id: 1})
+	if _, err := users.Get(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = users.Get(ctx, 2)
+	stats := collector.Snapshot()
+	for _, name := range []string{"redis", "users"} {
+		if s := stats[name]; s == nil || s.Hits != 1 || s.Misses != 1 {
+			t.Errorf("%s: want 1 hit and 1 miss, got %+v", name, s)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WritePrometheus 以 Prometheus 文本格式输出指标, 指标名以 cache_ 开头
+func WritePrometheus(w io.Writer, c *Collector) error {
+	snapshot := c.Snapshot()
+	names := make([]string, 0, len(snapshot))
+	for name := range snapshot {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# HELP cache_hits_total Number of cache hits.")
+	fmt.Fprintln(bw, "# TYPE cache_hits_total counter")
+	for _, name := range names {
+		fmt.Fprintf(bw, "cache_hits_total{cache=%s} %d\n", quote(name), snapshot[name].Hits)
+	}
+	fmt.Fprintln(bw, "# HELP cache_misses_total Number of cache misses.")
+	fmt.Fprintln(bw, "# TYPE cache_misses_total counter")
+	for _, name := range names {
+		fmt.Fprintf(bw, "cache_misses_total{cache=%s} %d\n", quote(name), snapshot[name].Misses)
+	}
+
+	type series struct {
+		labels string
+		stats  *OpStats
+	}
+	ops := make([]series, 0)
+	for _, name := range names {
+		opNames := make([]string, 0, len(snapshot[name].Ops))
+		for op := range snapshot[name].Ops {
+			opNames = append(opNames, op)
+		}
+		sort.Strings(opNames)
+		for _, op := range opNames {
+			ops = append(ops, series{
+				labels: fmt.Sprintf("cache=%s,op=%s", quote(name), quote(op)),
+				stats:  snapshot[name].Ops[op],
+			})
+		}
+	}
+
+	fmt.Fprintln(bw, "# HELP cache_errors_total Number of failed cache operations.")
+	fmt.Fprintln(bw, "# TYPE cache_errors_total counter")
+	for _, s := range ops {
+		fmt.Fprintf(bw, "cache_errors_total{%s} %d\n", s.labels, s.stats.Errors)
+	}
+	fmt.Fprintln(bw, "# HELP cache_bytes_total Number of bytes read from or written to the cache.")
+	fmt.Fprintln(bw, "# TYPE cache_bytes_total counter")
+	for _, s := range ops {
+		fmt.Fprintf(bw, "cache_bytes_total{%s} %d\n", s.labels, s.stats.Bytes)
+	}
+	fmt.Fprintln(bw, "# HELP cache_operation_duration_seconds Latency of cache operations.")
+	fmt.Fprintln(bw, "# TYPE cache_operation_duration_seconds histogram")
+	for _, s := range ops {
+		for i, b := range c.buckets {
+			fmt.Fprintf(bw, "cache_operation_duration_seconds_bucket{%s,le=\"%s\"} %d\n", s.labels, formatFloat(b), s.stats.Buckets[i])
+		}
+		fmt.Fprintf(bw, "cache_operation_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", s.labels, s.stats.Calls)
+		fmt.Fprintf(bw, "cache_operation_duration_seconds_sum{%s} %s\n", s.labels, formatFloat(s.stats.LatencySum))
+		fmt.Fprintf(bw, "cache_operation_duration_seconds_count{%s} %d\n", s.labels, s.stats.Calls)
+	}
+	return bw.Flush()
+}
+
+// PrometheusHandler 返回输出 Prometheus 文本格式指标的 http.Handler
+func PrometheusHandler(c *Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", prometheusContentType)
+		_ = WritePrometheus(w, c)
+	})
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+type kvCache[T any, K comparable] struct {
+	cache.IKVCache[T, K]
+	name    string
+	metrics IMetrics
+}
+
+// WrapKV 返回记录指标的 IKVCache, 只记录命中率、错误和耗时, 字节数需要通过 Wrap 包装底层 ICommonCache 记录
+// 底层 ICommonCache 同时通过 Wrap 或 Middleware 记录指标时, name 不能与其相同, 否则命中率会重复计算
+func WrapKV[T any, K comparable](name string, c cache.IKVCache[T, K], m IMetrics) cache.IKVCache[T, K] {
+	return &kvCache[T, K]{
+		IKVCache: c,
+		name:     name,
+		metrics:  m,
+	}
+}
+
+func (c *kvCache[T, K]) Get(ctx context.Context, k K) (*T, error) {
+	start := time.Now()
+	t, err := c.IKVCache.Get(ctx, k)
+	observe(c.metrics, c.name, "get", start, err)
+	lookup(c.metrics, c.name, err)
+	return t, err
+}
+
+func (c *kvCache[T, K]) Set(ctx context.Context, k K, t *T) error {
+	start := time.Now()
+	err := c.IKVCache.Set(ctx, k, t)
+	observe(c.metrics, c.name, "set", start, err)
+	return err
+}
+
+func (c *kvCache[T, K]) SetWithTags(ctx context.Context, k K, t *T, tags ...string) error {
+	start := time.Now()
+	err := c.IKVCache.SetWithTags(ctx, k, t, tags...)
+	observe(c.metrics, c.name, "set", start, err)
+	return err
+}
+
+func (c *kvCache[T, K]) Delete(ctx context.Context, keys ...K) error {
+	start := time.Now()
+	err := c.IKVCache.Delete(ctx, keys...)
+	observe(c.metrics, c.name, "del", start, err)
+	return err
+}
+
+func (c *kvCache[T, K]) GetMany(ctx context.Context, keys ...K) (map[K]*T, []K, error) {
+	start := time.Now()
+	values, missing, err := c.IKVCache.GetMany(ctx, keys...)
+	observe(c.metrics, c.name, "mget", start, err)
+	if err == nil {
+		if len(values) > 0 {
+			c.metrics.Hit(c.name, len(values))
+		}
+		if len(missing) > 0 {
+			c.metrics.Miss(c.name, len(missing))
+		}
+	}
+	return values, missing, err
+}
+
+func (c *kvCache[T, K]) SetMany(ctx context.Context, values map[K]*T) error {
+	start := time.Now()
+	err := c.IKVCache.SetMany(ctx, values)
+	observe(c.metrics, c.name, "mset", start, err)
+	return err
+}
+
+// GetOrLoad 从缓存读取计为命中, 需要回源计为未命中(包括等待同一个key的并发加载), 命中情况由 cache.WithLookupObserver 获取
+// loader 可能在 GetOrLoad 返回后由后台刷新调用, 只记录加载耗时
+func (c *kvCache[T, K]) GetOrLoad(ctx context.Context, k K, loader cache.Loader[T, K]) (*T, error) {
+	start := time.Now()
+	ctx = cache.WithLookupObserver(ctx, func(hit bool) {
+		if hit {
+			c.metrics.Hit(c.name, 1)
+		} else {
+			c.metrics.Miss(c.name, 1)
+		}
+	})
+	t, err := c.IKVCache.GetOrLoad(ctx, k, func(ctx context.Context, k K) (*T, error) {
+		loadStart := time.Now()
+		t, err := loader(ctx, k)
+		observe(c.metrics, c.name, "load", loadStart, err)
+		return t, err
+	})
+	observe(c.metrics, c.name, "get_or_load", start, err)
+	return t, err
+}
+
+type listCache[T any] struct {
+	cache.IListCache[T]
+	name    string
+	metrics IMetrics
+}
+
+// WrapList 返回记录指标的 IListCache
+func WrapList[T any](name string, c cache.IListCache[T], m IMetrics) cache.IListCache[T] {
+	return &listCache[T]{
+		IListCache: c,
+		name:       name,
+		metrics:    m,
+	}
+}
+
+func (c *listCache[T]) SetAll(ctx context.Context, t []T) error {
+	start := time.Now()
+	err := c.IListCache.SetAll(ctx, t)
+	observe(c.metrics, c.name, "set", start, err)
+	return err
+}
+
+func (c *listCache[T]) GetAll(ctx context.Context) ([]T, error) {
+	start := time.Now()
+	ts, err := c.IListCache.GetAll(ctx)
+	observe(c.metrics, c.name, "get", start, err)
+	lookup(c.metrics, c.name, err)
+	return ts, err
+}
+
+func (c *listCache[T]) Delete(ctx context.Context) error {
+	start := time.Now()
+	err := c.IListCache.Delete(ctx)
+	observe(c.metrics, c.name, "del", start, err)
+	return err
+}
+
+func (c *listCache[T]) Append(ctx context.Context, t ...T) error {
+	start := time.Now()
+	err := c.IListCache.Append(ctx, t...)
+	observe(c.metrics, c.name, "append", start, err)
+	return err
+}
+
+func (c *listCache[T]) Prepend(ctx context.Context, t ...T) error {
+	start := time.Now()
+	err := c.IListCache.Prepend(ctx, t...)
+	observe(c.metrics, c.name, "prepend", start, err)
+	return err
+}
+
+func (c *listCache[T]) Range(ctx context.Context, offset, limit int64) ([]T, error) {
+	start := time.Now()
+	ts, err := c.IListCache.Range(ctx, offset, limit)
+	observe(c.metrics, c.name, "range", start, err)
+	lookup(c.metrics, c.name, err)
+	return ts, err
+}
+
+func (c *listCache[T]) Len(ctx context.Context) (int64, error) {
+	start := time.Now()
+	n, err := c.IListCache.Len(ctx)
+	observe(c.metrics, c.name, "len", start, err)
+	return n, err
+}
+
+type singletonCache[T any] struct {
+	cache.ISingletonCache[T]
+	name    string
+	metrics IMetrics
+}
+
+// WrapSingleton 返回记录指标的 ISingletonCache
+func WrapSingleton[T any](name string, c cache.ISingletonCache[T], m IMetrics) cache.ISingletonCache[T] {
+	return &singletonCache[T]{
+		ISingletonCache: c,
+		name:            name,
+		metrics:         m,
+	}
+}
+
+func (c *singletonCache[T]) Get(ctx context.Context) (*T, error) {
+	start := time.Now()
+	t, err := c.ISingletonCache.Get(ctx)
+	observe(c.metrics, c.name, "get", start, err)
+	lookup(c.metrics, c.name, err)
+	return t, err
+}
+
+func (c *singletonCache[T]) Set(ctx context.Context, t *T) error {
+	start := time.Now()
+	err := c.ISingletonCache.Set(ctx, t)
+	observe(c.metrics, c.name, "set", start, err)
+	return err
+}
+
+func (c *singletonCache[T]) SetWithTags(ctx context.Context, t *T, tags ...string) error {
+	start := time.Now()
+	err := c.ISingletonCache.SetWithTags(ctx, t, tags...)
+	observe(c.metrics, c.name, "set", start, err)
+	return err
+}
+
+func (c *singletonCache[T]) Delete(ctx context.Context) error {
+	start := time.Now()
+	err := c.ISingletonCache.Delete(ctx)
+	observe(c.metrics, c.name, "del", start, err)
+	return err
+}
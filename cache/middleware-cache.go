@@ -0,0 +1,481 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	_ ICommonCache = (*middlewareCache)(nil)
+	_ IWrapper     = (*middlewareCache)(nil)
+)
+
+type middlewareCache struct {
+	client      ICommonCache
+	middlewares []Middleware
+}
+
+// Wrap 为 client 的每次操作依次应用中间件, mw[0] 在最外层
+// 返回值可以直接传给 CreateKvCache 等函数, 使所有类型化缓存共享同样的横切逻辑
+func Wrap(client ICommonCache, mw ...Middleware) ICommonCache {
+	if len(mw) == 0 {
+		return client
+	}
+	return &middlewareCache{
+		client:      client,
+		middlewares: mw,
+	}
+}
+
+func (c *middlewareCache) Unwrap() ICommonCache {
+	return c.client
+}
+
+func (c *middlewareCache) Clone() ICommonCache {
+	return Wrap(c.client.Clone(), c.middlewares...)
+}
+
+func (c *middlewareCache) do(ctx context.Context, op *Operation, call func(ctx context.Context) error) error {
+	h := func(ctx context.Context, op *Operation) error {
+		return call(ctx)
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+	return h(ctx, op)
+}
+
+func (c *middlewareCache) Get(ctx context.Context, key string) ([]byte, error) {
+	var v []byte
+	op := &Operation{Name: "get", Keys: []string{key}, Idempotent: true, Lookup: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		v, err = c.client.Get(ctx, key)
+		op.Read = len(v)
+		op.Hits = hits(err == nil)
+		return err
+	})
+	return v, err
+}
+
+func (c *middlewareCache) GetInt(ctx context.Context, key string) (int64, error) {
+	var v int64
+	op := &Operation{Name: "get", Keys: []string{key}, Idempotent: true, Lookup: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		v, err = c.client.GetInt(ctx, key)
+		op.Hits = hits(err == nil)
+		return err
+	})
+	return v, err
+}
+
+func (c *middlewareCache) Del(ctx context.Context, keys ...string) error {
+	op := &Operation{Name: "del", Keys: keys, Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.Del(ctx, keys...)
+	})
+}
+
+func (c *middlewareCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	op := &Operation{Name: "set", Keys: []string{key}, Written: len(val), Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.Set(ctx, key, val, expiration)
+	})
+}
+
+func (c *middlewareCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	var ttl time.Duration
+	op := &Operation{Name: "pttl", Keys: []string{key}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		ttl, err = c.client.TTL(ctx, key)
+		return err
+	})
+	return ttl, err
+}
+
+func (c *middlewareCache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	var ok bool
+	op := &Operation{Name: "pexpire", Keys: []string{key}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		ok, err = c.client.Expire(ctx, key, expiration)
+		return err
+	})
+	return ok, err
+}
+
+func (c *middlewareCache) MExpire(ctx context.Context, keys []string, expiration time.Duration) error {
+	op := &Operation{Name: "pexpire", Keys: keys, Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.MExpire(ctx, keys, expiration)
+	})
+}
+
+func (c *middlewareCache) ExpireAt(ctx context.Context, key string, t time.Time) (bool, error) {
+	var ok bool
+	op := &Operation{Name: "pexpireat", Keys: []string{key}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		ok, err = c.client.ExpireAt(ctx, key, t)
+		return err
+	})
+	return ok, err
+}
+
+func (c *middlewareCache) Persist(ctx context.Context, key string) (bool, error) {
+	var ok bool
+	op := &Operation{Name: "persist", Keys: []string{key}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		ok, err = c.client.Persist(ctx, key)
+		return err
+	})
+	return ok, err
+}
+
+func (c *middlewareCache) Exists(ctx context.Context, keys ...string) (int64, error) {
+	var n int64
+	op := &Operation{Name: "exists", Keys: keys, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		n, err = c.client.Exists(ctx, keys...)
+		return err
+	})
+	return n, err
+}
+
+func (c *middlewareCache) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	var vs [][]byte
+	op := &Operation{Name: "mget", Keys: keys, Idempotent: true, Lookup: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		vs, err = c.client.MGet(ctx, keys...)
+		op.Read = sizeOf(vs)
+		op.Hits = 0
+		for _, v := range vs {
+			op.Hits += hits(v != nil)
+		}
+		return err
+	})
+	return vs, err
+}
+
+func (c *middlewareCache) MSet(ctx context.Context, values map[string][]byte, expiration time.Duration) error {
+	keys, size := mapKeys(values)
+	op := &Operation{Name: "mset", Keys: keys, Written: size, Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.MSet(ctx, values, expiration)
+	})
+}
+
+func (c *middlewareCache) HMSet(ctx context.Context, key string, value map[string][]byte, expiration time.Duration) error {
+	_, size := mapKeys(value)
+	op := &Operation{Name: "hmset", Keys: []string{key}, Written: size, Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.HMSet(ctx, key, value, expiration)
+	})
+}
+
+func (c *middlewareCache) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	var vs map[string]string
+	op := &Operation{Name: "hgetall", Keys: []string{key}, Idempotent: true, Lookup: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		vs, err = c.client.HGetAll(ctx, key)
+		op.Read = 0
+		for _, v := range vs {
+			op.Read += len(v)
+		}
+		op.Hits = hits(len(vs) > 0)
+		return err
+	})
+	return vs, err
+}
+
+func (c *middlewareCache) HGetAllBytes(ctx context.Context, key string) (map[string][]byte, error) {
+	var vs map[string][]byte
+	op := &Operation{Name: "hgetall", Keys: []string{key}, Idempotent: true, Lookup: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		vs, err = c.client.HGetAllBytes(ctx, key)
+		_, op.Read = mapKeys(vs)
+		op.Hits = hits(len(vs) > 0)
+		return err
+	})
+	return vs, err
+}
+
+func (c *middlewareCache) HGet(ctx context.Context, key string, field string) ([]byte, error) {
+	var v []byte
+	op := &Operation{Name: "hget", Keys: []string{key}, Idempotent: true, Lookup: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		v, err = c.client.HGet(ctx, key, field)
+		op.Read = len(v)
+		op.Hits = hits(err == nil)
+		return err
+	})
+	return v, err
+}
+
+func (c *middlewareCache) HSet(ctx context.Context, key string, field string, val []byte, expiration time.Duration) error {
+	op := &Operation{Name: "hset", Keys: []string{key}, Written: len(val), Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.HSet(ctx, key, field, val, expiration)
+	})
+}
+
+func (c *middlewareCache) HDel(ctx context.Context, key string, fields ...string) error {
+	op := &Operation{Name: "hdel", Keys: []string{key}, Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.HDel(ctx, key, fields...)
+	})
+}
+
+func (c *middlewareCache) RPush(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) (int64, error) {
+	var n int64
+	op := &Operation{Name: "rpush", Keys: []string{key}, Written: sizeOf(vals)}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		n, err = c.client.RPush(ctx, key, expiration, vals...)
+		return err
+	})
+	return n, err
+}
+
+func (c *middlewareCache) LPush(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) (int64, error) {
+	var n int64
+	op := &Operation{Name: "lpush", Keys: []string{key}, Written: sizeOf(vals)}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		n, err = c.client.LPush(ctx, key, expiration, vals...)
+		return err
+	})
+	return n, err
+}
+
+func (c *middlewareCache) RPushTrim(ctx context.Context, key string, maxLen int64, expiration time.Duration, vals ...[]byte) (int64, error) {
+	var n int64
+	op := &Operation{Name: "rpushtrim", Keys: []string{key}, Written: sizeOf(vals)}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		n, err = c.client.RPushTrim(ctx, key, maxLen, expiration, vals...)
+		return err
+	})
+	return n, err
+}
+
+func (c *middlewareCache) LPushTrim(ctx context.Context, key string, maxLen int64, expiration time.Duration, vals ...[]byte) (int64, error) {
+	var n int64
+	op := &Operation{Name: "lpushtrim", Keys: []string{key}, Written: sizeOf(vals)}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		n, err = c.client.LPushTrim(ctx, key, maxLen, expiration, vals...)
+		return err
+	})
+	return n, err
+}
+
+func (c *middlewareCache) LReplace(ctx context.Context, key string, expiration time.Duration, vals ...[]byte) error {
+	op := &Operation{Name: "lreplace", Keys: []string{key}, Written: sizeOf(vals), Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.LReplace(ctx, key, expiration, vals...)
+	})
+}
+
+func (c *middlewareCache) LRange(ctx context.Context, key string, start, stop int64) ([][]byte, error) {
+	var vs [][]byte
+	op := &Operation{Name: "lrange", Keys: []string{key}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		vs, err = c.client.LRange(ctx, key, start, stop)
+		op.Read = sizeOf(vs)
+		return err
+	})
+	return vs, err
+}
+
+func (c *middlewareCache) LTrim(ctx context.Context, key string, start, stop int64) error {
+	op := &Operation{Name: "ltrim", Keys: []string{key}, Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.LTrim(ctx, key, start, stop)
+	})
+}
+
+func (c *middlewareCache) LLen(ctx context.Context, key string) (int64, error) {
+	var n int64
+	op := &Operation{Name: "llen", Keys: []string{key}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		n, err = c.client.LLen(ctx, key)
+		return err
+	})
+	return n, err
+}
+
+func (c *middlewareCache) ZAdd(ctx context.Context, key string, member []byte, score float64, expiration time.Duration) error {
+	op := &Operation{Name: "zadd", Keys: []string{key}, Written: len(member), Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.ZAdd(ctx, key, member, score, expiration)
+	})
+}
+
+func (c *middlewareCache) ZIncrBy(ctx context.Context, key string, member []byte, incr float64, expiration time.Duration) (float64, error) {
+	var v float64
+	op := &Operation{Name: "zincrby", Keys: []string{key}, Written: len(member)}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		v, err = c.client.ZIncrBy(ctx, key, member, incr, expiration)
+		return err
+	})
+	return v, err
+}
+
+func (c *middlewareCache) ZScore(ctx context.Context, key string, member []byte) (float64, error) {
+	var v float64
+	op := &Operation{Name: "zscore", Keys: []string{key}, Idempotent: true, Lookup: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		v, err = c.client.ZScore(ctx, key, member)
+		op.Hits = hits(err == nil)
+		return err
+	})
+	return v, err
+}
+
+func (c *middlewareCache) ZRank(ctx context.Context, key string, member []byte, reverse bool) (int64, error) {
+	var v int64
+	op := &Operation{Name: "zrank", Keys: []string{key}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		v, err = c.client.ZRank(ctx, key, member, reverse)
+		return err
+	})
+	return v, err
+}
+
+func (c *middlewareCache) ZRange(ctx context.Context, key string, start, stop int64, reverse bool) ([]ZMember, error) {
+	var vs []ZMember
+	op := &Operation{Name: "zrange", Keys: []string{key}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		vs, err = c.client.ZRange(ctx, key, start, stop, reverse)
+		op.Read = zmemberSize(vs)
+		return err
+	})
+	return vs, err
+}
+
+func (c *middlewareCache) ZRangeByScore(ctx context.Context, key string, min, max float64, offset, count int64) ([]ZMember, error) {
+	var vs []ZMember
+	op := &Operation{Name: "zrangebyscore", Keys: []string{key}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		vs, err = c.client.ZRangeByScore(ctx, key, min, max, offset, count)
+		op.Read = zmemberSize(vs)
+		return err
+	})
+	return vs, err
+}
+
+func (c *middlewareCache) ZRem(ctx context.Context, key string, members ...[]byte) error {
+	op := &Operation{Name: "zrem", Keys: []string{key}, Idempotent: true}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.ZRem(ctx, key, members...)
+	})
+}
+
+func (c *middlewareCache) Incr(ctx context.Context, key string, expiration time.Duration) error {
+	op := &Operation{Name: "incr", Keys: []string{key}}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.Incr(ctx, key, expiration)
+	})
+}
+
+func (c *middlewareCache) IncrBy(ctx context.Context, key string, val int64, expiration time.Duration) error {
+	op := &Operation{Name: "incrby", Keys: []string{key}}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.IncrBy(ctx, key, val, expiration)
+	})
+}
+
+func (c *middlewareCache) IncrByEx(ctx context.Context, key string, val int64, expiration time.Duration, sliding bool) (int64, error) {
+	var v int64
+	op := &Operation{Name: "incrby", Keys: []string{key}}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		v, err = c.client.IncrByEx(ctx, key, val, expiration, sliding)
+		return err
+	})
+	return v, err
+}
+
+func (c *middlewareCache) SetNX(ctx context.Context, key string, val interface{}, expiration time.Duration) (bool, error) {
+	var ok bool
+	op := &Operation{Name: "setnx", Keys: []string{key}}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		ok, err = c.client.SetNX(ctx, key, val, expiration)
+		return err
+	})
+	return ok, err
+}
+
+func (c *middlewareCache) CompareAndDelete(ctx context.Context, key string, val []byte) (bool, error) {
+	var ok bool
+	op := &Operation{Name: "compare_and_delete", Keys: []string{key}}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		ok, err = c.client.CompareAndDelete(ctx, key, val)
+		return err
+	})
+	return ok, err
+}
+
+func (c *middlewareCache) CompareAndExpire(ctx context.Context, key string, val []byte, expiration time.Duration) (bool, error) {
+	var ok bool
+	op := &Operation{Name: "compare_and_expire", Keys: []string{key}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		ok, err = c.client.CompareAndExpire(ctx, key, val, expiration)
+		return err
+	})
+	return ok, err
+}
+
+// Scan 会多次调用fn, 因此不会被 Retry 重试
+func (c *middlewareCache) Scan(ctx context.Context, pattern string, fn func(key string) error) error {
+	op := &Operation{Name: "scan", Keys: []string{pattern}}
+	return c.do(ctx, op, func(ctx context.Context) error {
+		return c.client.Scan(ctx, pattern, fn)
+	})
+}
+
+func (c *middlewareCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	var n int64
+	op := &Operation{Name: "delete_by_pattern", Keys: []string{pattern}, Idempotent: true}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		n, err = c.client.DeleteByPattern(ctx, pattern)
+		return err
+	})
+	return n, err
+}
+
+func (c *middlewareCache) Eval(ctx context.Context, script *Script, keys []string, args ...interface{}) (interface{}, error) {
+	var v interface{}
+	op := &Operation{Name: "eval", Keys: keys}
+	err := c.do(ctx, op, func(ctx context.Context) (err error) {
+		v, err = c.client.Eval(ctx, script, keys, args...)
+		return err
+	})
+	return v, err
+}
+
+func hits(hit bool) int {
+	if hit {
+		return 1
+	}
+	return 0
+}
+
+func sizeOf(vs [][]byte) int {
+	size := 0
+	for _, v := range vs {
+		size += len(v)
+	}
+	return size
+}
+
+func zmemberSize(vs []ZMember) int {
+	size := 0
+	for _, v := range vs {
+		size += len(v.Member)
+	}
+	return size
+}
+
+func mapKeys(values map[string][]byte) ([]string, int) {
+	keys := make([]string, 0, len(values))
+	size := 0
+	for k, v := range values {
+		keys = append(keys, k)
+		size += len(v)
+	}
+	return keys, size
+}
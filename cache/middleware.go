@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Operation 一次 ICommonCache 调用的描述, 由 Wrap 创建并传递给中间件
+type Operation struct {
+	// Name 操作名称, 与Redis命令名一致(小写), 如 get/set/mget/hset/eval
+	Name string
+	Keys []string
+	// Written 写入的字节数, 调用前设置
+	Written int
+	// Read 读取的字节数, 调用完成后设置
+	Read int
+	// Lookup 读取缓存值的操作(get/mget/hget/hgetall/zscore), 调用完成后设置 Hits
+	Lookup bool
+	// Hits Lookup 操作命中的key数量, 未命中的数量为 len(Keys)-Hits
+	Hits int
+	// Idempotent 重复执行不会改变结果, Retry 只重试幂等的操作
+	Idempotent bool
+}
+
+// Handler 执行操作, 中间件可以在调用 next 前后添加逻辑, 也可以多次调用或不调用 next
+type Handler func(ctx context.Context, op *Operation) error
+
+type Middleware func(next Handler) Handler
+
+// Logging 记录失败(ErrNotFound 除外)以及耗时超过 slow 的操作, slow 小于等于0时只记录失败, logf 为nil时使用 log.Printf
+func Logging(slow time.Duration, logf func(format string, v ...any)) Middleware {
+	if logf == nil {
+		logf = log.Printf
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			start := time.Now()
+			err := next(ctx, op)
+			d := time.Since(start)
+			switch {
+			case err != nil && !errors.Is(err, ErrNotFound):
+				logf("cache %s %v error:%s duration:%s", op.Name, op.Keys, err.Error(), d)
+			case slow > 0 && d >= slow:
+				logf("cache %s %v slow duration:%s written:%d read:%d", op.Name, op.Keys, d, op.Written, op.Read)
+			}
+			return err
+		}
+	}
+}
+
+// Timeout 每次操作最多执行 timeout, ctx 已有更早的截止时间时以ctx为准
+func Timeout(timeout time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next(ctx, op)
+		}
+	}
+}
+
+// Retry 幂等操作失败后按指数退避重试, 最多执行 attempts 次, 每次等待时间翻倍且不超过 maxBackoff(小于等于0时不限制)
+// ErrNotFound、ErrNotSupported 和ctx结束不会重试
+func Retry(attempts int, backoff, maxBackoff time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			err := next(ctx, op)
+			if !op.Idempotent {
+				return err
+			}
+			wait := backoff
+			for i := 1; i < attempts && retryable(ctx, err); i++ {
+				// 加入最多50%的随机抖动, 避免多个调用同时重试
+				d := wait + time.Duration(rand.Int63n(int64(wait)/2+1))
+				timer := time.NewTimer(d)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return err
+				case <-timer.C:
+				}
+				err = next(ctx, op)
+				wait *= 2
+				if maxBackoff > 0 && wait > maxBackoff {
+					wait = maxBackoff
+				}
+			}
+			return err
+		}
+	}
+}
+
+func retryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	return !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrNotSupported) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// SpanStarter 开始一个追踪span, 返回的ctx会传递给后续调用, end 在操作结束时调用
+type SpanStarter func(ctx context.Context, op *Operation) (spanCtx context.Context, end func(err error))
+
+// Tracing 为每次操作创建追踪span, 通过 SpanStarter 对接 OpenTelemetry 等追踪系统
+func Tracing(start SpanStarter) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			spanCtx, end := start(ctx, op)
+			err := next(spanCtx, op)
+			end(err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"time"
+)
+
+// Option 创建缓存时的可选配置
+type Option func(o *options)
+
+type options struct {
+	codec             Codec
+	compress          bool
+	compressThreshold int
+	compression       Compression
+
+	negativeExpiration time.Duration
+
+	nativeList bool
+	maxLen     int64
+
+	ttlJitter         time.Duration
+	slidingExpiration bool
+	refreshAhead      float64
+
+	generation string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		codec: JSONCodec,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.compress {
+		o.codec = CompressCodec(o.codec, o.compressThreshold, o.compression)
+	}
+	return o
+}
+
+// WithCodec 指定缓存值的编解码方式, 默认为 JSONCodec
+func WithCodec(codec Codec) Option {
+	return func(o *options) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithCompression 编码结果超过 threshold 字节时压缩后再写入缓存, threshold 小于等于0时使用默认值1KB
+func WithCompression(threshold int, algorithm ...Compression) Option {
+	return func(o *options) {
+		o.compress = true
+		o.compressThreshold = threshold
+		o.compression = CompressFlate
+		if len(algorithm) > 0 {
+			o.compression = algorithm[0]
+		}
+	}
+}
+
+// WithNegativeCache loader 报告数据不存在时写入一个过期时间为 expiration 的空标记,
+// 过期前对该key的读取直接返回 ErrNotFound, 不再回源
+func WithNegativeCache(expiration time.Duration) Option {
+	return func(o *options) {
+		o.negativeExpiration = expiration
+	}
+}
+
+// WithNativeList 列表缓存使用Redis列表保存, 每个元素单独编码, Append/Prepend 不需要读取整个列表
+func WithNativeList() Option {
+	return func(o *options) {
+		o.nativeList = true
+	}
+}
+
+// WithMaxLen 列表缓存的最大长度, SetAll 和 Append 时保留末尾的元素, Prepend 时保留开头的元素
+func WithMaxLen(maxLen int64) Option {
+	return func(o *options) {
+		o.maxLen = maxLen
+	}
+}
+
+// WithTTLJitter 每次写入时在过期时间上增加 [0, jitter) 的随机值, 避免同时写入的key同时过期
+func WithTTLJitter(jitter time.Duration) Option {
+	return func(o *options) {
+		o.ttlJitter = jitter
+	}
+}
+
+// WithSlidingExpiration 读取命中时重新设置过期时间, 经常被读取的key不会过期
+func WithSlidingExpiration() Option {
+	return func(o *options) {
+		o.slidingExpiration = true
+	}
+}
+
+// WithRefreshAhead GetOrLoad 命中的值剩余过期时间不超过 expiration*ratio 时, 在后台调用loader刷新缓存,
+// 本次读取直接返回旧值, ratio 不在 (0, 1) 范围内时不开启刷新
+// 只有 CreateKvCacheWithOptions 创建的缓存支持, 其他缓存忽略该选项
+func WithRefreshAhead(ratio float64) Option {
+	return func(o *options) {
+		if ratio > 0 && ratio < 1 {
+			o.refreshAhead = ratio
+		} else {
+			o.refreshAhead = 0
+		}
+	}
+}
+
+// WithGeneration 将分组 group 当前的代数加入缓存key, 调用 BumpGeneration(group) 后分组内已写入的值全部失效,
+// 多个缓存可以使用同一个分组, 每次读写都需要额外读取一次代数
+func WithGeneration(group string) Option {
+	return func(o *options) {
+		o.generation = group
+	}
+}
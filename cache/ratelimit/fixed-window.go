@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+var fixedWindowScript = cache.NewScript(`
+local n = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+if n > limit then
+	return {0, current, 0}
+end
+if current + n > limit then
+	return {0, current, redis.call("PTTL", KEYS[1])}
+end
+current = redis.call("INCRBY", KEYS[1], n)
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], window)
+	ttl = window
+end
+return {1, current, ttl}`)
+
+var _ ILimiter = (*fixedWindow)(nil)
+
+// fixedWindow 固定窗口限流, 窗口从第一次请求开始计时, 窗口内的请求不会延长窗口
+type fixedWindow struct {
+	client cache.ICommonCache
+	name   string
+	limit  int64
+	window time.Duration
+	lock   sync.Mutex
+}
+
+type fixedWindowState struct {
+	Count int64 `json:"count"`
+	Reset int64 `json:"reset"`
+}
+
+// NewFixedWindow limit 小于等于0或 window 小于1毫秒时返回 ErrInvalidLimit
+func NewFixedWindow(client cache.ICommonCache, name string, limit int64, window time.Duration) (ILimiter, error) {
+	if err := validWindow(limit, window); err != nil {
+		return nil, err
+	}
+	return &fixedWindow{
+		client: client,
+		name:   name,
+		limit:  limit,
+		window: window,
+	}, nil
+}
+
+func (l *fixedWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *fixedWindow) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	redisKey := limitKey(l.name, key)
+	values, err := evalInts(ctx, l.client, fixedWindowScript, []string{redisKey}, n, l.window.Milliseconds(), l.limit)
+	if errors.Is(err, cache.ErrNotSupported) {
+		return l.allowLocal(ctx, redisKey, n)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.result(values[0] == 1, values[1], time.Duration(values[2])*time.Millisecond), nil
+}
+
+func (l *fixedWindow) allowLocal(ctx context.Context, redisKey string, n int64) (*Result, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	state, err := loadState[fixedWindowState](ctx, l.client, redisKey)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil || state.Reset <= now.UnixMilli() {
+		state = &fixedWindowState{Reset: now.Add(l.window).UnixMilli()}
+	}
+	ttl := time.UnixMilli(state.Reset).Sub(now)
+	if n > l.limit {
+		return l.result(false, state.Count, 0), nil
+	}
+	if state.Count+n > l.limit {
+		return l.result(false, state.Count, ttl), nil
+	}
+	state.Count += n
+	if err := saveState(ctx, l.client, redisKey, state, ttl); err != nil {
+		return nil, err
+	}
+	return l.result(true, state.Count, ttl), nil
+}
+
+func (l *fixedWindow) result(allowed bool, count int64, ttl time.Duration) *Result {
+	rs := &Result{
+		Allowed:   allowed,
+		Limit:     l.limit,
+		Remaining: max(l.limit-count, 0),
+	}
+	if !allowed {
+		rs.RetryAfter = max(ttl, 0)
+	}
+	return rs
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+// Result 限流结果, Allowed 为false时 RetryAfter 为预计可以重试的等待时间
+// 一次请求的数量超过 Limit 时永远不会被允许, 此时 RetryAfter 为0
+type Result struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	RetryAfter time.Duration
+}
+
+// ILimiter 限流器, 在Redis上通过Lua脚本保证原子性,
+// 不支持脚本的实现(如进程内缓存)会在进程内加锁后读写缓存, 只保证单进程内的原子性
+// AllowN 的 n 超过 Limit 时返回 Allowed 为false、RetryAfter 为0 的结果, 调用方需要拆分请求而不是等待重试
+type ILimiter interface {
+	Allow(ctx context.Context, key string) (*Result, error)
+	AllowN(ctx context.Context, key string, n int64) (*Result, error)
+}
+
+// ErrInvalidLimit 固定窗口和滑动窗口的请求上限必须大于0, 窗口长度不能小于1毫秒
+var ErrInvalidLimit = errors.New("ratelimit: limit must be positive and window at least 1ms")
+
+func validWindow(limit int64, window time.Duration) error {
+	if limit <= 0 || window < time.Millisecond {
+		return ErrInvalidLimit
+	}
+	return nil
+}
+
+func limitKey(name, key string) string {
+	return fmt.Sprint("ratelimit:", name, ":", key)
+}
+
+// evalInts 执行脚本并将结果转换为整数数组, 当前实现不支持脚本时返回 cache.ErrNotSupported
+func evalInts(ctx context.Context, client cache.ICommonCache, script *cache.Script, keys []string, args ...interface{}) ([]int64, error) {
+	v, err := client.Eval(ctx, script, keys, args...)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("ratelimit: unexpected script result %T", v)
+	}
+	rs := make([]int64, 0, len(values))
+	for _, value := range values {
+		n, ok := value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("ratelimit: unexpected script result %T", value)
+		}
+		rs = append(rs, n)
+	}
+	return rs, nil
+}
+
+func loadState[T any](ctx context.Context, client cache.ICommonCache, key string) (*T, error) {
+	data, err := client.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	t := new(T)
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func saveState[T any](ctx context.Context, client cache.ICommonCache, key string, t *T, expiration time.Duration) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return client.Set(ctx, key, data, expiration)
+}
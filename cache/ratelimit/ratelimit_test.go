@@ -0,0 +1,295 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/cache/cache_memory"
+	"github.com/mengri/utils-store/cache/cache_redis"
+	"github.com/mengri/utils-store/cache/cachetest"
+)
+
+type callFunc = func(args ...string) (any, error)
+
+// 以下为限流脚本的Go实现, 通过脚本源码注册, 脚本修改后哈希不匹配会直接报错
+// 脚本本身在设置了 cachetest.RedisAddrEnv 时由 backends 中的真实Redis执行
+func registerScripts(s *cachetest.Server) {
+	s.HandleScript(fixedWindowScript.Source(), func(call callFunc, keys []string, args []string) (any, error) {
+		n, _ := strconv.ParseInt(args[0], 10, 64)
+		limit, _ := strconv.ParseInt(args[2], 10, 64)
+		v, err := call("GET", keys[0])
+		if err != nil {
+			return nil, err
+		}
+		current := int64(0)
+		if v != nil {
+			current, _ = strconv.ParseInt(v.(string), 10, 64)
+		}
+		if n > limit {
+			return []any{int64(0), current, int64(0)}, nil
+		}
+		if current+n > limit {
+			ttl, err := call("PTTL", keys[0])
+			return []any{int64(0), current, ttl}, err
+		}
+		if v, err = call("INCRBY", keys[0], args[0]); err != nil {
+			return nil, err
+		}
+		current = v.(int64)
+		ttl, err := call("PTTL", keys[0])
+		if err != nil {
+			return nil, err
+		}
+		if ttl.(int64) < 0 {
+			if _, err := call("PEXPIRE", keys[0], args[1]); err != nil {
+				return nil, err
+			}
+			ttl, _ = strconv.ParseInt(args[1], 10, 64)
+		}
+		return []any{int64(1), current, ttl}, nil
+	})
+	s.HandleScript(slidingWindowScript.Source(), func(call callFunc, keys []string, args []string) (any, error) {
+		window, _ := strconv.ParseInt(args[0], 10, 64)
+		limit, _ := strconv.ParseInt(args[1], 10, 64)
+		n, _ := strconv.ParseInt(args[2], 10, 64)
+		now := time.Now().UnixMilli()
+		expired, err := call("ZRANGEBYSCORE", keys[0], "-inf", strconv.FormatInt(now-window, 10))
+		if err != nil {
+			return nil, err
+		}
+		if members := expired.([]string); len(members) > 0 {
+			if _, err := call(append([]string{"ZREM", keys[0]}, members...)...); err != nil {
+				return nil, err
+			}
+		}
+		v, err := call("ZCARD", keys[0])
+		if err != nil {
+			return nil, err
+		}
+		count := v.(int64)
+		if count+n > limit {
+			retry := int64(0)
+			if n <= limit {
+				index := strconv.FormatInt(count+n-limit-1, 10)
+				hit, err := call("ZRANGE", keys[0], index, index, "WITHSCORES")
+				if err != nil {
+					return nil, err
+				}
+				if hit := hit.([]string); len(hit) == 2 {
+					score, _ := strconv.ParseFloat(hit[1], 64)
+					retry = int64(score) + window - now
+				}
+			}
+			return []any{int64(0), count, retry}, nil
+		}
+		for i := int64(1); i <= n; i++ {
+			if _, err := call("ZADD", keys[0], strconv.FormatInt(now, 10), fmt.Sprint(args[3], ":", i)); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := call("PEXPIRE", keys[0], args[0]); err != nil {
+			return nil, err
+		}
+		return []any{int64(1), count + n, int64(0)}, nil
+	})
+	s.HandleScript(tokenBucketScript.Source(), func(call callFunc, keys []string, args []string) (any, error) {
+		rate, _ := strconv.ParseFloat(args[0], 64)
+		burst, _ := strconv.ParseFloat(args[1], 64)
+		n, _ := strconv.ParseFloat(args[2], 64)
+		now := time.Now().UnixMilli()
+		tokensValue, err := call("HGET", keys[0], "tokens")
+		if err != nil {
+			return nil, err
+		}
+		tsValue, err := call("HGET", keys[0], "ts")
+		if err != nil {
+			return nil, err
+		}
+		tokens, ts := burst, now
+		if tokensValue != nil && tsValue != nil {
+			tokens, _ = strconv.ParseFloat(tokensValue.(string), 64)
+			ts, _ = strconv.ParseInt(tsValue.(string), 10, 64)
+		}
+		tokens = math.Min(burst, tokens+float64(max(now-ts, 0))*rate/1000)
+		allowed, retry := int64(0), int64(0)
+		if tokens >= n {
+			tokens -= n
+			allowed = 1
+		} else if n <= burst {
+			retry = int64(math.Ceil((n - tokens) * 1000 / rate))
+		}
+		if _, err := call("HSET", keys[0], "tokens", strconv.FormatFloat(tokens, 'f', -1, 64), "ts", strconv.FormatInt(now, 10)); err != nil {
+			return nil, err
+		}
+		if _, err := call("PEXPIRE", keys[0], strconv.FormatInt(int64(math.Ceil(burst*1000/rate))+1000, 10)); err != nil {
+			return nil, err
+		}
+		return []any{allowed, int64(math.Floor(tokens)), retry}, nil
+	})
+}
+
+// backends 在Redis(脚本)和进程内缓存(进程内加锁)上分别运行, 设置了 cachetest.RedisAddrEnv 时同时在真实的Redis上执行脚本
+func backends(t *testing.T) map[string]cache.ICommonCache {
+	t.Helper()
+	s := cachetest.StartServer(t, cachetest.ServerOption{})
+	registerScripts(s)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	memory := cache_memory.NewCommonCache(cache_memory.Option{})
+	t.Cleanup(func() {
+		_ = memory.(io.Closer).Close()
+		_ = client.Close()
+	})
+	rs := map[string]cache.ICommonCache{
+		"redis":  cache_redis.NewCommonCache(client, "test"),
+		"memory": memory,
+	}
+	if real, ok := cachetest.ConnectRedis(t); ok {
+		c := cache_redis.NewCommonCache(real, fmt.Sprint("ratelimit-test:", time.Now().UnixNano()))
+		t.Cleanup(func() {
+			_, _ = c.DeleteByPattern(context.Background(), "*")
+		})
+		rs["redis-server"] = c
+	}
+	return rs
+}
+
+func allow(t *testing.T, l ILimiter, key string, n int64) *Result {
+	t.Helper()
+	rs, err := l.AllowN(context.Background(), key, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs
+}
+
+func TestFixedWindow(t *testing.T) {
+	for name, client := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			l, err := NewFixedWindow(client, "fixed", 3, 200*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := int64(1); i <= 3; i++ {
+				if rs := allow(t, l, "u", 1); !rs.Allowed || rs.Remaining != 3-i || rs.Limit != 3 {
+					t.Fatalf("request %d: want allowed with %d remaining, got %+v", i, 3-i, rs)
+				}
+			}
+			rs := allow(t, l, "u", 1)
+			if rs.Allowed || rs.Remaining != 0 || rs.RetryAfter <= 0 || rs.RetryAfter > 200*time.Millisecond {
+				t.Errorf("over limit: want denied with RetryAfter within the window, got %+v", rs)
+			}
+			if rs := allow(t, l, "u", 4); rs.Allowed || rs.RetryAfter != 0 {
+				t.Errorf("n over limit can never be allowed, got %+v", rs)
+			}
+			if rs := allow(t, l, "other", 1); !rs.Allowed {
+				t.Error("keys should be limited independently")
+			}
+
+			time.Sleep(rs.RetryAfter + 20*time.Millisecond)
+			if rs := allow(t, l, "u", 3); !rs.Allowed || rs.Remaining != 0 {
+				t.Errorf("new window: want allowed, got %+v", rs)
+			}
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	for name, client := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			l, err := NewSlidingWindow(client, "sliding", 2, 200*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rs := allow(t, l, "u", 1); !rs.Allowed || rs.Remaining != 1 {
+				t.Fatalf("first request: want allowed, got %+v", rs)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if rs := allow(t, l, "u", 1); !rs.Allowed || rs.Remaining != 0 {
+				t.Fatalf("second request: want allowed, got %+v", rs)
+			}
+			// 第一条记录约100ms后滑出窗口
+			rs := allow(t, l, "u", 1)
+			if rs.Allowed || rs.RetryAfter <= 0 || rs.RetryAfter > 120*time.Millisecond {
+				t.Fatalf("over limit: want RetryAfter of the oldest hit, got %+v", rs)
+			}
+			if rs := allow(t, l, "u", 3); rs.Allowed || rs.RetryAfter != 0 {
+				t.Errorf("n over limit can never be allowed, got %+v", rs)
+			}
+			time.Sleep(rs.RetryAfter + 20*time.Millisecond)
+			if rs := allow(t, l, "u", 1); !rs.Allowed || rs.Remaining != 0 {
+				t.Errorf("after the oldest hit slides out: want allowed with 0 remaining, got %+v", rs)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	for name, client := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			l, err := NewTokenBucket(client, "bucket", 20, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rs := allow(t, l, "u", 2); !rs.Allowed || rs.Remaining != 0 || rs.Limit != 2 {
+				t.Fatalf("burst: want allowed, got %+v", rs)
+			}
+			rs := allow(t, l, "u", 1)
+			if rs.Allowed || rs.RetryAfter <= 0 || rs.RetryAfter > 50*time.Millisecond {
+				t.Fatalf("empty bucket: want RetryAfter of one token, got %+v", rs)
+			}
+			if rs := allow(t, l, "u", 3); rs.Allowed || rs.RetryAfter != 0 {
+				t.Errorf("n over burst can never be allowed, got %+v", rs)
+			}
+			time.Sleep(rs.RetryAfter + 20*time.Millisecond)
+			if rs := allow(t, l, "u", 1); !rs.Allowed {
+				t.Errorf("refilled bucket: want allowed, got %+v", rs)
+			}
+		})
+	}
+}
+
+func TestNewTokenBucketRejectsInvalidOptions(t *testing.T) {
+	client := cache_memory.NewCommonCache(cache_memory.Option{})
+	defer client.(io.Closer).Close()
+
+	tests := []struct {
+		rate  float64
+		burst int64
+	}{
+		{0, 10}, {-1, 10}, {math.NaN(), 10}, {math.Inf(1), 10}, {1, 0}, {1, -1},
+	}
+	for _, tt := range tests {
+		if _, err := NewTokenBucket(client, "bucket", tt.rate, tt.burst); !errors.Is(err, ErrInvalidBucket) {
+			t.Errorf("NewTokenBucket(%v, %d): want ErrInvalidBucket, got %v", tt.rate, tt.burst, err)
+		}
+	}
+}
+
+func TestNewWindowRejectsInvalidOptions(t *testing.T) {
+	client := cache_memory.NewCommonCache(cache_memory.Option{})
+	defer client.(io.Closer).Close()
+
+	tests := []struct {
+		limit  int64
+		window time.Duration
+	}{
+		{0, time.Second}, {-1, time.Second}, {1, 0}, {1, -time.Second}, {1, time.Microsecond},
+	}
+	for _, tt := range tests {
+		if _, err := NewFixedWindow(client, "fixed", tt.limit, tt.window); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("NewFixedWindow(%d, %s): want ErrInvalidLimit, got %v", tt.limit, tt.window, err)
+		}
+		if _, err := NewSlidingWindow(client, "sliding", tt.limit, tt.window); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("NewSlidingWindow(%d, %s): want ErrInvalidLimit, got %v", tt.limit, tt.window, err)
+		}
+	}
+}
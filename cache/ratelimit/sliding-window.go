@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+var slidingWindowScript = cache.NewScript(`
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count + n > limit then
+	local retry = 0
+	local index = count + n - limit - 1
+	if n <= limit then
+		local hit = redis.call("ZRANGE", KEYS[1], index, index, "WITHSCORES")
+		if hit[2] then
+			retry = tonumber(hit[2]) + window - now
+		end
+	end
+	return {0, count, retry}
+end
+for i = 1, n do
+	redis.call("ZADD", KEYS[1], now, ARGV[4] .. ":" .. i)
+end
+redis.call("PEXPIRE", KEYS[1], window)
+return {1, count + n, 0}`)
+
+var _ ILimiter = (*slidingWindow)(nil)
+
+// slidingWindow 滑动窗口日志限流, 记录窗口内每一次请求的时间
+type slidingWindow struct {
+	client cache.ICommonCache
+	name   string
+	limit  int64
+	window time.Duration
+	lock   sync.Mutex
+}
+
+type slidingWindowState struct {
+	Hits []int64 `json:"hits"`
+}
+
+// NewSlidingWindow limit 小于等于0或 window 小于1毫秒时返回 ErrInvalidLimit
+func NewSlidingWindow(client cache.ICommonCache, name string, limit int64, window time.Duration) (ILimiter, error) {
+	if err := validWindow(limit, window); err != nil {
+		return nil, err
+	}
+	return &slidingWindow{
+		client: client,
+		name:   name,
+		limit:  limit,
+		window: window,
+	}, nil
+}
+
+func (l *slidingWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *slidingWindow) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	redisKey := limitKey(l.name, key)
+	values, err := evalInts(ctx, l.client, slidingWindowScript, []string{redisKey}, l.window.Milliseconds(), l.limit, n, uuid.NewString())
+	if errors.Is(err, cache.ErrNotSupported) {
+		return l.allowLocal(ctx, redisKey, n)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.result(values[0] == 1, values[1], time.Duration(values[2])*time.Millisecond), nil
+}
+
+func (l *slidingWindow) allowLocal(ctx context.Context, redisKey string, n int64) (*Result, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now().UnixMilli()
+	window := l.window.Milliseconds()
+	state, err := loadState[slidingWindowState](ctx, l.client, redisKey)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = new(slidingWindowState)
+	}
+	hits := state.Hits[:0]
+	for _, hit := range state.Hits {
+		if hit > now-window {
+			hits = append(hits, hit)
+		}
+	}
+	state.Hits = hits
+	count := int64(len(hits))
+	if count+n > l.limit {
+		retry := int64(0)
+		if n <= l.limit {
+			retry = hits[count+n-l.limit-1] + window - now
+		}
+		return l.result(false, count, time.Duration(retry)*time.Millisecond), nil
+	}
+	for i := int64(0); i < n; i++ {
+		state.Hits = append(state.Hits, now)
+	}
+	if err := saveState(ctx, l.client, redisKey, state, l.window); err != nil {
+		return nil, err
+	}
+	return l.result(true, count+n, 0), nil
+}
+
+func (l *slidingWindow) result(allowed bool, count int64, retry time.Duration) *Result {
+	rs := &Result{
+		Allowed:   allowed,
+		Limit:     l.limit,
+		Remaining: max(l.limit-count, 0),
+	}
+	if !allowed {
+		rs.RetryAfter = max(retry, 0)
+	}
+	return rs
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+)
+
+var tokenBucketScript = cache.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(now - ts, 0) * rate / 1000)
+local allowed = 0
+local retry = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+elseif n <= burst then
+	retry = math.ceil((n - tokens) * 1000 / rate)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry}`)
+
+var _ ILimiter = (*tokenBucket)(nil)
+
+// tokenBucket 令牌桶限流, 每秒补充 rate 个令牌, 桶容量为 burst
+type tokenBucket struct {
+	client cache.ICommonCache
+	name   string
+	rate   float64
+	burst  int64
+	lock   sync.Mutex
+}
+
+type tokenBucketState struct {
+	Tokens float64 `json:"tokens"`
+	Ts     int64   `json:"ts"`
+}
+
+// ErrInvalidBucket 令牌桶的补充速率和容量必须大于0
+var ErrInvalidBucket = errors.New("ratelimit: token bucket rate and burst must be positive")
+
+// NewTokenBucket rate 或 burst 小于等于0时返回 ErrInvalidBucket
+func NewTokenBucket(client cache.ICommonCache, name string, rate float64, burst int64) (ILimiter, error) {
+	if !(rate > 0) || math.IsInf(rate, 1) || burst <= 0 {
+		return nil, ErrInvalidBucket
+	}
+	return &tokenBucket{
+		client: client,
+		name:   name,
+		rate:   rate,
+		burst:  burst,
+	}, nil
+}
+
+func (l *tokenBucket) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *tokenBucket) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	redisKey := limitKey(l.name, key)
+	values, err := evalInts(ctx, l.client, tokenBucketScript, []string{redisKey}, l.rate, l.burst, n)
+	if errors.Is(err, cache.ErrNotSupported) {
+		return l.allowLocal(ctx, redisKey, n)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.result(values[0] == 1, values[1], time.Duration(values[2])*time.Millisecond), nil
+}
+
+func (l *tokenBucket) allowLocal(ctx context.Context, redisKey string, n int64) (*Result, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now().UnixMilli()
+	burst := float64(l.burst)
+	state, err := loadState[tokenBucketState](ctx, l.client, redisKey)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = &tokenBucketState{Tokens: burst, Ts: now}
+	}
+	state.Tokens = math.Min(burst, state.Tokens+float64(max(now-state.Ts, 0))*l.rate/1000)
+	state.Ts = now
+
+	allowed := false
+	var retry int64
+	if state.Tokens >= float64(n) {
+		state.Tokens -= float64(n)
+		allowed = true
+	} else if n <= l.burst {
+		retry = int64(math.Ceil((float64(n) - state.Tokens) * 1000 / l.rate))
+	}
+	expiration := time.Duration(math.Ceil(burst*1000/l.rate))*time.Millisecond + time.Second
+	if err := saveState(ctx, l.client, redisKey, state, expiration); err != nil {
+		return nil, err
+	}
+	return l.result(allowed, int64(state.Tokens), time.Duration(retry)*time.Millisecond), nil
+}
+
+func (l *tokenBucket) result(allowed bool, tokens int64, retry time.Duration) *Result {
+	rs := &Result{
+		Allowed:   allowed,
+		Limit:     l.burst,
+		Remaining: max(tokens, 0),
+	}
+	if !allowed {
+		rs.RetryAfter = max(retry, 0)
+	}
+	return rs
+}
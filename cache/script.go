@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrNotSupported 当前实现不支持该操作, 如进程内缓存不支持执行Lua脚本
+var ErrNotSupported = errors.New("cache: not supported")
+
+// Script Lua脚本, 通过 ICommonCache.Eval 执行
+type Script struct {
+	src  string
+	hash string
+}
+
+func NewScript(src string) *Script {
+	h := sha1.Sum([]byte(src))
+	return &Script{
+		src:  src,
+		hash: hex.EncodeToString(h[:]),
+	}
+}
+
+func (s *Script) Source() string {
+	return s.src
+}
+
+func (s *Script) Hash() string {
+	return s.hash
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type flightCall[T any] struct {
+	done chan struct{}
+	val  *T
+	err  error
+}
+
+// flightGroup 合并进程内对同一个key的并发加载
+type flightGroup[T any] struct {
+	lock  sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+// do 加载在脱离调用方取消信号的ctx中执行, 最长执行 timeout, 发起加载的调用方被取消不会影响其他等待者,
+// 每个调用方只等待到自己的ctx结束
+func (g *flightGroup[T]) do(ctx context.Context, key string, timeout time.Duration, fn func(ctx context.Context) (*T, error)) (*T, error) {
+	g.lock.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	c, has := g.calls[key]
+	if !has {
+		c = &flightCall[T]{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.run(ctx, key, c, timeout, fn)
+	}
+	g.lock.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (g *flightGroup[T]) run(ctx context.Context, key string, c *flightCall[T], timeout time.Duration, fn func(ctx context.Context) (*T, error)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			c.val, c.err = nil, fmt.Errorf("cache: load %s panic: %v", key, r)
+		}
+		g.lock.Lock()
+		delete(g.calls, key)
+		g.lock.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn(ctx)
+}
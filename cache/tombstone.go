@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+)
+
+var (
+	// tombstone 负缓存的空标记, 以0字节开头, 不会与编码后的数据冲突
+	tombstone = []byte("\x00cache:tombstone")
+
+	errTombstone = fmt.Errorf("%w: negative cached", ErrNotFound)
+)
+
+func isTombstone(data []byte) bool {
+	return bytes.Equal(data, tombstone)
+}
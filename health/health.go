@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	defaultBackoff    = time.Second
+	defaultMaxBackoff = time.Second * 30
+)
+
+// IHealth 外部依赖(Redis、MySQL等)的健康检查, 健康时返回nil
+type IHealth interface {
+	Health(ctx context.Context) error
+}
+
+// Check 检查多个依赖, 返回不健康的依赖及其错误, 全部健康时返回空map
+func Check(ctx context.Context, checks map[string]IHealth) map[string]error {
+	rs := make(map[string]error)
+	for name, h := range checks {
+		if err := h.Health(ctx); err != nil {
+			rs[name] = err
+		}
+	}
+	return rs
+}
+
+// Startup 启动时连接依赖的重试配置
+type Startup struct {
+	// Attempts 最多尝试的次数, 小于等于1时只尝试一次
+	Attempts int `yaml:"attempts"`
+	// Backoff 第一次重试前的等待时间, 之后每次翻倍, 默认1秒
+	Backoff time.Duration `yaml:"backoff"`
+	// MaxBackoff 等待时间的上限, 默认30秒
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// Degraded 全部尝试失败后以降级模式继续启动, 依赖恢复前健康检查返回错误
+	Degraded bool `yaml:"degraded"`
+}
+
+// Do 按配置重试 fn 直到成功, 返回最后一次的错误
+func (s Startup) Do(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	attempts := max(s.Attempts, 1)
+	backoff := s.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+	maxBackoff := s.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	var err error
+	for i := 1; ; i++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+		if i >= attempts {
+			return fmt.Errorf("connect %s failed after %d attempts: %w", name, attempts, err)
+		}
+		log.Printf("connect %s failed (attempt %d/%d), retry in %s: %s", name, i, attempts, backoff, err.Error())
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
@@ -3,7 +3,11 @@ package store
 import (
 	"context"
 	"go/ast"
+	"log"
 	"reflect"
+	"time"
+
+	"github.com/mengri/utils-store/health"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -12,11 +16,20 @@ import (
 
 var _ IBaseStore[any] = (*Store[any])(nil)
 
+const healthTimeout = time.Second * 5
+
+var (
+	migrateBackoff    = time.Second
+	migrateMaxBackoff = time.Second * 30
+)
+
 type Store[T any] struct {
 	imlTransaction
 	UniqueList []clause.Column
 	Model      *T
 	name       string
+	// stopMigrate 停止后台建表
+	stopMigrate context.CancelFunc
 }
 
 func (b *Store[T]) CountByGroup(ctx context.Context, wm map[string]interface{}, groupBy string) (map[string]int64, error) {
@@ -61,13 +74,52 @@ func (b *Store[T]) UniqueIndex() {
 	}
 }
 
+// OnComplete 数据库以降级模式启动且当前不可用时, 在后台重试建表直到成功或调用 Close, 不阻塞启动
 func (b *Store[T]) OnComplete() {
-	ctx := context.Background()
-	err := b.DB(ctx).AutoMigrate(new(T))
+	b.UniqueIndex()
+	if h, ok := b.IDB.(health.IHealth); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+		err := h.Health(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("database unavailable, migrate %s in background: %s", b.name, err.Error())
+			ctx, cancel := context.WithCancel(context.Background())
+			b.stopMigrate = cancel
+			go b.migrateUntilReady(ctx)
+			return
+		}
+	}
+	err := b.DB(context.Background()).AutoMigrate(new(T))
 	if err != nil {
 		panic(err)
 	}
-	b.UniqueIndex()
+}
+
+// Close 停止后台建表
+func (b *Store[T]) Close() error {
+	if b.stopMigrate != nil {
+		b.stopMigrate()
+	}
+	return nil
+}
+
+func (b *Store[T]) migrateUntilReady(ctx context.Context) {
+	backoff := migrateBackoff
+	for {
+		err := b.DB(ctx).AutoMigrate(new(T))
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		log.Printf("migrate %s error:%s, retry in %s", b.name, err.Error(), backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, migrateMaxBackoff)
+	}
 }
 
 func (b *Store[T]) Get(ctx context.Context, id int64) (*T, error) {
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type item struct {
+	Id   int64 `gorm:"primaryKey"`
+	Name string
+}
+
+// unavailableDB 连接不上的数据库, 记录建表尝试次数
+type unavailableDB struct {
+	db          *gorm.DB
+	calls       atomic.Int32
+	hasDeadline atomic.Bool
+}
+
+func newUnavailableDB(t *testing.T) *unavailableDB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "root@tcp(127.0.0.1:1)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &unavailableDB{db: db}
+}
+
+func (d *unavailableDB) DB(ctx context.Context) *gorm.DB {
+	d.calls.Add(1)
+	return d.db.WithContext(ctx)
+}
+
+func (d *unavailableDB) IsTxCtx(ctx context.Context) bool {
+	return false
+}
+
+func (d *unavailableDB) Health(ctx context.Context) error {
+	_, ok := ctx.Deadline()
+	d.hasDeadline.Store(ok)
+	return errors.New("database unavailable")
+}
+
+func TestMigrateInBackgroundUntilClosed(t *testing.T) {
+	backoff, maxBackoff := migrateBackoff, migrateMaxBackoff
+	migrateBackoff, migrateMaxBackoff = time.Millisecond, 5*time.Millisecond
+	defer func() {
+		migrateBackoff, migrateMaxBackoff = backoff, maxBackoff
+	}()
+
+	db := newUnavailableDB(t)
+	s := new(Store[item])
+	s.IDB = db
+	s.OnComplete()
+	if !db.hasDeadline.Load() {
+		t.Error("health check should have a deadline")
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for db.calls.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("migration should be retried in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	stopped := db.calls.Load()
+	time.Sleep(50 * time.Millisecond)
+	if n := db.calls.Load(); n != stopped {
+		t.Errorf("Close should stop the background migration, %d more attempts", n-stopped)
+	}
+}
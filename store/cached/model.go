@@ -0,0 +1,61 @@
+package cached
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/mengri/utils-store/store"
+	"gorm.io/gorm/schema"
+)
+
+// model 通过gorm的schema读取模型的主键和uuid字段
+type model[T any] struct {
+	id   *schema.Field
+	uuid *schema.Field
+}
+
+func parseModel[T any]() *model[T] {
+	m := new(model[T])
+	s, err := schema.Parse(new(T), &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		return m
+	}
+	m.id = s.PrioritizedPrimaryField
+	m.uuid = s.LookUpField("uuid")
+	return m
+}
+
+func (m *model[T]) idOf(t *T) int64 {
+	var v interface{} = t
+	if table, ok := v.(store.Table); ok {
+		return table.IdValue()
+	}
+	if m.id == nil {
+		return 0
+	}
+	value, zero := m.id.ValueOf(context.Background(), reflect.ValueOf(t).Elem())
+	if zero {
+		return 0
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	}
+	return 0
+}
+
+func (m *model[T]) uuidOf(t *T) string {
+	if m.uuid == nil {
+		return ""
+	}
+	value, zero := m.uuid.ValueOf(context.Background(), reflect.ValueOf(t).Elem())
+	if zero {
+		return ""
+	}
+	uuid, _ := value.(string)
+	return uuid
+}
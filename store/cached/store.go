@@ -0,0 +1,294 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mengri/utils-store/cache"
+	"github.com/mengri/utils-store/store"
+	"gorm.io/gorm"
+)
+
+var _ store.IBaseStore[any] = (*Store[any])(nil)
+
+// Store 为 IBaseStore 增加读穿透缓存
+// Get/GetByUUID 读取缓存, 事务中的读取直接访问数据库; 写操作成功后删除受影响的缓存, 处于事务中时在提交后删除
+// 能确定主键的操作(Insert/Update/Delete/DeleteUUID 和带主键的Save)只删除对应的key,
+// 按条件或SQL修改的操作以及唯一索引upsert会递增缓存的代数, 使该表的所有缓存失效
+type Store[T any] struct {
+	store.IBaseStore[T]
+	name   string
+	client cache.ICommonCache
+	byId   cache.IKVCache[T, int64]
+	byUUID cache.IKVCache[int64, string]
+	model  *model[T]
+}
+
+// NewStore name 用于区分不同表的缓存key, 通常为表名
+func NewStore[T any](name string, base store.IBaseStore[T], client cache.ICommonCache, expiration time.Duration, opts ...cache.Option) store.IBaseStore[T] {
+	prefix := fmt.Sprint("store:", name)
+	return &Store[T]{
+		IBaseStore: base,
+		name:       name,
+		client:     client,
+		byId: cache.CreateKvCacheWithOptions[T, int64](client, expiration, func(id int64) string {
+			return fmt.Sprint(prefix, ":id:", id)
+		}, append(opts, cache.WithGeneration(prefix))...),
+		byUUID: cache.CreateKvCacheWithOptions[int64, string](client, expiration, func(uuid string) string {
+			return fmt.Sprint(prefix, ":uuid:", uuid)
+		}, opts...),
+		model: parseModel[T](),
+	}
+}
+
+// loadError loader 中数据库返回的错误, 与读写缓存的错误区分, 前者直接返回, 后者降级为直接读取数据库
+type loadError struct {
+	err error
+}
+
+func (e *loadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *loadError) Unwrap() error {
+	return e.err
+}
+
+// fromDB 返回loader中数据库的错误, 错误来自缓存时返回false
+func fromDB(err error) (error, bool) {
+	var le *loadError
+	if errors.As(err, &le) {
+		return le.err, true
+	}
+	return nil, false
+}
+
+func isTxCtx(ctx context.Context) bool {
+	_, ok := ctx.Value(store.TxContextKey).(*gorm.DB)
+	return ok
+}
+
+func (s *Store[T]) Get(ctx context.Context, id int64) (*T, error) {
+	if isTxCtx(ctx) {
+		return s.IBaseStore.Get(ctx, id)
+	}
+	t, err := s.byId.GetOrLoad(ctx, id, func(ctx context.Context, id int64) (*T, error) {
+		t, err := s.IBaseStore.Get(ctx, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, cache.ErrNotFound
+		}
+		if err != nil {
+			return nil, &loadError{err: err}
+		}
+		return t, nil
+	})
+	if err == nil {
+		return t, nil
+	}
+	if dbErr, ok := fromDB(err); ok {
+		return nil, dbErr
+	}
+	if errors.Is(err, cache.ErrNotFound) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	// 缓存不可用时直接读取数据库
+	log.Printf("read store cache %s:%d error:%s", s.name, id, err.Error())
+	return s.IBaseStore.Get(ctx, id)
+}
+
+// GetByUUID 缓存uuid到主键的映射, 再通过主键读取
+func (s *Store[T]) GetByUUID(ctx context.Context, uuid string) (*T, error) {
+	if isTxCtx(ctx) {
+		return s.IBaseStore.GetByUUID(ctx, uuid)
+	}
+	id, err := s.byUUID.GetOrLoad(ctx, uuid, func(ctx context.Context, uuid string) (*int64, error) {
+		t, err := s.IBaseStore.GetByUUID(ctx, uuid)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, cache.ErrNotFound
+			}
+			return nil, &loadError{err: err}
+		}
+		id := s.model.idOf(t)
+		_ = s.byId.Set(ctx, id, t)
+		return &id, nil
+	})
+	dbErr, fromLoader := fromDB(err)
+	switch {
+	case err == nil:
+	case fromLoader:
+		return nil, dbErr
+	case errors.Is(err, cache.ErrNotFound):
+		return nil, gorm.ErrRecordNotFound
+	default:
+		log.Printf("read store cache %s:%s error:%s", s.name, uuid, err.Error())
+		return s.IBaseStore.GetByUUID(ctx, uuid)
+	}
+
+	t, err := s.Get(ctx, *id)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err != nil || (s.model.uuid != nil && s.model.uuidOf(t) != uuid) {
+		// 映射已失效(记录被删除或uuid被修改), 删除映射后从数据库读取
+		_ = s.byUUID.Delete(ctx, uuid)
+		return s.IBaseStore.GetByUUID(ctx, uuid)
+	}
+	return t, nil
+}
+
+func (s *Store[T]) Insert(ctx context.Context, ts ...*T) error {
+	if err := s.IBaseStore.Insert(ctx, ts...); err != nil {
+		return err
+	}
+	// 清理可能存在的空标记
+	ids := make([]int64, 0, len(ts))
+	uuids := make([]string, 0, len(ts))
+	for _, t := range ts {
+		ids = append(ids, s.model.idOf(t))
+		uuids = append(uuids, s.model.uuidOf(t))
+	}
+	s.invalidate(ctx, ids, uuids)
+	return nil
+}
+
+// Save 没有主键时可能按唯一索引更新已有记录, 此时无法确定受影响的记录, 新增记录应使用 Insert
+func (s *Store[T]) Save(ctx context.Context, t *T) error {
+	id := s.model.idOf(t)
+	if err := s.IBaseStore.Save(ctx, t); err != nil {
+		return err
+	}
+	if id == 0 {
+		s.invalidateAll(ctx)
+		return nil
+	}
+	s.invalidate(ctx, []int64{id}, []string{s.model.uuidOf(t)})
+	return nil
+}
+
+func (s *Store[T]) UpdateByUnique(ctx context.Context, t *T, uniques []string) error {
+	if err := s.IBaseStore.UpdateByUnique(ctx, t, uniques); err != nil {
+		return err
+	}
+	s.invalidateAll(ctx)
+	return nil
+}
+
+func (s *Store[T]) Update(ctx context.Context, t *T) (int, error) {
+	n, err := s.IBaseStore.Update(ctx, t)
+	if err != nil {
+		return n, err
+	}
+	s.invalidate(ctx, []int64{s.model.idOf(t)}, []string{s.model.uuidOf(t)})
+	return n, nil
+}
+
+func (s *Store[T]) Delete(ctx context.Context, ids ...int64) (int, error) {
+	n, err := s.IBaseStore.Delete(ctx, ids...)
+	if err != nil {
+		return n, err
+	}
+	s.invalidate(ctx, ids, nil)
+	return n, nil
+}
+
+func (s *Store[T]) DeleteUUID(ctx context.Context, uuid string) error {
+	// 先查询主键, 删除后无法再得到
+	var ids []int64
+	if t, err := s.IBaseStore.GetByUUID(ctx, uuid); err == nil {
+		ids = append(ids, s.model.idOf(t))
+	}
+	if err := s.IBaseStore.DeleteUUID(ctx, uuid); err != nil {
+		return err
+	}
+	s.invalidate(ctx, ids, []string{uuid})
+	return nil
+}
+
+func (s *Store[T]) UpdateWhere(ctx context.Context, w map[string]interface{}, m map[string]interface{}) (int64, error) {
+	n, err := s.IBaseStore.UpdateWhere(ctx, w, m)
+	if err != nil {
+		return n, err
+	}
+	s.invalidateAll(ctx)
+	return n, nil
+}
+
+func (s *Store[T]) UpdateField(ctx context.Context, field string, value interface{}, sql string, args ...interface{}) (int64, error) {
+	n, err := s.IBaseStore.UpdateField(ctx, field, value, sql, args...)
+	if err != nil {
+		return n, err
+	}
+	s.invalidateAll(ctx)
+	return n, nil
+}
+
+func (s *Store[T]) DeleteWhere(ctx context.Context, m map[string]interface{}) (int64, error) {
+	n, err := s.IBaseStore.DeleteWhere(ctx, m)
+	if err != nil {
+		return n, err
+	}
+	s.invalidateAll(ctx)
+	return n, nil
+}
+
+func (s *Store[T]) DeleteQuery(ctx context.Context, sql string, args ...interface{}) (int64, error) {
+	n, err := s.IBaseStore.DeleteQuery(ctx, sql, args...)
+	if err != nil {
+		return n, err
+	}
+	s.invalidateAll(ctx)
+	return n, nil
+}
+
+func (s *Store[T]) SoftDelete(ctx context.Context, where map[string]interface{}) error {
+	if err := s.IBaseStore.SoftDelete(ctx, where); err != nil {
+		return err
+	}
+	s.invalidateAll(ctx)
+	return nil
+}
+
+func (s *Store[T]) SoftDeleteQuery(ctx context.Context, sql string, args ...interface{}) error {
+	if err := s.IBaseStore.SoftDeleteQuery(ctx, sql, args...); err != nil {
+		return err
+	}
+	s.invalidateAll(ctx)
+	return nil
+}
+
+// invalidate 删除指定主键和uuid的缓存, 事务中在提交后执行
+func (s *Store[T]) invalidate(ctx context.Context, ids []int64, uuids []string) {
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		keys := make([]int64, 0, len(ids))
+		for _, id := range ids {
+			if id != 0 {
+				keys = append(keys, id)
+			}
+		}
+		if err := s.byId.Delete(ctx, keys...); err != nil {
+			log.Printf("invalidate store cache %s %v error:%s", s.name, keys, err.Error())
+		}
+		us := make([]string, 0, len(uuids))
+		for _, uuid := range uuids {
+			if uuid != "" {
+				us = append(us, uuid)
+			}
+		}
+		if err := s.byUUID.Delete(ctx, us...); err != nil {
+			log.Printf("invalidate store cache %s %v error:%s", s.name, us, err.Error())
+		}
+	})
+}
+
+// invalidateAll 递增代数, 使该表的所有主键缓存失效, uuid映射在读取时校验
+func (s *Store[T]) invalidateAll(ctx context.Context) {
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		if _, err := cache.BumpGeneration(ctx, s.client, fmt.Sprint("store:", s.name)); err != nil {
+			log.Printf("invalidate store cache %s error:%s", s.name, err.Error())
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/mengri/utils-store/cache/cachetest"
+	"github.com/mengri/utils-store/store"
+)
+
+type row struct {
+	Id   int64 `gorm:"primaryKey"`
+	UUID string
+	Name string
+}
+
+// fakeStore 只实现读穿透和失效用到的方法, 其他方法调用时panic
+type fakeStore struct {
+	store.IBaseStore[row]
+	lock  sync.Mutex
+	rows  map[int64]*row
+	err   error
+	wait  chan struct{}
+	reads atomic.Int32
+}
+
+func newFakeStore(rows ...*row) *fakeStore {
+	f := &fakeStore{rows: make(map[int64]*row)}
+	for _, r := range rows {
+		f.rows[r.Id] = r
+	}
+	return f
+}
+
+func (f *fakeStore) read(match func(r *row) bool) (*row, error) {
+	f.reads.Add(1)
+	if f.wait != nil {
+		<-f.wait
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	for _, r := range f.rows {
+		if match(r) {
+			v := *r
+			return &v, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeStore) Get(ctx context.Context, id int64) (*row, error) {
+	return f.read(func(r *row) bool { return r.Id == id })
+}
+
+func (f *fakeStore) GetByUUID(ctx context.Context, uuid string) (*row, error) {
+	return f.read(func(r *row) bool { return r.UUID == uuid })
+}
+
+func (f *fakeStore) Update(ctx context.Context, t *row) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	v := *t
+	f.rows[t.Id] = &v
+	return 1, nil
+}
+
+func (f *fakeStore) UpdateWhere(ctx context.Context, w map[string]interface{}, m map[string]interface{}) (int64, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, r := range f.rows {
+		r.Name = m["name"].(string)
+	}
+	return int64(len(f.rows)), nil
+}
+
+func (f *fakeStore) Delete(ctx context.Context, ids ...int64) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, id := range ids {
+		delete(f.rows, id)
+	}
+	return len(ids), nil
+}
+
+func newStore(t *testing.T, base *fakeStore) (store.IBaseStore[row], *cachetest.Server) {
+	t.Helper()
+	client, s := cachetest.NewRedisCache(t)
+	return NewStore[row]("rows", base, client, time.Minute), s
+}
+
+func TestReadThrough(t *testing.T) {
+	ctx := context.Background()
+	base := newFakeStore(&row{Id: 1, UUID: "u1", Name: "a"})
+	rows, _ := newStore(t, base)
+
+	for i := 0; i < 2; i++ {
+		if r, err := rows.Get(ctx, 1); err != nil || r.Name != "a" {
+			t.Fatalf("Get: want a, got %v %v", r, err)
+		}
+		if r, err := rows.GetByUUID(ctx, "u1"); err != nil || r.Id != 1 {
+			t.Fatalf("GetByUUID: want 1, got %v %v", r, err)
+		}
+	}
+	if n := base.reads.Load(); n != 2 {
+		t.Errorf("second reads should hit the cache, got %d database reads", n)
+	}
+	if _, err := rows.Get(ctx, 2); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("missing row: want ErrRecordNotFound, got %v", err)
+	}
+	if _, err := rows.GetByUUID(ctx, "u2"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("missing uuid: want ErrRecordNotFound, got %v", err)
+	}
+}
+
+// 数据库错误直接返回给所有等待加载结果的调用, 不会被当作缓存故障再次读取数据库
+func TestDatabaseErrorIsReturned(t *testing.T) {
+	ctx := context.Background()
+	dbErr := errors.New("database is down")
+	base := newFakeStore(&row{Id: 1, UUID: "u1"})
+	base.err = dbErr
+	base.wait = make(chan struct{})
+	rows, _ := newStore(t, base)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := rows.Get(ctx, 1); !errors.Is(err, dbErr) {
+				t.Errorf("Get: want the database error, got %v", err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(base.wait)
+	wg.Wait()
+	if n := base.reads.Load(); n != 1 {
+		t.Errorf("concurrent misses should read the database once, got %d", n)
+	}
+
+	base.reads.Store(0)
+	if _, err := rows.GetByUUID(ctx, "u1"); !errors.Is(err, dbErr) {
+		t.Errorf("GetByUUID: want the database error, got %v", err)
+	}
+	if n := base.reads.Load(); n != 1 {
+		t.Errorf("GetByUUID should not retry the database, got %d reads", n)
+	}
+}
+
+func TestCacheFailureFallsBackToDatabase(t *testing.T) {
+	ctx := context.Background()
+	base := newFakeStore(&row{Id: 1, UUID: "u1", Name: "a"})
+	rows, s := newStore(t, base)
+	_ = s.Close()
+
+	if r, err := rows.Get(ctx, 1); err != nil || r.Name != "a" {
+		t.Errorf("Get: want a from the database, got %v %v", r, err)
+	}
+	if r, err := rows.GetByUUID(ctx, "u1"); err != nil || r.Id != 1 {
+		t.Errorf("GetByUUID: want 1 from the database, got %v %v", r, err)
+	}
+}
+
+func TestWritesInvalidate(t *testing.T) {
+	ctx := context.Background()
+	base := newFakeStore(&row{Id: 1, UUID: "u1", Name: "a"}, &row{Id: 2, UUID: "u2", Name: "b"})
+	rows, _ := newStore(t, base)
+	name := func(id int64) string {
+		r, err := rows.Get(ctx, id)
+		if err != nil {
+			return err.Error()
+		}
+		return r.Name
+	}
+	_, _ = name(1), name(2)
+
+	_, _ = rows.Update(ctx, &row{Id: 1, UUID: "u1", Name: "a2"})
+	if got := name(1); got != "a2" {
+		t.Errorf("Update should invalidate the row, got %s", got)
+	}
+	_, _ = rows.UpdateWhere(ctx, map[string]interface{}{}, map[string]interface{}{"name": "all"})
+	if got1, got2 := name(1), name(2); got1 != "all" || got2 != "all" {
+		t.Errorf("UpdateWhere should invalidate every row, got %s %s", got1, got2)
+	}
+	_, _ = rows.GetByUUID(ctx, "u2")
+	_, _ = rows.Delete(ctx, 2)
+	if _, err := rows.Get(ctx, 2); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Delete should invalidate the row, got %v", err)
+	}
+	if _, err := rows.GetByUUID(ctx, "u2"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("uuid of a deleted row should not resolve, got %v", err)
+	}
+}
@@ -1,14 +1,18 @@
 package auto_env
 
 import (
+	"context"
 	"fmt"
 	"github.com/joho/godotenv"
+	"github.com/mengri/utils-store/health"
 	"github.com/mengri/utils-store/store"
 	store_mysql "github.com/mengri/utils-store/store/mysql"
 	"github.com/mengri/utils/autowire-v2"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func init() {
@@ -24,7 +28,42 @@ func createDbFromEnv() store.IDB {
 	if err != nil {
 		log.Fatal(err)
 	}
-	return store_mysql.CreateDb(databaseUrl)
+	startup, err := loadStartup()
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := store_mysql.NewDb(context.Background(), databaseUrl, startup)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+// loadStartup 从环境变量读取启动重试和降级配置, 与yaml配置的 startup 配置节对应, 未设置时只尝试一次
+func loadStartup() (health.Startup, error) {
+	var startup health.Startup
+	var err error
+	if v := os.Getenv("DATABASE_STARTUP_ATTEMPTS"); v != "" {
+		if startup.Attempts, err = strconv.Atoi(v); err != nil {
+			return startup, fmt.Errorf("invalid DATABASE_STARTUP_ATTEMPTS %q: %w", v, err)
+		}
+	}
+	if v := os.Getenv("DATABASE_STARTUP_BACKOFF"); v != "" {
+		if startup.Backoff, err = time.ParseDuration(v); err != nil {
+			return startup, fmt.Errorf("invalid DATABASE_STARTUP_BACKOFF %q: %w", v, err)
+		}
+	}
+	if v := os.Getenv("DATABASE_STARTUP_MAX_BACKOFF"); v != "" {
+		if startup.MaxBackoff, err = time.ParseDuration(v); err != nil {
+			return startup, fmt.Errorf("invalid DATABASE_STARTUP_MAX_BACKOFF %q: %w", v, err)
+		}
+	}
+	if v := os.Getenv("DATABASE_STARTUP_DEGRADED"); v != "" {
+		if startup.Degraded, err = strconv.ParseBool(v); err != nil {
+			return startup, fmt.Errorf("invalid DATABASE_STARTUP_DEGRADED %q: %w", v, err)
+		}
+	}
+	return startup, nil
 }
 func loadConfig() (string, error) {
 	// 加载 .env 文件
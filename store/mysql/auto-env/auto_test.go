@@ -0,0 +1,47 @@
+package auto_env
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mengri/utils-store/health"
+)
+
+func TestLoadStartup(t *testing.T) {
+	t.Setenv("DATABASE_STARTUP_ATTEMPTS", "5")
+	t.Setenv("DATABASE_STARTUP_BACKOFF", "500ms")
+	t.Setenv("DATABASE_STARTUP_MAX_BACKOFF", "10s")
+	t.Setenv("DATABASE_STARTUP_DEGRADED", "true")
+	startup, err := loadStartup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := health.Startup{Attempts: 5, Backoff: 500 * time.Millisecond, MaxBackoff: 10 * time.Second, Degraded: true}
+	if startup != want {
+		t.Errorf("want %+v, got %+v", want, startup)
+	}
+}
+
+func TestLoadStartupDefaults(t *testing.T) {
+	for _, name := range []string{"DATABASE_STARTUP_ATTEMPTS", "DATABASE_STARTUP_BACKOFF", "DATABASE_STARTUP_MAX_BACKOFF", "DATABASE_STARTUP_DEGRADED"} {
+		t.Setenv(name, "")
+	}
+	if startup, err := loadStartup(); err != nil || startup != (health.Startup{}) {
+		t.Errorf("want the zero value, got %+v %v", startup, err)
+	}
+}
+
+func TestLoadStartupInvalid(t *testing.T) {
+	for name, value := range map[string]string{
+		"DATABASE_STARTUP_ATTEMPTS": "five",
+		"DATABASE_STARTUP_BACKOFF":  "1",
+		"DATABASE_STARTUP_DEGRADED": "maybe",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := loadStartup(); err == nil {
+				t.Errorf("%s=%s: want an error", name, value)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package auto_yaml
 
 import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/mengri/utils-store/health"
 	"github.com/mengri/utils-store/store"
 	store_mysql "github.com/mengri/utils-store/store/mysql"
 	"github.com/mengri/utils/autowire-v2"
@@ -24,6 +29,17 @@ func (m *mysqlInit) OnPreComplete() {
 	m.InitDb()
 }
 func (m *mysqlInit) InitDb() {
+	db, err := store_mysql.NewDb(context.Background(), m.config.getDBNS(), m.config.Startup)
+	if err != nil {
+		log.Fatalf("connect mysql %s:%d error:%s", m.config.Ip, m.config.Port, err.Error())
+	}
+	m.IDB = db
+}
 
-	m.IDB = store_mysql.CreateDb(m.config.getDBNS())
+func (m *mysqlInit) Health(ctx context.Context) error {
+	h, ok := m.IDB.(health.IHealth)
+	if !ok {
+		return errors.New("mysql health check not supported")
+	}
+	return h.Health(ctx)
 }
@@ -2,6 +2,8 @@ package auto_yaml
 
 import (
 	"fmt"
+
+	"github.com/mengri/utils-store/health"
 )
 
 type DBConfig struct {
@@ -10,6 +12,9 @@ type DBConfig struct {
 	Ip       string `yaml:"ip"`
 	Port     int    `yaml:"port"`
 	Db       string `yaml:"db"`
+
+	// Startup 启动时的连接重试和降级配置, 不配置时只尝试一次, 失败后退出
+	Startup health.Startup `yaml:"startup"`
 }
 
 func (c *DBConfig) getDBNS() string {
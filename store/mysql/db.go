@@ -10,6 +10,8 @@ package store_mysql
 
 import (
 	"context"
+	"fmt"
+	"github.com/mengri/utils-store/health"
 	"github.com/mengri/utils-store/store"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -20,9 +22,13 @@ import (
 )
 
 var (
-	_ store.IDB = (*storeDB)(nil)
+	_ store.IDB      = (*storeDB)(nil)
+	_ health.IHealth = (*storeDB)(nil)
 )
 
+// gormOpen 测试时替换以检查连接失败后连接池是否关闭
+var gormOpen = gorm.Open
+
 type storeDB struct {
 	db *gorm.DB
 }
@@ -49,26 +55,79 @@ func (m *storeDB) IsTxCtx(ctx context.Context) bool {
 	return false
 }
 
+// Health 通过连接池ping数据库
+func (m *storeDB) Health(ctx context.Context) error {
+	sqlDb, err := m.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.PingContext(ctx)
+}
+
+// CreateDb 连接失败时直接退出进程, 需要处理错误或启动重试时使用 NewDb
 func CreateDb(dbns string) store.IDB {
-	dialector := mysql.Open(dbns)
-	db, err := gorm.Open(dialector, &gorm.Config{
-		Logger: logger.New(slog.New(os.Stderr, "\r\n", slog.LstdFlags), logger.Config{
-			SlowThreshold:             200 * time.Millisecond,
-			LogLevel:                  logger.Info,
-			IgnoreRecordNotFoundError: false,
-			Colorful:                  true,
-		}),
-	})
+	db, err := NewDb(context.Background(), dbns, health.Startup{})
 	if err != nil {
 		slog.Fatal(err)
 	}
+	return db
+}
+
+// NewDb 按 startup 配置重试连接数据库
+// 全部尝试失败时, 开启降级则返回一个延迟连接的实例(数据库恢复后自动可用, 期间 Health 返回错误), 否则返回错误
+func NewDb(ctx context.Context, dbns string, startup health.Startup) (store.IDB, error) {
+	var db *gorm.DB
+	err := startup.Do(ctx, "mysql", func(ctx context.Context) error {
+		var err error
+		db, err = gormOpen(mysql.Open(dbns), gormConfig())
+		if err != nil {
+			closeDb(db)
+		}
+		return err
+	})
+	if err != nil {
+		if !startup.Degraded {
+			return nil, err
+		}
+		slog.Printf("mysql unavailable, start in degraded mode: %s", err.Error())
+		// 数据库不可用时无法查询版本, 跳过版本检测和自动ping以获得延迟连接的实例
+		config := gormConfig()
+		config.DisableAutomaticPing = true
+		db, err = gormOpen(mysql.New(mysql.Config{DSN: dbns, SkipInitializeWithVersion: true}), config)
+		if err != nil {
+			closeDb(db)
+			return nil, err
+		}
+	}
 	sqlDb, err := db.DB()
 	if err != nil {
-		slog.Fatal(err)
+		closeDb(db)
+		return nil, fmt.Errorf("get sql db error:%w", err)
 	}
 	sqlDb.SetConnMaxLifetime(time.Second * 9)
 	sqlDb.SetMaxOpenConns(200)
 	sqlDb.SetMaxIdleConns(200)
 
-	return NewStoreDB(db)
+	return NewStoreDB(db), nil
+}
+
+// closeDb 关闭连接失败时 gorm.Open 已经创建的连接池, gorm 在自动ping失败时不会关闭
+func closeDb(db *gorm.DB) {
+	if db == nil || db.ConnPool == nil {
+		return
+	}
+	if sqlDb, err := db.DB(); err == nil {
+		_ = sqlDb.Close()
+	}
+}
+
+func gormConfig() *gorm.Config {
+	return &gorm.Config{
+		Logger: logger.New(slog.New(os.Stderr, "\r\n", slog.LstdFlags), logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  logger.Info,
+			IgnoreRecordNotFoundError: false,
+			Colorful:                  true,
+		}),
+	}
 }
@@ -0,0 +1,87 @@
+package store_mysql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"github.com/mengri/utils-store/health"
+)
+
+// 没有监听的端口, 连接立即失败
+const unavailableDSN = "root@tcp(127.0.0.1:1)/test?timeout=100ms"
+
+// recordOpens 记录 NewDb 打开的所有连接池
+// 跳过版本查询, 使连接失败发生在 gorm.Open 的自动ping中, 这时 gorm 不会关闭已经创建的连接池
+func recordOpens(t *testing.T) *[]*gorm.DB {
+	t.Helper()
+	opened := new([]*gorm.DB)
+	t.Cleanup(func() {
+		gormOpen = gorm.Open
+	})
+	gormOpen = func(dialector gorm.Dialector, opts ...gorm.Option) (*gorm.DB, error) {
+		if d, ok := dialector.(*mysql.Dialector); ok {
+			d.SkipInitializeWithVersion = true
+		}
+		db, err := gorm.Open(dialector, opts...)
+		*opened = append(*opened, db)
+		return db, err
+	}
+	return opened
+}
+
+func isClosed(t *testing.T, db *gorm.DB) bool {
+	t.Helper()
+	sqlDb, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sqlDb.PingContext(context.Background())
+	return err != nil && strings.Contains(err.Error(), "database is closed")
+}
+
+// 每次失败的连接尝试都要关闭 gorm.Open 创建的连接池
+func TestNewDbClosesFailedAttempts(t *testing.T) {
+	opened := recordOpens(t)
+	_, err := NewDb(context.Background(), unavailableDSN, health.Startup{Attempts: 3, Backoff: time.Millisecond})
+	if err == nil {
+		t.Fatal("NewDb: want an error for an unavailable database")
+	}
+	if len(*opened) != 3 {
+		t.Fatalf("want 3 attempts, got %d", len(*opened))
+	}
+	for i, db := range *opened {
+		if !isClosed(t, db) {
+			t.Errorf("attempt %d: connection pool should be closed", i+1)
+		}
+	}
+}
+
+func TestNewDbDegraded(t *testing.T) {
+	opened := recordOpens(t)
+	db, err := NewDb(context.Background(), unavailableDSN, health.Startup{Attempts: 2, Backoff: time.Millisecond, Degraded: true})
+	if err != nil {
+		t.Fatalf("NewDb degraded: want an instance, got %v", err)
+	}
+	if err := db.(health.IHealth).Health(context.Background()); err == nil || errors.Is(err, context.Canceled) {
+		t.Errorf("Health: want a connection error while the database is unavailable, got %v", err)
+	}
+	if len(*opened) != 3 {
+		t.Fatalf("want 2 attempts and the degraded open, got %d", len(*opened))
+	}
+	for i, db := range (*opened)[:2] {
+		if !isClosed(t, db) {
+			t.Errorf("attempt %d: connection pool should be closed", i+1)
+		}
+	}
+	if isClosed(t, (*opened)[2]) {
+		t.Error("the degraded instance should stay open")
+	}
+	sqlDb, _ := (*opened)[2].DB()
+	_ = sqlDb.Close()
+}
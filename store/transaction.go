@@ -4,12 +4,47 @@ import (
 	"context"
 	"github.com/mengri/utils/autowire-v2"
 	"gorm.io/gorm"
+	"sync"
 )
 
 var _ ITransaction = (*imlTransaction)(nil)
 
 var TxContextKey = struct{}{}
 
+type txHooksKey struct{}
+
+// txHooks 事务提交后需要执行的操作
+type txHooks struct {
+	lock sync.Mutex
+	fns  []func(ctx context.Context)
+}
+
+func (h *txHooks) add(fn func(ctx context.Context)) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.fns = append(h.fns, fn)
+}
+
+func (h *txHooks) run(ctx context.Context) {
+	h.lock.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.lock.Unlock()
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
+
+// AfterCommit ctx 处于 Transaction 创建的事务中时, fn 在事务提交后执行, 事务回滚时不执行; 否则立即执行
+// fn 收到的ctx不再携带事务
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if hooks, ok := ctx.Value(txHooksKey{}).(*txHooks); ok {
+		hooks.add(fn)
+		return
+	}
+	fn(ctx)
+}
+
 type ITransaction interface {
 	Transaction(ctx context.Context, f func(txCtx context.Context) error) error
 }
@@ -22,10 +57,17 @@ func (b *imlTransaction) Transaction(ctx context.Context, f func(context.Context
 	if b.IsTxCtx(ctx) {
 		return f(ctx)
 	}
-	return b.DB(ctx).Transaction(func(tx *gorm.DB) error {
+	hooks := new(txHooks)
+	err := b.DB(ctx).Transaction(func(tx *gorm.DB) error {
 		txCtx := context.WithValue(ctx, TxContextKey, tx)
+		txCtx = context.WithValue(txCtx, txHooksKey{}, hooks)
 		return f(txCtx)
 	})
+	if err != nil {
+		return err
+	}
+	hooks.run(ctx)
+	return nil
 }
 func init() {
 	autowire.Auto[ITransaction](func() ITransaction {